@@ -4,17 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
-	"strings"
+	"path/filepath"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/hasura/ndc-sdk-go/connector"
 	"github.com/hasura/ndc-sdk-go/schema"
 )
 
+// the name of the configuration file in the configuration directory
+const configurationFileName = "config.json"
+
 type Configuration struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
@@ -22,6 +25,20 @@ type Configuration struct {
 	User     string `json:"user"`
 	Password string `json:"password"`
 	Schema   Schema `json:"schema"`
+	// DSNs of read replicas. Queries are load-balanced across healthy replicas, mutations always go to the primary database
+	Replicas []string `json:"replicas"`
+	// row-level security policies, keyed by collection name
+	Permissions map[string]Permission `json:"permissions"`
+}
+
+// Permission is the row-level security policy of a collection.
+// The predicate is ANDed into every query, relationship subquery, exists clause, update and delete of the collection
+type Permission struct {
+	// Predicate filters the rows of the collection that a request can access.
+	// Variables in comparison values refer to the session arguments supplied with the request,
+	// which are the collection arguments of a query or the arguments of a procedure.
+	// The schema declares the session arguments as nullable arguments of every collection and of the update and delete procedures
+	Predicate schema.Expression `json:"predicate"`
 }
 
 type Schema struct {
@@ -85,8 +102,19 @@ type ForeignKey struct {
 }
 
 type State struct {
-	Database  *sql.DB
-	Telemetry *connector.TelemetryState
+	// the primary database, which serves mutations
+	Database *sql.DB
+	// read replicas which serve queries, falling back to the primary database
+	Replicas *replicaPool
+	// FULLTEXT indexes of the database, introspected on startup
+	FullTextIndexes fullTextIndexes
+	// max_allowed_packet of the primary database, which limits the size of batched insert statements
+	MaxAllowedPacket int
+	// AUTO_INCREMENT columns of the tables, keyed by table name, introspected on startup
+	AutoIncrementColumns map[string]string
+	// auto_increment_increment of the primary database, the interval between generated keys. Zero if it is unknown
+	AutoIncrementIncrement int
+	Telemetry              *connector.TelemetryState
 }
 
 type Connector struct{}
@@ -99,176 +127,36 @@ func (mc *Connector) Query(ctx context.Context, configuration *Configuration, st
 	}
 	rowSets := make([]schema.RowSet, 0, len(variableSets))
 
-	sql := getFetchQuery(request)
-
-	rows, err := state.Database.Query(sql)
-	if err != nil {
-		fmt.Print("Database query failed!")
-	}
-	defer rows.Close()
-
-	var rowSet schema.RowSet
-	rowSet.Rows = []map[string]any{}
-
-	cols, err := rows.Columns()
-	if err != nil {
-		return schema.QueryResponse{}, err
-	}
-
-	for rows.Next() {
-		columns := make([]any, len(cols))
-		columnPointers := make([]any, len(cols))
-		for i := range columns {
-			columnPointers[i] = &columns[i]
+	fetch := newRowFetcher(state.Replicas)
+	for _, variables := range variableSets {
+		sessionArguments, err := evalQueryArguments(request.Arguments, variables)
+		if err != nil {
+			return nil, err
 		}
-
-		if err := rows.Scan(columnPointers...); err != nil {
-			return schema.QueryResponse{}, err
+		query, err := newQueryCompiler(request.CollectionRelationships, variables).
+			WithPermissions(configuration.Permissions, sessionArguments).
+			WithFullTextIndexes(state.FullTextIndexes).
+			Compile(request.Collection, &request.Query)
+		if err != nil {
+			return nil, err
 		}
 
-		rowMap := make(map[string]any)
-		for i, colName := range cols {
-			val := columnPointers[i].(*any)
-			switch v := (*val).(type) {
-			case []byte:
-				rowMap[colName] = string(v)
-			default:
-				rowMap[colName] = *val
-			}
+		rowSet, err := executeQuery(ctx, fetch, query)
+		if err != nil {
+			return nil, err
 		}
-
-		rowSet.Rows = append(rowSet.Rows, rowMap)
-	}
-
-	if err := rows.Err(); err != nil {
-		return schema.QueryResponse{}, err
+		rowSets = append(rowSets, *rowSet)
 	}
 
-	rowSets = append(rowSets, rowSet)
 	return rowSets, nil
 }
 
-func getFetchQuery(request *schema.QueryRequest) string {
-	var fields []string
-
-	for _, field := range request.Query.Fields {
-		if fieldType, ok := field["type"].(schema.FieldType); ok && fieldType == schema.FieldTypeColumn {
-			if columnName, ok := field["column"].(string); ok {
-				fields = append(fields, columnName)
-			}
-		}
-	}
-
-	selectClause := "SELECT " + strings.Join(fields, ", ") + " FROM " + request.Collection
-
-	limitClause := ""
-	if request.Query.Limit != nil {
-		limitClause = fmt.Sprintf("LIMIT %d", *request.Query.Limit)
-	}
-
-	offsetClause := ""
-	if request.Query.Offset != nil {
-		offsetClause = fmt.Sprintf("OFFSET %d", *request.Query.Offset)
-	}
-
-	orderByClause := ""
-	if request.Query.OrderBy != nil && len(request.Query.OrderBy.Elements) > 0 {
-		var orderByElements []string
-		for _, element := range request.Query.OrderBy.Elements {
-			if targetName, ok := element.Target["name"].(string); ok {
-				orderByElements = append(orderByElements, fmt.Sprintf("%s %s", targetName, element.OrderDirection))
-			}
-		}
-		orderByClause = "ORDER BY " + strings.Join(orderByElements, ", ")
-	}
-
-	whereClause := ""
-	if request.Query.Predicate != nil {
-		whereClause = "WHERE " + visitExpression(request.Query.Predicate)
-	}
-
-	sql := fmt.Sprintf("%s %s %s %s %s", selectClause, orderByClause, whereClause, limitClause, offsetClause)
-
-	return sql
-}
-
-func visitExpression(expression schema.Expression) string {
-	expressionType, err := expression.Type()
-	if err != nil {
-		fmt.Print("Invalid expression type in the predicate")
-	}
-	switch expressionType {
-	case schema.ExpressionTypeAnd:
-		return visitLogicalExpression(expression, "AND")
-	case schema.ExpressionTypeOr:
-		return visitLogicalExpression(expression, "OR")
-	case schema.ExpressionTypeNot:
-		return "NOT " + visitExpression(expression)
-	case schema.ExpressionTypeUnaryComparisonOperator:
-		return visitUnaryComparison(expression)
-	case schema.ExpressionTypeBinaryComparisonOperator:
-		return visitBinaryComparison(expression)
-	default:
-		return ""
-	}
-}
-
-func visitLogicalExpression(expression schema.Expression, operator string) string {
-	var clauses []string
-	subExpressions := expression["expressions"].([]schema.Expression)
-	for _, subExpression := range subExpressions {
-		clauses = append(clauses, visitExpression(subExpression))
-	}
-	return "(" + strings.Join(clauses, " "+operator+" ") + ")"
-}
-
-func visitUnaryComparison(expression schema.Expression) string {
-	targetName := expression["column"].(schema.ComparisonTarget).Name
-	operator := expression["operator"]
-	return fmt.Sprintf("%s %s", targetName, operator)
-}
-
-func visitBinaryComparison(expression schema.Expression) string {
-	targetName := expression["column"].(schema.ComparisonTarget).Name
-	operator := expression["operator"]
-	var clause string
-	switch operator {
-	case "_lte":
-		clause = "<="
-	case "_in":
-		clause = "IN"
-	case "_like":
-		clause = "LIKE"
-	case "_eq":
-		clause = "="
-	default:
-		fmt.Print("Invalid comparison operator!")
-	}
-	value := getComparisonValue(expression)
-	return fmt.Sprintf("%s %s %s", targetName, clause, value)
-}
-
-func getComparisonValue(expression map[string]interface{}) string {
-	comparisonValue := expression["value"].(schema.ComparisonValue)["value"]
-
-	switch value := comparisonValue.(type) {
-	case []interface{}:
-		formattedValues := make([]string, len(value))
-		for i, item := range value {
-			formattedValues[i] = fmt.Sprintf("\"%v\"", item)
-		}
-		return fmt.Sprintf("(%s)", strings.Join(formattedValues, ", "))
-	default:
-		return fmt.Sprintf("'%v'", value)
-	}
-}
-
 func (mc *Connector) GetCapabilities(configuration *Configuration) schema.CapabilitiesResponseMarshaler {
 	return nil
 }
 
 func (mc *Connector) GetSchema(ctx context.Context, configuration *Configuration, state *State) (schema.SchemaResponseMarshaler, error) {
-	return nil, nil
+	return buildSchemaResponse(configuration), nil
 }
 
 func (mc *Connector) HealthCheck(ctx context.Context, configuration *Configuration, state *State) error {
@@ -276,7 +164,35 @@ func (mc *Connector) HealthCheck(ctx context.Context, configuration *Configurati
 }
 
 func (mc *Connector) Mutation(ctx context.Context, configuration *Configuration, state *State, request *schema.MutationRequest) (*schema.MutationResponse, error) {
-	return nil, nil
+	tx, err := state.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, schema.InternalServerError("failed to begin transaction", map[string]any{
+			"cause": err.Error(),
+		})
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	exec := newStatementExecutor(tx)
+	results := make([]schema.MutationOperationResults, 0, len(request.Operations))
+	for _, operation := range request.Operations {
+		result, err := executeProcedure(ctx, exec, configuration, state, request.CollectionRelationships, &operation)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, schema.NewProcedureResult(result).Encode())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, schema.InternalServerError("failed to commit transaction", map[string]any{
+			"cause": err.Error(),
+		})
+	}
+
+	return &schema.MutationResponse{
+		OperationResults: results,
+	}, nil
 }
 
 func (mc *Connector) MutationExplain(ctx context.Context, configuration *Configuration, state *State, request *schema.MutationRequest) (*schema.ExplainResponse, error) {
@@ -285,25 +201,57 @@ func (mc *Connector) MutationExplain(ctx context.Context, configuration *Configu
 	}, nil
 }
 
-func (mc *Connector) ParseConfiguration(ctx context.Context, rawConfiguration string) (*Configuration, error) {
-	data, err := readConfigFile("config.json")
+// ParseConfiguration reads the config.json file of the configuration directory, or the current directory if it is empty.
+// The path can also be the configuration file itself
+func (mc *Connector) ParseConfiguration(ctx context.Context, configurationDir string) (*Configuration, error) {
+	path := configurationDir
+	if info, err := os.Stat(path); path == "" || (err == nil && info.IsDir()) {
+		path = filepath.Join(path, configurationFileName)
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Error reading config file: %v\n", err)
-		return nil, nil
+		return nil, fmt.Errorf("failed to read the configuration file: %w", err)
 	}
 
 	var config Configuration
-
 	if err := json.Unmarshal(data, &config); err != nil {
-		log.Fatalf("Error unmarshaling config file: %v\n", err)
-		return nil, nil
+		return nil, fmt.Errorf("failed to decode the configuration file %s: %w", path, err)
 	}
-
 	return &config, nil
 }
 
 func (mc *Connector) QueryExplain(ctx context.Context, configuration *Configuration, state *State, request *schema.QueryRequest) (*schema.ExplainResponse, error) {
-	return nil, schema.NotSupportedError("query explain has not been supported yet", nil)
+	var variables map[string]any
+	if len(request.Variables) > 0 {
+		variables = request.Variables[0]
+	}
+	sessionArguments, err := evalQueryArguments(request.Arguments, variables)
+	if err != nil {
+		return nil, err
+	}
+	query, err := newQueryCompiler(request.CollectionRelationships, variables).
+		WithPermissions(configuration.Permissions, sessionArguments).
+		WithFullTextIndexes(state.FullTextIndexes).
+		Compile(request.Collection, &request.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := newRowFetcher(state.Replicas)(ctx, "EXPLAIN "+query.SQL, query.Arguments...)
+	if err != nil {
+		return nil, err
+	}
+	rawPlan, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.ExplainResponse{
+		Details: schema.ExplainResponseDetails{
+			"SQL":  query.SQL,
+			"Plan": string(rawPlan),
+		},
+	}, nil
 }
 
 func (mc *Connector) TryInitState(ctx context.Context, configuration *Configuration, metrics *connector.TelemetryState) (*State, error) {
@@ -311,24 +259,48 @@ func (mc *Connector) TryInitState(ctx context.Context, configuration *Configurat
 
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
-	} else {
-		fmt.Println("Database connected successfully")
 	}
 
-	return &State{
-		Database:  db,
-		Telemetry: metrics,
-	}, nil
-}
-
-func readConfigFile(filename string) ([]byte, error) {
-	file, err := os.Open(filename)
+	replicas, err := newReplicaPool(db, configuration.Replicas, metrics)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	replicas.Start(ctx, defaultReplicaHealthCheckInterval)
+
+	indexes, err := introspectFullTextIndexes(ctx, newRowFetcher(db), configuration.DB)
+	if err != nil {
+		connector.GetLogger(ctx).Warn("failed to introspect FULLTEXT indexes, the _match operator is disabled", slog.Any("error", err))
+		indexes = fullTextIndexes{}
+	}
+	maxAllowedPacket, err := introspectMaxAllowedPacket(ctx, newRowFetcher(db))
+	if err != nil {
+		connector.GetLogger(ctx).Warn("failed to introspect max_allowed_packet, using the default value", slog.Any("error", err), slog.Int("default", defaultMaxAllowedPacket))
+		maxAllowedPacket = defaultMaxAllowedPacket
+	}
+	autoIncrementColumns, err := introspectAutoIncrementColumns(ctx, newRowFetcher(db), configuration.DB)
+	if err != nil {
+		connector.GetLogger(ctx).Warn("failed to introspect AUTO_INCREMENT columns, insert procedures return null generated keys", slog.Any("error", err))
+		autoIncrementColumns = map[string]string{}
+	}
+	autoIncrementIncrement, err := introspectAutoIncrementIncrement(ctx, newRowFetcher(db))
+	if err != nil {
+		connector.GetLogger(ctx).Warn("failed to introspect auto_increment_increment, insert procedures return null generated keys", slog.Any("error", err))
+		autoIncrementIncrement = 0
+	}
+
+	return &State{
+		Database:               db,
+		Replicas:               replicas,
+		FullTextIndexes:        indexes,
+		MaxAllowedPacket:       maxAllowedPacket,
+		AutoIncrementColumns:   autoIncrementColumns,
+		AutoIncrementIncrement: autoIncrementIncrement,
+		Telemetry:              metrics,
+	}, nil
+}
 
-	return ioutil.ReadAll(file)
+// Close releases the connections of the state on shutdown and after the configuration is reloaded
+func (mc *Connector) Close(ctx context.Context, state *State) error {
+	return errors.Join(state.Replicas.Close(), state.Database.Close())
 }
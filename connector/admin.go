@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+const sdkModulePath = "github.com/hasura/ndc-sdk-go"
+
+// sdkVersion returns the version of the SDK module that the connector is built with
+var sdkVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == sdkModulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == sdkModulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+})
+
+// AdminLogLevel is the request and response body of the /admin/log-level endpoint
+type AdminLogLevel struct {
+	// Level is the minimum level of logs, one of debug, info, warn and error
+	Level string `json:"level"`
+}
+
+// AdminInfoResponse is the response body of the /admin/info endpoint
+type AdminInfoResponse struct {
+	// SDKVersion is the version of the SDK that the connector is built with
+	SDKVersion string `json:"sdk_version"`
+	// Version is the version of the connector that is set by the WithVersion option
+	Version string `json:"version"`
+	// ConfigurationGeneration increases on every reload of the configuration of the default tenant.
+	// It is omitted if the server has no default tenant
+	ConfigurationGeneration uint64 `json:"configuration_generation,omitempty"`
+	// Tenants are the configuration generations of the named tenants
+	Tenants map[string]uint64 `json:"tenants,omitempty"`
+}
+
+// buildAdminHandler builds the handler of the admin listener, which serves the log level, info and pprof endpoints.
+// The endpoints are protected by the admin token
+func (s *Server[Configuration, State]) buildAdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, s.withAdminAuth(func(w http.ResponseWriter, r *http.Request) {
+			// the admin listener doesn't use the router, so the logger of the server is set here
+			handler(w, r.WithContext(context.WithValue(r.Context(), logContextKey, s.logger)))
+		}))
+	}
+	handle("/admin/log-level", s.LogLevelHandler)
+	handle("/admin/info", s.AdminInfoHandler)
+	handle("/debug/pprof/", pprof.Index)
+	handle("/debug/pprof/cmdline", pprof.Cmdline)
+	handle("/debug/pprof/profile", pprof.Profile)
+	handle("/debug/pprof/symbol", pprof.Symbol)
+	handle("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+func createAdminServer(port uint, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+}
+
+// LogLevelHandler implements a handler for the /admin/log-level endpoint.
+// The GET method returns the level of the logger, and the PUT method changes it without a restart
+func (s *Server[Configuration, State]) LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if s.logLevelVar == nil {
+			writeError(w, logger, schema.NewConnectorError(http.StatusNotImplemented, "the log level can't be changed, set the WithLogLevelVar option", map[string]any{}))
+			return
+		}
+		var body AdminLogLevel
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJson(w, logger, http.StatusUnprocessableEntity, schema.ErrorResponse{
+				Message: "failed to decode json request body",
+				Details: map[string]any{
+					"cause": err.Error(),
+				},
+			})
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.ToUpper(body.Level))); err != nil {
+			writeJson(w, logger, http.StatusUnprocessableEntity, schema.ErrorResponse{
+				Message: fmt.Sprintf("invalid log level %s", body.Level),
+				Details: map[string]any{
+					"cause": err.Error(),
+				},
+			})
+			return
+		}
+		previous := s.logLevelVar.Level()
+		s.logLevelVar.Set(level)
+		s.logger.Info("changed the log level", slog.String("previous", previous.String()), slog.String("level", level.String()))
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeJson(w, logger, http.StatusMethodNotAllowed, schema.ErrorResponse{
+			Message: fmt.Sprintf("method %s is not allowed", r.Method),
+			Details: map[string]any{},
+		})
+		return
+	}
+
+	level := s.logLevel
+	if s.logLevelVar != nil {
+		level = s.logLevelVar.Level()
+	}
+	writeJson(w, logger, http.StatusOK, AdminLogLevel{
+		Level: strings.ToLower(level.String()),
+	})
+}
+
+// AdminInfoHandler implements a handler for the /admin/info endpoint, GET method that returns the build versions
+// and the configuration generations
+func (s *Server[Configuration, State]) AdminInfoHandler(w http.ResponseWriter, r *http.Request) {
+	result := AdminInfoResponse{
+		SDKVersion: sdkVersion(),
+		Version:    s.version,
+	}
+	if s.defaultTenant != nil {
+		result.ConfigurationGeneration = s.defaultTenant.runtime.Load().generation
+	}
+	for name, t := range s.tenants {
+		if result.Tenants == nil {
+			result.Tenants = make(map[string]uint64, len(s.tenants))
+		}
+		result.Tenants[name] = t.runtime.Load().generation
+	}
+	writeJson(w, GetLogger(r.Context()), http.StatusOK, result)
+}
@@ -0,0 +1,139 @@
+package connector
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+func TestAdminServer(t *testing.T) {
+	levelVar := &slog.LevelVar{}
+	server, err := NewServer[mockConfiguration, mockState](&reloadConnector{
+		closed: make(chan *mockState, 10),
+	}, &ServerOptions{
+		Configuration:      "{}",
+		InlineConfig:       true,
+		ServiceTokenSecret: "random-secret",
+		AdminTokenSecret:   "admin-secret",
+		AdminPort:          9090,
+	}, WithVersion("1.2.3"), WithLogLevelVar(levelVar))
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+	adminServer := createAdminServer(0, server.buildAdminHandler(), nil)
+	testServer := httptest.NewServer(adminServer.Handler)
+	defer testServer.Close()
+
+	request := func(t *testing.T, method string, path string, body string, token string) *http.Response {
+		req, err := http.NewRequest(method, testServer.URL+path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		return res
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/admin/info", "", "")
+		assertHTTPResponseStatus(t, "GET /admin/info", res, http.StatusUnauthorized)
+		// the service token doesn't authorize the admin endpoints
+		res = request(t, http.MethodGet, "/admin/info", "", "random-secret")
+		assertHTTPResponseStatus(t, "GET /admin/info", res, http.StatusUnauthorized)
+	})
+
+	t.Run("missing_admin_token", func(t *testing.T) {
+		_, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+			Configuration: "{}",
+			InlineConfig:  true,
+			AdminPort:     9090,
+		})
+		if err == nil {
+			t.Errorf("expected an error of the admin listener without an admin token")
+		}
+	})
+
+	t.Run("log_level", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/admin/log-level", "", "admin-secret")
+		assertHTTPResponse(t, res, http.StatusOK, AdminLogLevel{Level: "info"})
+
+		res = request(t, http.MethodPut, "/admin/log-level", `{"level": "debug"}`, "admin-secret")
+		assertHTTPResponse(t, res, http.StatusOK, AdminLogLevel{Level: "debug"})
+		if levelVar.Level() != slog.LevelDebug {
+			t.Errorf("expected the debug level, got %s", levelVar.Level())
+		}
+
+		res = request(t, http.MethodPut, "/admin/log-level", `{"level": "verbose"}`, "admin-secret")
+		assertHTTPResponseStatus(t, "PUT /admin/log-level", res, http.StatusUnprocessableEntity)
+		if levelVar.Level() != slog.LevelDebug {
+			t.Errorf("expected the level unchanged, got %s", levelVar.Level())
+		}
+	})
+
+	t.Run("info", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/admin/info", "", "admin-secret")
+		assertHTTPResponse(t, res, http.StatusOK, AdminInfoResponse{
+			SDKVersion:              sdkVersion(),
+			Version:                 "1.2.3",
+			ConfigurationGeneration: 1,
+		})
+
+		if err := server.Reload(); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		res = request(t, http.MethodGet, "/admin/info", "", "admin-secret")
+		assertHTTPResponse(t, res, http.StatusOK, AdminInfoResponse{
+			SDKVersion:              sdkVersion(),
+			Version:                 "1.2.3",
+			ConfigurationGeneration: 2,
+		})
+	})
+
+	t.Run("pprof", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/debug/pprof/cmdline", "", "admin-secret")
+		assertHTTPResponseStatus(t, "GET /debug/pprof/cmdline", res, http.StatusOK)
+	})
+}
+
+func TestAdminLogLevelWithoutLevelVar(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration:    "{}",
+		InlineConfig:     true,
+		AdminTokenSecret: "admin-secret",
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	testServer := httptest.NewServer(server.buildAdminHandler())
+	defer testServer.Close()
+
+	req, err := http.NewRequest(http.MethodPut, testServer.URL+"/admin/log-level", bytes.NewBufferString(`{"level": "debug"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponse(t, res, http.StatusNotImplemented, schema.ErrorResponse{
+		Message: "the log level can't be changed, set the WithLogLevelVar option",
+		Details: map[string]any{},
+	})
+}
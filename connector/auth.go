@@ -0,0 +1,261 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// watchedFilePollInterval is the minimum interval between checks of a watched file for changes
+const watchedFilePollInterval = time.Second
+
+const claimsContextKey serverContextKey = "hasura-claims"
+
+var errBearerTokenMismatch = errors.New("Bearer token does not match.") //nolint:all
+
+// Claims are the validated claims of an authenticated request
+type Claims map[string]any
+
+// Authenticator authenticates the requests of the connector endpoints
+type Authenticator interface {
+	// Authenticate validates the credentials of the request.
+	// It returns the claims of the request, which are nil if the credentials don't carry any
+	Authenticate(r *http.Request) (Claims, error)
+}
+
+// GetClaims gets the validated claims of the request from the context, or nil if the request has no claims
+func GetClaims(ctx context.Context) Claims {
+	if claims, ok := ctx.Value(claimsContextKey).(Claims); ok {
+		return claims
+	}
+	return nil
+}
+
+// WithAuthenticator sets a custom authenticator option, which replaces the authenticators of the server options
+func WithAuthenticator(authenticator Authenticator) ServeOption {
+	return func(so *serveOptions) {
+		so.authenticator = authenticator
+	}
+}
+
+// newAuthenticator creates the authenticator of the server options, or nil if authentication is disabled
+func newAuthenticator(options *ServerOptions) (Authenticator, error) {
+	var authenticators []Authenticator
+	var tokens []string
+	if options.ServiceTokenSecret != "" {
+		tokens = append(tokens, options.ServiceTokenSecret)
+	}
+	tokens = append(tokens, options.ServiceTokenSecrets...)
+	if len(tokens) > 0 {
+		authenticators = append(authenticators, NewStaticTokenAuthenticator(tokens...))
+	}
+	if options.ServiceTokenFile != "" {
+		authenticator, err := NewTokenFileAuthenticator(options.ServiceTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+	if options.JWKSFile != "" {
+		authenticator, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+			JWKSFile: options.JWKSFile,
+			Issuer:   options.JWTIssuer,
+			Audience: options.JWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	switch len(authenticators) {
+	case 0:
+		return nil, nil
+	case 1:
+		return authenticators[0], nil
+	default:
+		return NewChainAuthenticator(authenticators...), nil
+	}
+}
+
+func getBearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+}
+
+// ChainAuthenticator accepts requests that any of its authenticators accepts
+type ChainAuthenticator struct {
+	authenticators []Authenticator
+}
+
+// NewChainAuthenticator creates a ChainAuthenticator instance
+func NewChainAuthenticator(authenticators ...Authenticator) *ChainAuthenticator {
+	return &ChainAuthenticator{
+		authenticators: authenticators,
+	}
+}
+
+// Authenticate tries the authenticators in order and returns the claims of the first one that succeeds
+func (ca *ChainAuthenticator) Authenticate(r *http.Request) (Claims, error) {
+	errs := make([]error, 0, len(ca.authenticators))
+	for _, authenticator := range ca.authenticators {
+		claims, err := authenticator.Authenticate(r)
+		if err == nil {
+			return claims, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// StaticTokenAuthenticator accepts a list of bearer tokens, so a token can be rotated
+// by accepting both the old and the new one until every client is updated
+type StaticTokenAuthenticator struct {
+	tokens []string
+}
+
+// NewStaticTokenAuthenticator creates a StaticTokenAuthenticator instance
+func NewStaticTokenAuthenticator(tokens ...string) *StaticTokenAuthenticator {
+	return &StaticTokenAuthenticator{
+		tokens: tokens,
+	}
+}
+
+// Authenticate checks that the bearer token of the request is one of the accepted tokens
+func (sa *StaticTokenAuthenticator) Authenticate(r *http.Request) (Claims, error) {
+	token, ok := getBearerToken(r)
+	if !ok || !matchToken(sa.tokens, token) {
+		return nil, errBearerTokenMismatch
+	}
+	return nil, nil
+}
+
+func matchToken(tokens []string, token string) bool {
+	matched := 0
+	for _, t := range tokens {
+		// compare with every token so that the response time doesn't reveal which one matches
+		matched |= subtle.ConstantTimeCompare([]byte(t), []byte(token))
+	}
+	return matched == 1
+}
+
+// TokenFileAuthenticator accepts the bearer tokens listed in a file, one per line.
+// Blank lines and lines starting with # are ignored. The file is read again when it changes
+type TokenFileAuthenticator struct {
+	file   *watchedFile
+	lock   sync.RWMutex
+	tokens []string
+}
+
+// NewTokenFileAuthenticator creates a TokenFileAuthenticator instance
+func NewTokenFileAuthenticator(path string) (*TokenFileAuthenticator, error) {
+	ta := &TokenFileAuthenticator{}
+	ta.file = newWatchedFile(path, ta.load)
+	if err := ta.file.Reload(); err != nil {
+		return nil, err
+	}
+	return ta, nil
+}
+
+func (ta *TokenFileAuthenticator) load(data []byte) error {
+	var tokens []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	ta.lock.Lock()
+	ta.tokens = tokens
+	ta.lock.Unlock()
+	return nil
+}
+
+// Authenticate checks that the bearer token of the request is listed in the token file
+func (ta *TokenFileAuthenticator) Authenticate(r *http.Request) (Claims, error) {
+	ta.file.ReloadIfChanged()
+
+	token, ok := getBearerToken(r)
+	ta.lock.RLock()
+	defer ta.lock.RUnlock()
+	if !ok || !matchToken(ta.tokens, token) {
+		return nil, errBearerTokenMismatch
+	}
+	return nil, nil
+}
+
+// watchedFile reads a file again when its size or modification time changes.
+// The file is checked at most once per watchedFilePollInterval.
+// If reading a changed file fails, the previously loaded content is kept
+type watchedFile struct {
+	path    string
+	load    func(data []byte) error
+	lock    sync.Mutex
+	size    int64
+	modTime time.Time
+	// the time of the last check in Unix nanoseconds, read without the lock so requests between checks don't contend
+	checkedAt atomic.Int64
+}
+
+func newWatchedFile(path string, load func(data []byte) error) *watchedFile {
+	return &watchedFile{
+		path: path,
+		load: load,
+	}
+}
+
+// Reload reads and loads the file
+func (wf *watchedFile) Reload() error {
+	wf.lock.Lock()
+	defer wf.lock.Unlock()
+	wf.checkedAt.Store(time.Now().UnixNano())
+	info, err := os.Stat(wf.path)
+	if err != nil {
+		return err
+	}
+	return wf.reload(info)
+}
+
+// ReloadIfChanged loads the file if it changed since it was loaded.
+// It returns immediately if the file was checked within the poll interval
+func (wf *watchedFile) ReloadIfChanged() {
+	now := time.Now().UnixNano()
+	checkedAt := wf.checkedAt.Load()
+	if now-checkedAt < int64(watchedFilePollInterval) || !wf.checkedAt.CompareAndSwap(checkedAt, now) {
+		return
+	}
+	wf.lock.Lock()
+	defer wf.lock.Unlock()
+	info, err := os.Stat(wf.path)
+	if err != nil || (info.Size() == wf.size && info.ModTime().Equal(wf.modTime)) {
+		return
+	}
+	_ = wf.reload(info)
+}
+
+func (wf *watchedFile) reload(info os.FileInfo) error {
+	data, err := os.ReadFile(wf.path)
+	if err != nil {
+		return err
+	}
+	if err := wf.load(data); err != nil {
+		return fmt.Errorf("failed to load %s: %w", wf.path, err)
+	}
+	wf.size = info.Size()
+	wf.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,242 @@
+package connector
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+)
+
+func newAuthRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/schema", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func signJWT(t *testing.T, header map[string]any, claims map[string]any, sign func(input []byte) []byte) string {
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsBytes, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(claimsBytes)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func TestStaticTokenAuthenticator(t *testing.T) {
+	authenticator := NewStaticTokenAuthenticator("old-secret", "new-secret")
+	for _, token := range []string{"old-secret", "new-secret"} {
+		if _, err := authenticator.Authenticate(newAuthRequest(token)); err != nil {
+			t.Errorf("%s: expected no error, got %s", token, err)
+		}
+	}
+	for _, token := range []string{"", "secret"} {
+		if _, err := authenticator.Authenticate(newAuthRequest(token)); err == nil {
+			t.Errorf("%s: expected error, got nil", token)
+		}
+	}
+}
+
+func TestTokenFileAuthenticator(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokenFile, []byte("# rotated on 2024-05-01\nold-secret\n\nnew-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := NewTokenFileAuthenticator(tokenFile)
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	if _, err := authenticator.Authenticate(newAuthRequest("old-secret")); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("new-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// make sure that the modification time changes on file systems with coarse timestamps
+	if err := os.Chtimes(tokenFile, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// the file isn't checked again within the poll interval
+	if _, err := authenticator.Authenticate(newAuthRequest("old-secret")); err != nil {
+		t.Errorf("expected no error within the poll interval, got %s", err)
+	}
+	authenticator.file.checkedAt.Add(-int64(watchedFilePollInterval))
+	if _, err := authenticator.Authenticate(newAuthRequest("old-secret")); err == nil {
+		t.Error("expected the removed token to be rejected")
+	}
+	if _, err := authenticator.Authenticate(newAuthRequest("new-secret")); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("a-very-long-secret-of-the-hmac-key")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(map[string]any{
+		"keys": []map[string]any{
+			{
+				"kty": "oct",
+				"kid": "hmac",
+				"alg": "HS256",
+				"k":   base64.RawURLEncoding.EncodeToString(secret),
+			},
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+		JWKSFile: jwksFile,
+		Issuer:   "https://auth.hasura.io",
+		Audience: "ndc",
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+
+	signHS256 := func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+	signRS256 := func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+	validClaims := map[string]any{
+		"sub": "user-1",
+		"iss": "https://auth.hasura.io",
+		"aud": []string{"ndc", "engine"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	testCases := []struct {
+		name        string
+		token       string
+		expectedErr string
+	}{
+		{
+			name:  "hs256",
+			token: signJWT(t, map[string]any{"alg": "HS256", "kid": "hmac"}, validClaims, signHS256),
+		},
+		{
+			name:  "rs256",
+			token: signJWT(t, map[string]any{"alg": "RS256", "kid": "rsa"}, validClaims, signRS256),
+		},
+		{
+			name:  "rs256_without_key_id",
+			token: signJWT(t, map[string]any{"alg": "RS256"}, validClaims, signRS256),
+		},
+		{
+			name:        "algorithm_none",
+			token:       signJWT(t, map[string]any{"alg": "none"}, validClaims, func(input []byte) []byte { return nil }),
+			expectedErr: "unsupported JWT algorithm: none",
+		},
+		{
+			name:        "invalid_signature",
+			token:       signJWT(t, map[string]any{"alg": "HS256", "kid": "rsa"}, validClaims, signHS256),
+			expectedErr: "invalid JWT signature",
+		},
+		{
+			name: "expired",
+			token: signJWT(t, map[string]any{"alg": "HS256"}, map[string]any{
+				"iss": "https://auth.hasura.io",
+				"aud": "ndc",
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			}, signHS256),
+			expectedErr: "JWT is expired",
+		},
+		{
+			name: "invalid_audience",
+			token: signJWT(t, map[string]any{"alg": "HS256"}, map[string]any{
+				"iss": "https://auth.hasura.io",
+				"aud": "engine",
+			}, signHS256),
+			expectedErr: "invalid JWT audience",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := authenticator.Authenticate(newAuthRequest(tc.token))
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("expected error %s, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if claims["sub"] != "user-1" {
+				t.Errorf("expected sub claim user-1, got %v", claims["sub"])
+			}
+		})
+	}
+
+	t.Run("claims_context", func(t *testing.T) {
+		server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+			Configuration: "{}",
+			InlineConfig:  true,
+		}, WithAuthenticator(authenticator))
+		if err != nil {
+			t.Errorf("NewServer: expected no error, got %s", err)
+			t.FailNow()
+		}
+
+		var claims Claims
+		handler := server.withAuth(func(w http.ResponseWriter, r *http.Request) {
+			claims = GetClaims(r.Context())
+		})
+		handler(httptest.NewRecorder(), newAuthRequest(testCases[0].token))
+		if !internal.DeepEqual(Claims{
+			"sub": "user-1",
+			"iss": "https://auth.hasura.io",
+			"aud": []any{"ndc", "engine"},
+			"exp": float64(validClaims["exp"].(int64)),
+		}, claims) {
+			t.Errorf("unexpected claims: %v", claims)
+		}
+	})
+}
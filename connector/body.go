@@ -0,0 +1,105 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxRequestBodySize = 10 << 20
+	defaultMaxJSONDepth       = 64
+	defaultDebugBodyLogSize   = 4096
+)
+
+// WithRequestBodyLimit sets the maximum size in bytes of request bodies of the route of the path,
+// for example /query or /mutation. It replaces the limit of the server options for that route.
+// The body size is unlimited if the limit is negative
+func WithRequestBodyLimit(path string, limit int64) ServeOption {
+	return func(so *serveOptions) {
+		if so.requestBodyLimits == nil {
+			so.requestBodyLimits = make(map[string]int64)
+		}
+		so.requestBodyLimits[path] = limit
+	}
+}
+
+// requestBodyLimit returns the maximum size in bytes of request bodies of the route of the path
+func (s *Server[Configuration, State]) requestBodyLimit(path string) int64 {
+	if limit, ok := s.requestBodyLimits[path]; ok {
+		return limit
+	}
+	if s.options.MaxRequestBodySize == 0 {
+		return defaultMaxRequestBodySize
+	}
+	return s.options.MaxRequestBodySize
+}
+
+func (s *Server[Configuration, State]) maxJSONDepth() int {
+	if s.options.MaxJSONDepth <= 0 {
+		return defaultMaxJSONDepth
+	}
+	return s.options.MaxJSONDepth
+}
+
+func (s *Server[Configuration, State]) debugBodyLogSize() int {
+	if s.options.DebugBodyLogSize <= 0 {
+		return defaultDebugBodyLogSize
+	}
+	return s.options.DebugBodyLogSize
+}
+
+// isRequestBodyTooLarge checks if the error is caused by a request body that exceeds its size limit
+func isRequestBodyTooLarge(err error) (int64, bool) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit, true
+	}
+	return 0, false
+}
+
+// checkJSONDepth checks that the nesting depth of objects and arrays of the JSON document doesn't exceed the limit,
+// so deeply nested expressions are rejected before they are decoded recursively
+func checkJSONDepth(data []byte, maxDepth int) error {
+	depth := 0
+	inString := false
+	escaped := false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("the JSON nesting depth exceeds the limit of %d", maxDepth)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// truncateBody truncates the body for logging at the maximum size, without splitting a UTF-8 character
+func truncateBody(body []byte, maxSize int) string {
+	if len(body) <= maxSize {
+		return string(body)
+	}
+	size := maxSize
+	for size > 0 && !utf8.RuneStart(body[size]) {
+		size--
+	}
+	return fmt.Sprintf("%s...(truncated %d bytes)", body[:size], len(body)-size)
+}
@@ -0,0 +1,127 @@
+package connector
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+func TestCheckJSONDepth(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		maxDepth int
+		valid    bool
+	}{
+		{name: "flat", input: `{"a": 1, "b": [1, 2]}`, maxDepth: 2, valid: true},
+		{name: "nested", input: `{"a": {"b": [1]}}`, maxDepth: 2, valid: false},
+		{name: "brackets_in_string", input: `{"a": "[[[{{{\"]]]"}`, maxDepth: 1, valid: true},
+		{name: "escaped_backslash", input: `{"a": "\\", "b": [[1]]}`, maxDepth: 2, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkJSONDepth([]byte(tc.input), tc.maxDepth)
+			if tc.valid && err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestTruncateBody(t *testing.T) {
+	if result := truncateBody([]byte("hello"), 10); result != "hello" {
+		t.Errorf("expected hello, got %s", result)
+	}
+	if result := truncateBody([]byte("hello world"), 5); result != "hello...(truncated 6 bytes)" {
+		t.Errorf("unexpected truncated body: %s", result)
+	}
+	// the multi-byte character at the boundary isn't split
+	if result := truncateBody([]byte("aé"), 2); result != "a...(truncated 2 bytes)" {
+		t.Errorf("unexpected truncated body: %s", result)
+	}
+}
+
+func TestServerRequestBodyLimit(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+		MaxJSONDepth:  8,
+	}, WithRequestBodyLimit("/query", 256), WithLogger(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))))
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	t.Run("request_entity_too_large", func(t *testing.T) {
+		res, err := httpPostJSON(fmt.Sprintf("%s/query", httpServer.URL), schema.QueryRequest{
+			Collection:              strings.Repeat("a", 300),
+			Arguments:               schema.QueryRequestArguments{},
+			CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+			Query:                   schema.Query{},
+			Variables:               []schema.QueryRequestVariablesElem{},
+		})
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponse(t, res, http.StatusRequestEntityTooLarge, schema.ErrorResponse{
+			Message: "request body is too large, the limit is 256 bytes",
+			Details: map[string]any{
+				"limit": float64(256),
+			},
+		})
+	})
+
+	t.Run("route_without_custom_limit", func(t *testing.T) {
+		res, err := httpPostJSON(fmt.Sprintf("%s/query/explain", httpServer.URL), schema.QueryRequest{
+			Collection:              strings.Repeat("a", 300),
+			Arguments:               schema.QueryRequestArguments{},
+			CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+			Query:                   schema.Query{},
+			Variables:               []schema.QueryRequestVariablesElem{},
+		})
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponseStatus(t, "POST /query/explain", res, http.StatusOK)
+	})
+
+	t.Run("json_depth_exceeded", func(t *testing.T) {
+		predicate := map[string]any{"type": "and", "expressions": []any{}}
+		for i := 0; i < 4; i++ {
+			predicate = map[string]any{"type": "and", "expressions": []any{predicate}}
+		}
+		res, err := httpPostJSON(fmt.Sprintf("%s/query/explain", httpServer.URL), map[string]any{
+			"collection":               "articles",
+			"arguments":                map[string]any{},
+			"collection_relationships": map[string]any{},
+			"query": map[string]any{
+				"predicate": predicate,
+			},
+		})
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponse(t, res, http.StatusUnprocessableEntity, schema.ErrorResponse{
+			Message: "failed to decode json request body",
+			Details: map[string]any{
+				"cause": "the JSON nesting depth exceeds the limit of 8",
+			},
+		})
+	})
+}
@@ -0,0 +1,90 @@
+package connector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WithDynamicSchema disables the cache of /schema responses, for connectors whose schema changes
+// without a configuration reload. The ETag of responses is still computed, so clients can revalidate them.
+// The request validator of [WithRequestValidation] is also built from the current schema for every request
+func WithDynamicSchema() ServeOption {
+	return func(so *serveOptions) {
+		so.dynamicSchema = true
+	}
+}
+
+// cachedResponse is an encoded JSON response with its entity tag.
+// The tag is sent as a weak validator, because the response is the same entity in every content encoding
+// but its bytes differ
+type cachedResponse struct {
+	body []byte
+	etag string
+}
+
+func newCachedResponse(body []byte) *cachedResponse {
+	sum := sha256.Sum256(body)
+	return &cachedResponse{
+		body: body,
+		etag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// responseCache caches a successful response. Errors aren't cached, so the next request retries
+type responseCache struct {
+	lock  sync.Mutex
+	value *cachedResponse
+}
+
+func (rc *responseCache) get(encode func() ([]byte, error)) (*cachedResponse, error) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if rc.value != nil {
+		return rc.value, nil
+	}
+	body, err := encode()
+	if err != nil {
+		return nil, err
+	}
+	rc.value = newCachedResponse(body)
+	return rc.value, nil
+}
+
+// writeCachedResponse writes the response with its weak ETag, or the 304 status if the If-None-Match header matches it
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, logger *slog.Logger, response *cachedResponse) {
+	header := w.Header()
+	header.Set("ETag", "W/"+response.etag)
+	header.Set("Cache-Control", "no-cache")
+	// the compression middleware adds the header too
+	if !slices.Contains(header.Values("Vary"), "Accept-Encoding") {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), response.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set(headerContentType, contentTypeJson)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(response.body); err != nil {
+		logger.Error("failed to write response", slog.Any("error", err))
+	}
+}
+
+// etagMatches checks if the If-None-Match header matches the entity tag with the weak comparison
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
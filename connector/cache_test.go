@@ -0,0 +1,175 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// schemaCountingConnector counts the calls of GetSchema and returns a schema of the configuration version
+type schemaCountingConnector struct {
+	reloadConnector
+	calls atomic.Int32
+}
+
+func (sc *schemaCountingConnector) GetSchema(ctx context.Context, configuration *mockConfiguration, state *mockState) (schema.SchemaResponseMarshaler, error) {
+	sc.calls.Add(1)
+	result := mockSchema
+	result.Collections = []schema.CollectionInfo{
+		{
+			Name:                  fmt.Sprintf("articles_v%d", configuration.Version),
+			Arguments:             schema.CollectionInfoArguments{},
+			ForeignKeys:           schema.CollectionInfoForeignKeys{},
+			UniquenessConstraints: schema.CollectionInfoUniquenessConstraints{},
+		},
+	}
+	return result, nil
+}
+
+func TestEtagMatches(t *testing.T) {
+	testCases := []struct {
+		ifNoneMatch string
+		expected    bool
+	}{
+		{ifNoneMatch: "", expected: false},
+		{ifNoneMatch: `"abc"`, expected: true},
+		{ifNoneMatch: `W/"abc"`, expected: true},
+		{ifNoneMatch: `"xyz", "abc"`, expected: true},
+		{ifNoneMatch: `"xyz"`, expected: false},
+		{ifNoneMatch: "*", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ifNoneMatch, func(t *testing.T) {
+			if result := etagMatches(tc.ifNoneMatch, `"abc"`); result != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestServerSchemaCache(t *testing.T) {
+	getSchema := func(t *testing.T, url string, etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, url+"/schema", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		return res
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		connector := &schemaCountingConnector{
+			reloadConnector: reloadConnector{
+				closed: make(chan *mockState, 10),
+			},
+		}
+		server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+			Configuration: "{}",
+			InlineConfig:  true,
+		})
+		if err != nil {
+			t.Errorf("NewServer: expected no error, got %s", err)
+			t.FailNow()
+		}
+		httpServer := server.BuildTestServer()
+		defer httpServer.Close()
+
+		res := getSchema(t, httpServer.URL, "")
+		assertHTTPResponseStatus(t, "GET /schema", res, http.StatusOK)
+		etag := res.Header.Get("ETag")
+		if !strings.HasPrefix(etag, `W/"`) {
+			t.Errorf("expected a weak ETag, got %s", etag)
+			t.FailNow()
+		}
+		if vary := res.Header.Values("Vary"); !slices.Equal(vary, []string{"Accept-Encoding"}) {
+			t.Errorf("expected the Vary header Accept-Encoding, got %v", vary)
+		}
+
+		res = getSchema(t, httpServer.URL, etag)
+		assertHTTPResponseStatus(t, "GET /schema", res, http.StatusNotModified)
+		if calls := connector.calls.Load(); calls != 1 {
+			t.Errorf("expected the schema to be encoded once, got %d calls", calls)
+		}
+
+		if err := server.Reload(); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		res = getSchema(t, httpServer.URL, etag)
+		assertHTTPResponseStatus(t, "GET /schema", res, http.StatusOK)
+		if newEtag := res.Header.Get("ETag"); newEtag == etag {
+			t.Errorf("expected a new ETag after the reload, got %s", newEtag)
+		}
+		if calls := connector.calls.Load(); calls != 2 {
+			t.Errorf("expected the schema to be encoded again after the reload, got %d calls", calls)
+		}
+	})
+
+	t.Run("dynamic_schema", func(t *testing.T) {
+		connector := &schemaCountingConnector{}
+		server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+			Configuration: "{}",
+			InlineConfig:  true,
+		}, WithDynamicSchema())
+		if err != nil {
+			t.Errorf("NewServer: expected no error, got %s", err)
+			t.FailNow()
+		}
+		httpServer := server.BuildTestServer()
+		defer httpServer.Close()
+
+		res := getSchema(t, httpServer.URL, "")
+		assertHTTPResponseStatus(t, "GET /schema", res, http.StatusOK)
+		res = getSchema(t, httpServer.URL, res.Header.Get("ETag"))
+		assertHTTPResponseStatus(t, "GET /schema", res, http.StatusNotModified)
+		if calls := connector.calls.Load(); calls != 2 {
+			t.Errorf("expected the schema to be encoded on every request, got %d calls", calls)
+		}
+	})
+
+	t.Run("capabilities", func(t *testing.T) {
+		server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+			Configuration: "{}",
+			InlineConfig:  true,
+		})
+		if err != nil {
+			t.Errorf("NewServer: expected no error, got %s", err)
+			t.FailNow()
+		}
+		httpServer := server.BuildTestServer()
+		defer httpServer.Close()
+
+		res, err := http.Get(httpServer.URL + "/capabilities")
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponse(t, res, http.StatusOK, mockCapabilities)
+
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/capabilities", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+		res, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponseStatus(t, "GET /capabilities", res, http.StatusNotModified)
+	})
+}
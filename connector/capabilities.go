@@ -0,0 +1,318 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// CapabilityEnforcement is the mode to handle requests that use capabilities that the connector doesn't advertise
+type CapabilityEnforcement string
+
+const (
+	// CapabilityEnforcementStrict rejects requests that use unadvertised capabilities
+	CapabilityEnforcementStrict CapabilityEnforcement = "strict"
+	// CapabilityEnforcementWarn logs requests that use unadvertised capabilities and executes them
+	CapabilityEnforcementWarn CapabilityEnforcement = "warn"
+	// CapabilityEnforcementOff doesn't check the capabilities of requests
+	CapabilityEnforcementOff CapabilityEnforcement = "off"
+)
+
+// Validate checks if the enforcement mode is valid
+func (ce CapabilityEnforcement) Validate() error {
+	switch ce {
+	case "", CapabilityEnforcementStrict, CapabilityEnforcementWarn, CapabilityEnforcementOff:
+		return nil
+	default:
+		return fmt.Errorf("invalid capability enforcement %s, expected one of strict, warn, off", ce)
+	}
+}
+
+// connectorCapabilities holds the capabilities that the connector advertises
+type connectorCapabilities struct {
+	Capabilities struct {
+		Query struct {
+			Aggregates any `json:"aggregates"`
+			Variables  any `json:"variables"`
+			Explain    any `json:"explain"`
+		} `json:"query"`
+		Mutation struct {
+			Explain any `json:"explain"`
+		} `json:"mutation"`
+		Relationships *struct {
+			OrderByAggregate    any `json:"order_by_aggregate"`
+			RelationComparisons any `json:"relation_comparisons"`
+		} `json:"relationships"`
+	} `json:"capabilities"`
+}
+
+func parseConnectorCapabilities(capabilities schema.CapabilitiesResponseMarshaler) (*connectorCapabilities, error) {
+	rawCapabilities, err := capabilities.MarshalCapabilitiesJSON()
+	if err != nil {
+		return nil, err
+	}
+	var result connectorCapabilities
+	if err := json.Unmarshal(rawCapabilities, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// capabilitySupported reports whether the connector advertises the capability with the dot-separated name
+func (cc *connectorCapabilities) capabilitySupported(name string) bool {
+	relationships := cc.Capabilities.Relationships
+	switch name {
+	case "query.aggregates":
+		return cc.Capabilities.Query.Aggregates != nil
+	case "query.variables":
+		return cc.Capabilities.Query.Variables != nil
+	case "query.explain":
+		return cc.Capabilities.Query.Explain != nil
+	case "mutation.explain":
+		return cc.Capabilities.Mutation.Explain != nil
+	case "relationships":
+		return relationships != nil
+	case "relationships.order_by_aggregate":
+		return relationships != nil && relationships.OrderByAggregate != nil
+	case "relationships.relation_comparisons":
+		return relationships != nil && relationships.RelationComparisons != nil
+	default:
+		return true
+	}
+}
+
+// capabilityNotSupportedError returns the 501 error of a request that uses an unadvertised capability
+func capabilityNotSupportedError(capability string) *schema.ConnectorError {
+	return schema.NewConnectorError(http.StatusNotImplemented, fmt.Sprintf("the connector doesn't support the %s capability", capability), map[string]any{
+		"capability": capability,
+	})
+}
+
+func (s *Server[Configuration, State]) capabilityEnforcement() CapabilityEnforcement {
+	if s.options.CapabilityEnforcement == "" {
+		return CapabilityEnforcementWarn
+	}
+	return s.options.CapabilityEnforcement
+}
+
+// connectorCapabilities returns the parsed capabilities of the runtime, or nil if the connector doesn't return capabilities
+func (s *Server[Configuration, State]) connectorCapabilities(rt *serverRuntime[Configuration, State]) (*connectorCapabilities, error) {
+	rt.capabilitiesOnce.Do(func() {
+		capabilities := s.connector.GetCapabilities(rt.configuration)
+		if capabilities == nil {
+			return
+		}
+		rt.capabilities, rt.capabilitiesErr = parseConnectorCapabilities(capabilities)
+	})
+	return rt.capabilities, rt.capabilitiesErr
+}
+
+// checkCapabilities checks that the connector advertises the capabilities that a request requires.
+// In the warn mode, unadvertised capabilities are logged and the request is executed
+func (s *Server[Configuration, State]) checkCapabilities(ctx context.Context, rt *serverRuntime[Configuration, State], required []string) error {
+	enforcement := s.capabilityEnforcement()
+	if enforcement == CapabilityEnforcementOff || len(required) == 0 {
+		return nil
+	}
+	capabilities, err := s.connectorCapabilities(rt)
+	if err != nil {
+		return schema.InternalServerError("failed to parse the connector capabilities", map[string]any{
+			"cause": err.Error(),
+		})
+	}
+	if capabilities == nil {
+		return nil
+	}
+	for _, capability := range required {
+		if capabilities.capabilitySupported(capability) {
+			continue
+		}
+		if enforcement == CapabilityEnforcementWarn {
+			GetLogger(ctx).Warn("the request uses a capability that the connector doesn't advertise", slog.String("capability", capability))
+			continue
+		}
+		return capabilityNotSupportedError(capability)
+	}
+	return nil
+}
+
+func (s *Server[Configuration, State]) checkQueryCapabilities(ctx context.Context, rt *serverRuntime[Configuration, State], request *schema.QueryRequest, explain bool) error {
+	var required []string
+	if explain {
+		required = append(required, "query.explain")
+	}
+	return s.checkCapabilities(ctx, rt, append(required, queryRequestCapabilities(request)...))
+}
+
+func (s *Server[Configuration, State]) checkMutationCapabilities(ctx context.Context, rt *serverRuntime[Configuration, State], request *schema.MutationRequest, explain bool) error {
+	var required []string
+	if explain {
+		required = append(required, "mutation.explain")
+	}
+	collector := newCapabilityCollector()
+	for _, operation := range request.Operations {
+		if len(operation.Fields) > 0 {
+			collector.visitNestedField(operation.Fields)
+		}
+	}
+	return s.checkCapabilities(ctx, rt, append(required, collector.capabilities...))
+}
+
+// queryRequestCapabilities returns the capabilities that the query request requires
+func queryRequestCapabilities(request *schema.QueryRequest) []string {
+	collector := newCapabilityCollector()
+	if len(request.Variables) > 0 {
+		collector.add("query.variables")
+	}
+	collector.visitQuery(&request.Query)
+	return collector.capabilities
+}
+
+// capabilityCollector collects the unique capabilities that the nodes of a request use, in the order they are found
+type capabilityCollector struct {
+	capabilities []string
+	seen         map[string]bool
+}
+
+func newCapabilityCollector() *capabilityCollector {
+	return &capabilityCollector{
+		seen: make(map[string]bool),
+	}
+}
+
+func (cc *capabilityCollector) add(capability string) {
+	if cc.seen[capability] {
+		return
+	}
+	cc.seen[capability] = true
+	cc.capabilities = append(cc.capabilities, capability)
+}
+
+func (cc *capabilityCollector) visitQuery(query *schema.Query) {
+	if len(query.Aggregates) > 0 {
+		cc.add("query.aggregates")
+	}
+	for _, field := range query.Fields {
+		cc.visitField(field)
+	}
+	if query.OrderBy != nil {
+		for _, element := range query.OrderBy.Elements {
+			cc.visitOrderByTarget(element.Target)
+		}
+	}
+	cc.visitExpression(query.Predicate)
+}
+
+func (cc *capabilityCollector) visitField(field schema.Field) {
+	value, err := field.InterfaceT()
+	if err != nil {
+		return
+	}
+	switch f := value.(type) {
+	case *schema.ColumnField:
+		if len(f.Fields) > 0 {
+			cc.visitNestedField(f.Fields)
+		}
+	case *schema.RelationshipField:
+		cc.add("relationships")
+		cc.visitQuery(&f.Query)
+	}
+}
+
+func (cc *capabilityCollector) visitNestedField(nestedField schema.NestedField) {
+	value, err := nestedField.InterfaceT()
+	if err != nil {
+		return
+	}
+	switch nf := value.(type) {
+	case *schema.NestedObject:
+		for _, field := range nf.Fields {
+			cc.visitField(field)
+		}
+	case *schema.NestedArray:
+		cc.visitNestedField(nf.Fields)
+	}
+}
+
+func (cc *capabilityCollector) visitOrderByTarget(target schema.OrderByTarget) {
+	value, err := target.InterfaceT()
+	if err != nil {
+		return
+	}
+	var path []schema.PathElement
+	switch t := value.(type) {
+	case *schema.OrderByColumn:
+		path = t.Path
+	case *schema.OrderBySingleColumnAggregate:
+		path = t.Path
+		if len(path) > 0 {
+			cc.add("relationships.order_by_aggregate")
+		}
+	case *schema.OrderByStarCountAggregate:
+		path = t.Path
+		if len(path) > 0 {
+			cc.add("relationships.order_by_aggregate")
+		}
+	}
+	cc.visitPath(path)
+}
+
+func (cc *capabilityCollector) visitPath(path []schema.PathElement) {
+	if len(path) == 0 {
+		return
+	}
+	cc.add("relationships")
+	for _, element := range path {
+		cc.visitExpression(element.Predicate)
+	}
+}
+
+func (cc *capabilityCollector) visitExpression(expression schema.Expression) {
+	if len(expression) == 0 {
+		return
+	}
+	value, err := expression.InterfaceT()
+	if err != nil {
+		return
+	}
+	switch expr := value.(type) {
+	case *schema.ExpressionAnd:
+		for _, item := range expr.Expressions {
+			cc.visitExpression(item)
+		}
+	case *schema.ExpressionOr:
+		for _, item := range expr.Expressions {
+			cc.visitExpression(item)
+		}
+	case *schema.ExpressionNot:
+		cc.visitExpression(expr.Expression)
+	case *schema.ExpressionUnaryComparisonOperator:
+		cc.visitComparisonTarget(expr.Column)
+	case *schema.ExpressionBinaryComparisonOperator:
+		cc.visitComparisonTarget(expr.Column)
+		if comparisonValue, err := expr.Value.InterfaceT(); err == nil {
+			if column, ok := comparisonValue.(*schema.ComparisonValueColumn); ok {
+				cc.visitComparisonTarget(column.Column)
+			}
+		}
+	case *schema.ExpressionExists:
+		if inCollection, err := expr.InCollection.InterfaceT(); err == nil {
+			if _, ok := inCollection.(*schema.ExistsInCollectionRelated); ok {
+				cc.add("relationships")
+			}
+		}
+		cc.visitExpression(expr.Predicate)
+	}
+}
+
+func (cc *capabilityCollector) visitComparisonTarget(target schema.ComparisonTarget) {
+	if len(target.Path) == 0 {
+		return
+	}
+	cc.add("relationships.relation_comparisons")
+	cc.visitPath(target.Path)
+}
@@ -0,0 +1,182 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// minimalCapabilitiesConnector advertises no optional capabilities
+type minimalCapabilitiesConnector struct {
+	mockConnector
+}
+
+func (mc *minimalCapabilitiesConnector) GetCapabilities(configuration *mockConfiguration) schema.CapabilitiesResponseMarshaler {
+	return schema.CapabilitiesResponse{
+		Version: "^0.1.0",
+		Capabilities: schema.Capabilities{
+			Query: schema.QueryCapabilities{
+				Aggregates: schema.LeafCapability{},
+			},
+		},
+	}
+}
+
+func TestQueryRequestCapabilities(t *testing.T) {
+	testCases := []struct {
+		name     string
+		request  string
+		expected []string
+	}{
+		{
+			name:     "none",
+			request:  `{ "collection": "articles", "arguments": {}, "collection_relationships": {}, "query": { "fields": { "id": { "type": "column", "column": "id" } } } }`,
+			expected: nil,
+		},
+		{
+			name:     "variables_and_aggregates",
+			request:  `{ "collection": "articles", "arguments": {}, "collection_relationships": {}, "variables": [{ "id": 1 }], "query": { "aggregates": { "count": { "type": "star_count" } } } }`,
+			expected: []string{"query.variables", "query.aggregates"},
+		},
+		{
+			name:     "relationship_field",
+			request:  `{ "collection": "articles", "arguments": {}, "collection_relationships": {}, "query": { "fields": { "author": { "type": "relationship", "relationship": "article_author", "arguments": {}, "query": { "aggregates": { "count": { "type": "star_count" } } } } } } }`,
+			expected: []string{"relationships", "query.aggregates"},
+		},
+		{
+			name:     "order_by_aggregate",
+			request:  `{ "collection": "authors", "arguments": {}, "collection_relationships": {}, "query": { "order_by": { "elements": [{ "order_direction": "desc", "target": { "type": "star_count_aggregate", "path": [{ "relationship": "author_articles", "arguments": {} }] } }] } } }`,
+			expected: []string{"relationships.order_by_aggregate", "relationships"},
+		},
+		{
+			name:     "relation_comparisons",
+			request:  `{ "collection": "articles", "arguments": {}, "collection_relationships": {}, "query": { "predicate": { "type": "not", "expression": { "type": "unary_comparison_operator", "operator": "is_null", "column": { "type": "column", "name": "name", "path": [{ "relationship": "article_author", "arguments": {} }] } } } } }`,
+			expected: []string{"relationships.relation_comparisons", "relationships"},
+		},
+		{
+			name:     "exists_related",
+			request:  `{ "collection": "articles", "arguments": {}, "collection_relationships": {}, "query": { "predicate": { "type": "exists", "in_collection": { "type": "related", "relationship": "article_author", "arguments": {} } } } }`,
+			expected: []string{"relationships"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var request schema.QueryRequest
+			if err := json.Unmarshal([]byte(tc.request), &request); err != nil {
+				t.Fatal(err)
+			}
+			result := queryRequestCapabilities(&request)
+			if !internal.DeepEqual(tc.expected, result) {
+				t.Errorf("expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestServerCapabilityEnforcement(t *testing.T) {
+	queryRequest := schema.QueryRequest{
+		Collection:              "articles",
+		Arguments:               schema.QueryRequestArguments{},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables: []schema.QueryRequestVariablesElem{
+			{"id": 1},
+		},
+	}
+
+	testCases := []struct {
+		enforcement CapabilityEnforcement
+		path        string
+		body        any
+		status      int
+		response    any
+	}{
+		{
+			path:   "/query",
+			body:   queryRequest,
+			status: http.StatusOK,
+		},
+		{
+			enforcement: CapabilityEnforcementStrict,
+			path:        "/query",
+			body:        queryRequest,
+			status:      http.StatusNotImplemented,
+			response: schema.ErrorResponse{
+				Message: "the connector doesn't support the query.variables capability",
+				Details: map[string]any{
+					"capability": "query.variables",
+				},
+			},
+		},
+		{
+			enforcement: CapabilityEnforcementStrict,
+			path:        "/query/explain",
+			body:        queryRequest,
+			status:      http.StatusNotImplemented,
+			response: schema.ErrorResponse{
+				Message: "the connector doesn't support the query.explain capability",
+				Details: map[string]any{
+					"capability": "query.explain",
+				},
+			},
+		},
+		{
+			enforcement: CapabilityEnforcementWarn,
+			path:        "/query",
+			body:        queryRequest,
+			status:      http.StatusOK,
+		},
+		{
+			enforcement: CapabilityEnforcementOff,
+			path:        "/mutation/explain",
+			body: schema.MutationRequest{
+				Operations:              []schema.MutationOperation{},
+				CollectionRelationships: schema.MutationRequestCollectionRelationships{},
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s%s", tc.enforcement, tc.path), func(t *testing.T) {
+			server, err := NewServer[mockConfiguration, mockState](&minimalCapabilitiesConnector{}, &ServerOptions{
+				Configuration:         "{}",
+				InlineConfig:          true,
+				CapabilityEnforcement: tc.enforcement,
+			})
+			if err != nil {
+				t.Errorf("NewServer: expected no error, got %s", err)
+				t.FailNow()
+			}
+			httpServer := server.BuildTestServer()
+			defer httpServer.Close()
+
+			res, err := httpPostJSON(httpServer.URL+tc.path, tc.body)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if tc.response == nil {
+				assertHTTPResponseStatus(t, tc.path, res, tc.status)
+			} else {
+				assertHTTPResponse(t, res, tc.status, tc.response)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewServer[mockConfiguration, mockState](&minimalCapabilitiesConnector{}, &ServerOptions{
+			Configuration:         "{}",
+			InlineConfig:          true,
+			CapabilityEnforcement: "block",
+		})
+		if err == nil || err.Error() != "invalid capability enforcement block, expected one of strict, warn, off" {
+			t.Errorf("expected invalid capability enforcement error, got %v", err)
+		}
+	})
+}
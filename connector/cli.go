@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
 )
@@ -13,10 +14,40 @@ import (
 // ServeCommandArguments contains argument flags of the serve command
 type ServeCommandArguments struct {
 	OTLPConfig
+	TLSConfig
 
-	Configuration      string `help:"Configuration directory." env:"HASURA_CONFIGURATION_DIRECTORY"`
-	Port               uint   `help:"Serve Port." env:"HASURA_CONNECTOR_PORT" default:"8080"`
-	ServiceTokenSecret string `help:"Service token secret." env:"HASURA_SERVICE_TOKEN_SECRET"`
+	Configuration              string        `help:"Configuration directory." env:"HASURA_CONFIGURATION_DIRECTORY"`
+	Port                       uint          `help:"Serve Port." env:"HASURA_CONNECTOR_PORT" default:"8080"`
+	ServiceTokenSecret         string        `help:"Service token secret." env:"HASURA_SERVICE_TOKEN_SECRET"`
+	ServiceTokenSecrets        []string      `help:"Additional accepted service token secrets, to rotate the secret without downtime." env:"HASURA_SERVICE_TOKEN_SECRETS"`
+	ServiceTokenFile           string        `help:"Path of a file with accepted service tokens, one per line. The file is read again when it changes." env:"HASURA_SERVICE_TOKEN_FILE"`
+	JWKSFile                   string        `help:"Path of a JSON Web Key Set file to validate HS256 and RS256 JSON Web Tokens." env:"HASURA_JWKS_FILE"`
+	JWTIssuer                  string        `help:"Expected issuer of JSON Web Tokens." env:"HASURA_JWT_ISSUER"`
+	JWTAudience                string        `help:"Expected audience of JSON Web Tokens." env:"HASURA_JWT_AUDIENCE"`
+	AdminTokenSecret           string        `help:"Admin token secret. Admin endpoints are disabled if empty." env:"HASURA_ADMIN_TOKEN_SECRET"`
+	AdminPort                  uint          `help:"Port of the admin listener that serves the log level, build info and pprof endpoints. The listener is disabled if empty, and requires the admin token secret." env:"HASURA_ADMIN_PORT"`
+	DrainTimeout               time.Duration `help:"Maximum duration to drain in-flight requests and close the connector state on shutdown." env:"HASURA_DRAIN_TIMEOUT" default:"30s"`
+	ShutdownDelay              time.Duration `help:"Duration to keep serving requests with the failing health check before draining on shutdown." env:"HASURA_SHUTDOWN_DELAY" default:"0s"`
+	ConfigurationWatchInterval time.Duration `help:"Interval to poll the configuration directory and reload the connector on changes. Disabled if zero." env:"HASURA_CONFIGURATION_WATCH_INTERVAL" default:"0s"`
+	QueryConcurrencyLimit      int           `help:"Maximum number of queries of every tenant that execute at the same time. Unlimited if zero." env:"HASURA_QUERY_CONCURRENCY_LIMIT" default:"0"`
+	QueryQueueSize             int           `help:"Maximum number of queries that wait for an execution slot when the concurrency limit is reached." env:"HASURA_QUERY_QUEUE_SIZE" default:"0"`
+	MutationConcurrencyLimit   int           `help:"Maximum number of mutations of every tenant that execute at the same time. Unlimited if zero." env:"HASURA_MUTATION_CONCURRENCY_LIMIT" default:"0"`
+	MutationQueueSize          int           `help:"Maximum number of mutations that wait for an execution slot when the concurrency limit is reached." env:"HASURA_MUTATION_QUEUE_SIZE" default:"0"`
+	MaxRequestBodySize         int64         `help:"Maximum size in bytes of request bodies. Unlimited if negative." env:"HASURA_MAX_REQUEST_BODY_SIZE" default:"10485760"`
+	MaxJSONDepth               int           `help:"Maximum nesting depth of JSON request bodies." env:"HASURA_MAX_JSON_DEPTH" default:"64"`
+	DebugBodyLogSize           int           `help:"Maximum size in bytes of request and response bodies in debug logs." env:"HASURA_DEBUG_BODY_LOG_SIZE" default:"4096"`
+	DisableCompression         bool          `help:"Disable the gzip and zstd compression of responses." env:"HASURA_DISABLE_COMPRESSION"`
+	CompressionMinSize         int           `help:"Minimum size in bytes of compressed responses." env:"HASURA_COMPRESSION_MIN_SIZE" default:"1024"`
+	QueueTimeout               time.Duration `help:"Maximum duration that a request waits for an execution slot." env:"HASURA_QUEUE_TIMEOUT" default:"10s"`
+	HealthCheckInterval        time.Duration `help:"Interval of the background health checks that drive the readiness endpoint." env:"HASURA_HEALTH_CHECK_INTERVAL" default:"10s"`
+	HealthCheckTimeout         time.Duration `help:"Maximum duration of a background health check." env:"HASURA_HEALTH_CHECK_TIMEOUT" default:"5s"`
+	TenantsFile                string        `help:"Path of a JSON file that lists the tenants to serve, with the name, the configuration directory and the service token secret of each one." env:"HASURA_TENANTS_FILE"`
+	TenantHeader               string        `help:"Request header that selects the tenant." env:"HASURA_TENANT_HEADER" default:"X-Hasura-Tenant"`
+	MetricsOperationAllowlist  []string      `help:"Collection, function and procedure names that are attached to metrics. Other names are attached as other. All names are attached if empty." env:"HASURA_METRICS_OPERATION_ALLOWLIST"`
+	CapabilityEnforcement      string        `help:"Handling of requests that use capabilities that the connector doesn't advertise." env:"HASURA_CAPABILITY_ENFORCEMENT" enum:"strict,warn,off" default:"warn"`
+	RedactHeaders              []string      `help:"Headers that are redacted in debug logs and span attributes, in addition to Authorization, Cookie and other credential headers." env:"HASURA_REDACT_HEADERS"`
+	RedactBodyPaths            []string      `help:"JSON paths of request and response body fields that are redacted in debug logs and span attributes, e.g. $.arguments.* or $..value." env:"HASURA_REDACT_BODY_PATHS"`
+	RedactHash                 bool          `help:"Replace redacted values with their SHA-256 hashes instead of a fixed mask." env:"HASURA_REDACT_HASH"`
 }
 
 // ServeCLI is used for CLI argument binding
@@ -47,7 +78,7 @@ type ConnectorCLI interface {
 // This should be the entrypoint of your connector
 func Start[Configuration any, State any](connector Connector[Configuration, State], options ...ServeOption) error {
 	var cli ServeCLI
-	return StartCustom[Configuration, State](&cli, connector)
+	return StartCustom[Configuration, State](&cli, connector, options...)
 }
 
 // Starts the connector with custom CLI.
@@ -64,11 +95,50 @@ func StartCustom[Configuration any, State any](cli ConnectorCLI, connector Conne
 	}
 	switch command {
 	case "serve":
+		var tenants []Tenant
+		if serveCLI.Serve.TenantsFile != "" {
+			tenants, err = LoadTenantsFile(serveCLI.Serve.TenantsFile)
+			if err != nil {
+				return err
+			}
+		}
 		server, err := NewServer[Configuration, State](connector, &ServerOptions{
-			Configuration:      serveCLI.Serve.Configuration,
-			ServiceTokenSecret: serveCLI.Serve.ServiceTokenSecret,
-			OTLPConfig:         serveCLI.Serve.OTLPConfig,
-		}, append([]ServeOption{WithLogger(logger), withLogLevel(logLevel)}, options...)...)
+			Configuration:              serveCLI.Serve.Configuration,
+			ServiceTokenSecret:         serveCLI.Serve.ServiceTokenSecret,
+			ServiceTokenSecrets:        serveCLI.Serve.ServiceTokenSecrets,
+			ServiceTokenFile:           serveCLI.Serve.ServiceTokenFile,
+			JWKSFile:                   serveCLI.Serve.JWKSFile,
+			JWTIssuer:                  serveCLI.Serve.JWTIssuer,
+			JWTAudience:                serveCLI.Serve.JWTAudience,
+			AdminTokenSecret:           serveCLI.Serve.AdminTokenSecret,
+			AdminPort:                  serveCLI.Serve.AdminPort,
+			DrainTimeout:               serveCLI.Serve.DrainTimeout,
+			ShutdownDelay:              serveCLI.Serve.ShutdownDelay,
+			ConfigurationWatchInterval: serveCLI.Serve.ConfigurationWatchInterval,
+			OTLPConfig:                 serveCLI.Serve.OTLPConfig,
+			QueryConcurrencyLimit:      serveCLI.Serve.QueryConcurrencyLimit,
+			QueryQueueSize:             serveCLI.Serve.QueryQueueSize,
+			MutationConcurrencyLimit:   serveCLI.Serve.MutationConcurrencyLimit,
+			MutationQueueSize:          serveCLI.Serve.MutationQueueSize,
+			QueueTimeout:               serveCLI.Serve.QueueTimeout,
+			MaxRequestBodySize:         serveCLI.Serve.MaxRequestBodySize,
+			MaxJSONDepth:               serveCLI.Serve.MaxJSONDepth,
+			DebugBodyLogSize:           serveCLI.Serve.DebugBodyLogSize,
+			DisableCompression:         serveCLI.Serve.DisableCompression,
+			CompressionMinSize:         serveCLI.Serve.CompressionMinSize,
+			TLSConfig:                  serveCLI.Serve.TLSConfig,
+			CapabilityEnforcement:      CapabilityEnforcement(serveCLI.Serve.CapabilityEnforcement),
+			HealthCheckInterval:        serveCLI.Serve.HealthCheckInterval,
+			HealthCheckTimeout:         serveCLI.Serve.HealthCheckTimeout,
+			Tenants:                    tenants,
+			TenantHeader:               serveCLI.Serve.TenantHeader,
+			MetricsOperationAllowlist:  serveCLI.Serve.MetricsOperationAllowlist,
+			Redaction: RedactionPolicy{
+				Headers:   serveCLI.Serve.RedactHeaders,
+				BodyPaths: serveCLI.Serve.RedactBodyPaths,
+				Hash:      serveCLI.Serve.RedactHash,
+			},
+		}, append([]ServeOption{WithLogger(logger), withLogLevel(logLevel.Level()), WithLogLevelVar(logLevel)}, options...)...)
 		if err != nil {
 			return err
 		}
@@ -79,17 +149,20 @@ func StartCustom[Configuration any, State any](cli ConnectorCLI, connector Conne
 	}
 }
 
-func initLogger(logLevel string) (*slog.Logger, slog.Level, error) {
+func initLogger(logLevel string) (*slog.Logger, *slog.LevelVar, error) {
 	var level slog.Level
 	err := level.UnmarshalText([]byte(strings.ToUpper(logLevel)))
 	if err != nil {
-		return nil, level, err
+		return nil, nil, err
 	}
 
+	// the level variable can be changed by the admin listener without a restart
+	levelVar := &slog.LevelVar{}
+	levelVar.Set(level)
 	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		Level: level,
+		Level: levelVar,
 	}))
 	slog.SetDefault(logger)
 
-	return logger, level, nil
+	return logger, levelVar, nil
 }
@@ -0,0 +1,57 @@
+package connector
+
+import (
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStartServeOptions(t *testing.T) {
+	args := os.Args
+	defer func() {
+		os.Args = args
+	}()
+	os.Args = []string{"connector", "serve", "--port", "18082", "--configuration", "testdata"}
+
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Start-Middleware", "true")
+			next.ServeHTTP(w, r)
+		})
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- Start[mockConfiguration, mockState](&mockConnector{}, WithMiddleware(middleware))
+	}()
+
+	var res *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if res, err = http.Get("http://localhost:18082/health"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "GET /health", res, http.StatusOK)
+	if value := res.Header.Get("X-Start-Middleware"); value != "true" {
+		t.Errorf("expected the middleware of the serve options to run, got header %q", value)
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the server to shut down")
+	}
+}
@@ -0,0 +1,220 @@
+package connector
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	encodingGzip              = "gzip"
+	encodingZstd              = "zstd"
+	defaultCompressionMinSize = 1024
+)
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() any {
+			return gzip.NewWriter(io.Discard)
+		},
+	}
+	zstdEncoderPool = sync.Pool{
+		New: func() any {
+			encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return encoder
+		},
+	}
+)
+
+// compressionEncoder is the common interface of gzip and zstd writers
+type compressionEncoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// negotiateEncoding selects the preferred response encoding that the Accept-Encoding header accepts,
+// zstd before gzip, or an empty string if the response should not be compressed
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = quality
+	}
+
+	var result string
+	var resultQuality float64
+	for _, encoding := range []string{encodingZstd, encodingGzip} {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > resultQuality {
+			result, resultQuality = encoding, quality
+		}
+	}
+	return result
+}
+
+// compressResponseWriter compresses the response body once it reaches the minimum size.
+// Smaller responses are buffered and written uncompressed when the writer is closed
+type compressResponseWriter struct {
+	http.ResponseWriter
+	ctx        context.Context
+	telemetry  *TelemetryState
+	attributes metric.MeasurementOption
+	encoding   string
+	minSize    int
+	statusCode int
+	buffer     []byte
+	encoder    compressionEncoder
+	// counts the bytes that are written to the underlying writer
+	counter *countingWriter
+	// the response is written without compression
+	passthrough  bool
+	wroteHeader  bool
+	uncompressed int64
+}
+
+func newCompressResponseWriter(w http.ResponseWriter, r *http.Request, telemetry *TelemetryState, encoding string, minSize int) *compressResponseWriter {
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressResponseWriter{
+		ResponseWriter: w,
+		ctx:            r.Context(),
+		telemetry:      telemetry,
+		attributes: metric.WithAttributes(
+			attribute.String("endpoint", r.URL.Path),
+			attribute.String("encoding", encoding),
+		),
+		encoding:   encoding,
+		minSize:    minSize,
+		statusCode: http.StatusOK,
+	}
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.statusCode = statusCode
+	// responses without body or with an encoding of the handler aren't compressed
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || cw.Header().Get("Content-Encoding") != "" {
+		cw.passthrough = true
+		cw.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (cw *compressResponseWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(data)
+	}
+	cw.uncompressed += int64(len(data))
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+
+	cw.buffer = append(cw.buffer, data...)
+	if len(cw.buffer) < cw.minSize {
+		return len(data), nil
+	}
+	if err := cw.startCompression(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (cw *compressResponseWriter) startCompression() error {
+	header := cw.Header()
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	cw.counter = &countingWriter{writer: cw.ResponseWriter}
+	switch cw.encoding {
+	case encodingZstd:
+		cw.encoder = zstdEncoderPool.Get().(*zstd.Encoder)
+	default:
+		cw.encoder = gzipWriterPool.Get().(*gzip.Writer)
+	}
+	cw.encoder.Reset(cw.counter)
+
+	_, err := cw.encoder.Write(cw.buffer)
+	cw.buffer = nil
+	return err
+}
+
+// Close flushes the compressed stream, or writes the buffered response uncompressed if it is below the minimum size
+func (cw *compressResponseWriter) Close() error {
+	if cw.passthrough {
+		return nil
+	}
+	if cw.encoder == nil {
+		if !cw.wroteHeader {
+			return nil
+		}
+		cw.ResponseWriter.WriteHeader(cw.statusCode)
+		if len(cw.buffer) == 0 {
+			return nil
+		}
+		_, err := cw.ResponseWriter.Write(cw.buffer)
+		return err
+	}
+
+	err := cw.encoder.Close()
+	// release the response writer before the encoder returns to the pool
+	cw.encoder.Reset(io.Discard)
+	switch encoder := cw.encoder.(type) {
+	case *zstd.Encoder:
+		zstdEncoderPool.Put(encoder)
+	case *gzip.Writer:
+		gzipWriterPool.Put(encoder)
+	}
+	cw.encoder = nil
+
+	cw.telemetry.uncompressedResponseBytes.Add(cw.ctx, cw.uncompressed, cw.attributes, tenantAttributes(cw.ctx))
+	cw.telemetry.compressedResponseBytes.Add(cw.ctx, cw.counter.size, cw.attributes, tenantAttributes(cw.ctx))
+	return err
+}
+
+// countingWriter counts the bytes that are written to the writer
+type countingWriter struct {
+	writer io.Writer
+	size   int64
+}
+
+func (cw *countingWriter) Write(data []byte) (int, error) {
+	n, err := cw.writer.Write(data)
+	cw.size += int64(n)
+	return n, err
+}
+
+// gzipRequestBody decompresses a gzip request body and closes both the reader and the original body
+type gzipRequestBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (gb *gzipRequestBody) Close() error {
+	_ = gb.Reader.Close()
+	return gb.body.Close()
+}
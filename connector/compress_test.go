@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{acceptEncoding: "", expected: ""},
+		{acceptEncoding: "identity", expected: ""},
+		{acceptEncoding: "gzip, deflate, br", expected: "gzip"},
+		{acceptEncoding: "gzip, zstd", expected: "zstd"},
+		{acceptEncoding: "zstd;q=0.5, gzip;q=0.8", expected: "gzip"},
+		{acceptEncoding: "gzip;q=0, *", expected: "zstd"},
+		{acceptEncoding: "*;q=0", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.acceptEncoding, func(t *testing.T) {
+			if result := negotiateEncoding(tc.acceptEncoding); result != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestServerCompression(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration:      "{}",
+		InlineConfig:       true,
+		CompressionMinSize: 200,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	expectedSchema, err := json.Marshal(mockSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getSchema := func(acceptEncoding string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/schema", httpServer.URL), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// setting the header explicitly disables the transparent decompression of the client
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, body
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		res, body := getSchema("gzip")
+		if encoding := res.Header.Get("Content-Encoding"); encoding != "gzip" {
+			t.Errorf("expected gzip encoding, got %s", encoding)
+			t.FailNow()
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, expectedSchema) {
+			t.Errorf("expected %s, got %s", expectedSchema, decoded)
+		}
+	})
+
+	t.Run("zstd", func(t *testing.T) {
+		res, body := getSchema("gzip, zstd")
+		if encoding := res.Header.Get("Content-Encoding"); encoding != "zstd" {
+			t.Errorf("expected zstd encoding, got %s", encoding)
+			t.FailNow()
+		}
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer decoder.Close()
+		decoded, err := decoder.DecodeAll(body, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, expectedSchema) {
+			t.Errorf("expected %s, got %s", expectedSchema, decoded)
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		res, body := getSchema("identity")
+		if encoding := res.Header.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("expected no encoding, got %s", encoding)
+		}
+		if !bytes.Equal(body, expectedSchema) {
+			t.Errorf("expected %s, got %s", expectedSchema, body)
+		}
+	})
+
+	t.Run("below_min_size", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/capabilities", httpServer.URL), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		if encoding := res.Header.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("expected no encoding, got %s", encoding)
+		}
+		assertHTTPResponse(t, res, http.StatusOK, mockCapabilities)
+	})
+
+	postQuery := func(contentEncoding string, body []byte) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/query", httpServer.URL), bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", contentEncoding)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		return res
+	}
+	queryBody, err := json.Marshal(schema.QueryRequest{
+		Collection:              "articles",
+		Arguments:               schema.QueryRequestArguments{},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables:               []schema.QueryRequestVariablesElem{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("gzip_request_body", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, _ = writer.Write(queryBody)
+		_ = writer.Close()
+
+		res := postQuery("gzip", buf.Bytes())
+		assertHTTPResponseStatus(t, "POST /query", res, http.StatusOK)
+	})
+
+	t.Run("unsupported_request_encoding", func(t *testing.T) {
+		res := postQuery("br", queryBody)
+		assertHTTPResponse(t, res, http.StatusUnsupportedMediaType, schema.ErrorResponse{
+			Message: "unsupported content encoding br, accept gzip only",
+			Details: map[string]any{},
+		})
+	})
+}
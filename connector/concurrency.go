@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const defaultQueueTimeout = 10 * time.Second
+
+// ConcurrencyLimit bounds the number of requests of a route that execute at the same time
+type ConcurrencyLimit struct {
+	// MaxConcurrency is the maximum number of requests that execute at the same time. The route is unlimited if it is zero
+	MaxConcurrency int
+	// MaxQueue is the maximum number of requests that wait for an execution slot.
+	// Requests are rejected when all slots are busy if it is zero
+	MaxQueue int
+	// QueueTimeout is the maximum duration that a request waits in the queue. The default is 10 seconds
+	QueueTimeout time.Duration
+}
+
+// WithConcurrencyLimit sets the concurrency limit of the route of the path, for example /query or /mutation.
+// It replaces the limit of the server options for that route
+func WithConcurrencyLimit(path string, limit ConcurrencyLimit) ServeOption {
+	return func(so *serveOptions) {
+		if so.concurrencyLimits == nil {
+			so.concurrencyLimits = make(map[string]ConcurrencyLimit)
+		}
+		so.concurrencyLimits[path] = limit
+	}
+}
+
+// concurrencyLimiter admits requests into a fixed number of execution slots, queues a bounded number
+// of waiting requests and sheds the rest
+type concurrencyLimiter struct {
+	limit   ConcurrencyLimit
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+func newConcurrencyLimiter(limit ConcurrencyLimit) *concurrencyLimiter {
+	if limit.QueueTimeout <= 0 {
+		limit.QueueTimeout = defaultQueueTimeout
+	}
+	return &concurrencyLimiter{
+		limit: limit,
+		slots: make(chan struct{}, limit.MaxConcurrency),
+	}
+}
+
+// acquire takes an execution slot, waiting in the queue if all slots are busy.
+// It returns false if the queue is full, the queue timeout expires or the context is canceled
+func (cl *concurrencyLimiter) acquire(ctx context.Context) bool {
+	select {
+	case cl.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if cl.waiting.Add(1) > int64(cl.limit.MaxQueue) {
+		cl.waiting.Add(-1)
+		return false
+	}
+	defer cl.waiting.Add(-1)
+
+	timer := time.NewTimer(cl.limit.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case cl.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (cl *concurrencyLimiter) release() {
+	<-cl.slots
+}
+
+// retryAfter is the number of seconds that rejected clients are asked to wait before retrying
+func (cl *concurrencyLimiter) retryAfter() string {
+	return strconv.Itoa(int(math.Ceil(cl.limit.QueueTimeout.Seconds())))
+}
+
+// concurrencyLimit returns the concurrency limit of the route of the path
+func (s *Server[Configuration, State]) concurrencyLimit(path string) ConcurrencyLimit {
+	if limit, ok := s.concurrencyLimits[path]; ok {
+		return limit
+	}
+	switch path {
+	case "/query":
+		return ConcurrencyLimit{
+			MaxConcurrency: s.options.QueryConcurrencyLimit,
+			MaxQueue:       s.options.QueryQueueSize,
+			QueueTimeout:   s.options.QueueTimeout,
+		}
+	case "/mutation":
+		return ConcurrencyLimit{
+			MaxConcurrency: s.options.MutationConcurrencyLimit,
+			MaxQueue:       s.options.MutationQueueSize,
+			QueueTimeout:   s.options.QueueTimeout,
+		}
+	default:
+		return ConcurrencyLimit{}
+	}
+}
+
+// withConcurrencyLimit sheds the requests of the path that exceed its concurrency limit
+// with a 503 response and a Retry-After header. Every tenant has its own slots, so a tenant can't starve the others.
+// The handler must run after the authentication, so unauthorized requests don't take slots
+func (s *Server[Configuration, State]) withConcurrencyLimit(path string, handler http.HandlerFunc) http.HandlerFunc {
+	limit := s.concurrencyLimit(path)
+	if limit.MaxConcurrency <= 0 {
+		return handler
+	}
+	// the limiters of tenants, keyed by the tenant name
+	var limiters sync.Map
+	endpointAttr := metric.WithAttributes(attribute.String("endpoint", path))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := tenantName(r.Context())
+		value, ok := limiters.Load(name)
+		if !ok {
+			value, _ = limiters.LoadOrStore(name, newConcurrencyLimiter(limit))
+		}
+		limiter := value.(*concurrencyLimiter)
+
+		startTime := time.Now()
+		acquired := limiter.acquire(r.Context())
+		s.telemetry.queueLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), endpointAttr, tenantAttributes(r.Context()))
+		if !acquired {
+			s.telemetry.rejectedRequestsCounter.Add(r.Context(), 1, endpointAttr, tenantAttributes(r.Context()))
+			w.Header().Set("Retry-After", limiter.retryAfter())
+			writeJson(w, GetLogger(r.Context()), http.StatusServiceUnavailable, schema.ErrorResponse{
+				Message: "the server is overloaded",
+				Details: map[string]any{
+					"max_concurrency": limit.MaxConcurrency,
+					"max_queue":       limit.MaxQueue,
+				},
+			})
+			return
+		}
+		defer limiter.release()
+		handler(w, r)
+	}
+}
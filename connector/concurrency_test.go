@@ -0,0 +1,202 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// blockingConnector blocks queries until the unblock channel is closed
+type blockingConnector struct {
+	mockConnector
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (bc *blockingConnector) Query(ctx context.Context, configuration *mockConfiguration, state *mockState, request *schema.QueryRequest) (schema.QueryResponse, error) {
+	bc.started <- struct{}{}
+	<-bc.unblock
+	return bc.mockConnector.Query(ctx, configuration, state, request)
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimit{
+		MaxConcurrency: 1,
+		MaxQueue:       1,
+		QueueTimeout:   time.Second,
+	})
+	if !limiter.acquire(context.Background()) {
+		t.Error("expected the first request to acquire a slot")
+		t.FailNow()
+	}
+
+	queued := make(chan bool)
+	go func() {
+		queued <- limiter.acquire(context.Background())
+	}()
+	// wait until the second request is queued
+	for limiter.waiting.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if limiter.acquire(context.Background()) {
+		t.Error("expected the request to be rejected when the queue is full")
+	}
+
+	limiter.release()
+	if !<-queued {
+		t.Error("expected the queued request to acquire the released slot")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if limiter.acquire(ctx) {
+		t.Error("expected the request to be rejected when the context is canceled")
+	}
+}
+
+func TestServerConcurrencyLimit(t *testing.T) {
+	connector := &blockingConnector{
+		started: make(chan struct{}, 10),
+		unblock: make(chan struct{}),
+	}
+	server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	}, WithConcurrencyLimit("/query", ConcurrencyLimit{
+		MaxConcurrency: 1,
+		MaxQueue:       1,
+		QueueTimeout:   50 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	postQuery := func() (*http.Response, error) {
+		return httpPostJSON(fmt.Sprintf("%s/query", httpServer.URL), schema.QueryRequest{
+			Collection:              "articles",
+			Arguments:               schema.QueryRequestArguments{},
+			CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+			Query:                   schema.Query{},
+			Variables:               []schema.QueryRequestVariablesElem{},
+		})
+	}
+	postQueryAsync := func() chan *http.Response {
+		result := make(chan *http.Response, 1)
+		go func() {
+			res, err := postQuery()
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+			result <- res
+		}()
+		return result
+	}
+
+	executing := postQueryAsync()
+	<-connector.started
+
+	// one request waits in the queue until it times out because the slot is still busy,
+	// the other one is rejected immediately because the queue is full
+	for _, result := range []chan *http.Response{postQueryAsync(), postQueryAsync()} {
+		res := <-result
+		if retryAfter := res.Header.Get("Retry-After"); retryAfter != "1" {
+			t.Errorf("expected Retry-After 1, got %s", retryAfter)
+		}
+		assertHTTPResponse(t, res, http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the server is overloaded",
+			Details: map[string]any{
+				"max_concurrency": float64(1),
+				"max_queue":       float64(1),
+			},
+		})
+	}
+
+	close(connector.unblock)
+	assertHTTPResponseStatus(t, "executing", <-executing, http.StatusOK)
+
+	res, err := postQuery()
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "after_release", res, http.StatusOK)
+}
+
+func TestServerConcurrencyLimitTenants(t *testing.T) {
+	connector := &blockingConnector{
+		started: make(chan struct{}, 10),
+		unblock: make(chan struct{}),
+	}
+	server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+		Tenants: []Tenant{
+			{Name: "a", Configuration: "tenant-a"},
+			{Name: "b", Configuration: "tenant-b", ServiceTokenSecret: "b-secret"},
+		},
+	}, WithConcurrencyLimit("/query", ConcurrencyLimit{
+		MaxConcurrency: 1,
+	}))
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	queryBody, err := json.Marshal(schema.QueryRequest{
+		Collection:              "articles",
+		Arguments:               schema.QueryRequestArguments{},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables:               []schema.QueryRequestVariablesElem{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := func(path string, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, httpServer.URL+path, bytes.NewReader(queryBody))
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		return res
+	}
+	queryAsync := func(path string, token string) chan *http.Response {
+		result := make(chan *http.Response, 1)
+		go func() {
+			result <- query(path, token)
+		}()
+		return result
+	}
+
+	executingA := queryAsync("/a/query", "")
+	<-connector.started
+
+	// the busy slot of tenant a doesn't reject the requests of tenant b,
+	// and unauthorized requests are rejected before they take a slot
+	assertHTTPResponseStatus(t, "unauthorized", query("/b/query", ""), http.StatusUnauthorized)
+	executingB := queryAsync("/b/query", "b-secret")
+	<-connector.started
+
+	assertHTTPResponseStatus(t, "overloaded", query("/a/query", ""), http.StatusServiceUnavailable)
+
+	close(connector.unblock)
+	assertHTTPResponseStatus(t, "executing_a", <-executingA, http.StatusOK)
+	assertHTTPResponseStatus(t, "executing_b", <-executingB, http.StatusOK)
+}
@@ -0,0 +1,188 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// healthState caches the result of the latest background health check
+type healthState struct {
+	lock    sync.RWMutex
+	checked bool
+	err     error
+}
+
+// get returns whether a health check completed and the error of the latest one
+func (hs *healthState) get() (bool, error) {
+	hs.lock.RLock()
+	defer hs.lock.RUnlock()
+	return hs.checked, hs.err
+}
+
+// set stores the result of a health check. It returns whether the readiness changed, and whether the connector was ready before
+func (hs *healthState) set(err error) (bool, bool) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+	wasReady := hs.checked && hs.err == nil
+	changed := !hs.checked || wasReady != (err == nil)
+	hs.checked = true
+	hs.err = err
+	return changed, wasReady
+}
+
+func (s *Server[Configuration, State]) healthCheckInterval() time.Duration {
+	if s.options.HealthCheckInterval > 0 {
+		return s.options.HealthCheckInterval
+	}
+	return defaultHealthCheckInterval
+}
+
+func (s *Server[Configuration, State]) healthCheckTimeout() time.Duration {
+	if s.options.HealthCheckTimeout > 0 {
+		return s.options.HealthCheckTimeout
+	}
+	return defaultHealthCheckTimeout
+}
+
+// checkHealth calls the health check of every tenant with the timeout and caches the results
+func (s *Server[Configuration, State]) checkHealth(ctx context.Context) error {
+	var errs []error
+	for _, t := range s.allTenants() {
+		if err := s.checkTenantHealth(ctx, t); err != nil {
+			if t.name != "" {
+				err = fmt.Errorf("tenant %s: %w", t.name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server[Configuration, State]) checkTenantHealth(ctx context.Context, t *tenant[Configuration, State]) error {
+	// background health checks run as often as probes, so their spans aren't sampled either
+	ctx, cancel := context.WithTimeout(withoutTracing(ctx), s.healthCheckTimeout())
+	defer cancel()
+
+	startTime := time.Now()
+	var err error
+	if rt := t.acquireRuntime(); rt != nil {
+		err = s.connector.HealthCheck(ctx, rt.configuration, rt.state)
+		rt.release()
+	} else {
+		err = errors.New("the connector runtime is retired")
+	}
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("the health check timed out after %s", s.healthCheckTimeout())
+	}
+
+	ready := err == nil
+	statusAttribute := attribute.String("status", readinessStatus(ready))
+	tenantAttribute := metricTenantAttributes(t.name)
+	s.telemetry.healthCheckHistogram.Record(ctx, time.Since(startTime).Seconds(), metric.WithAttributes(statusAttribute), tenantAttribute)
+	changed, wasReady := t.health.set(err)
+	if !changed {
+		return err
+	}
+
+	logger := s.logger.With(t.logAttributes()...)
+	s.telemetry.healthTransitionsCounter.Add(ctx, 1, metric.WithAttributes(statusAttribute), tenantAttribute)
+	if ready {
+		s.telemetry.readyGauge.Add(ctx, 1, tenantAttribute)
+		logger.Info("the connector is ready")
+		return nil
+	}
+	// the gauge starts at zero, so it only decreases after the connector was ready
+	if wasReady {
+		s.telemetry.readyGauge.Add(ctx, -1, tenantAttribute)
+	}
+	logger.Warn("the connector is not ready", slog.Any("error", err))
+	return err
+}
+
+// watchHealth runs the health check of the connector at the interval until the server stops
+func (s *Server[Configuration, State]) watchHealth(interval time.Duration) {
+	_ = s.checkHealth(s.context)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.context.Done():
+			return
+		case <-ticker.C:
+			_ = s.checkHealth(s.context)
+		}
+	}
+}
+
+func readinessStatus(ready bool) string {
+	if ready {
+		return "ready"
+	}
+	return "not_ready"
+}
+
+// Livez implements a handler for the /livez endpoint, GET method. It succeeds while the process is able to serve requests
+func (s *Server[Configuration, State]) Livez(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Readyz implements a handler for the /readyz endpoint, GET method.
+// It returns the cached result of the latest background health check of the tenant that the request is routed to,
+// or of all tenants, and fails while the server is shutting down
+func (s *Server[Configuration, State]) Readyz(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	if s.draining.Load() {
+		writeJson(w, logger, http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the server is shutting down",
+			Details: map[string]any{},
+		})
+		return
+	}
+
+	tenants := s.allTenants()
+	if tenantName(r.Context()) != "" {
+		tenants = []*tenant[Configuration, State]{s.requestTenant(r.Context())}
+	}
+	var errs []error
+	for _, t := range tenants {
+		checked, err := t.health.get()
+		if !checked {
+			writeJson(w, logger, http.StatusServiceUnavailable, schema.ErrorResponse{
+				Message: "the health check hasn't completed yet",
+				Details: map[string]any{},
+			})
+			return
+		}
+		if err != nil {
+			if t.name != "" {
+				err = fmt.Errorf("tenant %s: %w", t.name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		writeJson(w, logger, http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the connector is not ready",
+			Details: map[string]any{
+				"cause": err.Error(),
+			},
+		})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
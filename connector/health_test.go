@@ -0,0 +1,118 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// unhealthyConnector fails the health check while the error is set, and blocks it while blocking is set
+type unhealthyConnector struct {
+	mockConnector
+	err      atomic.Pointer[error]
+	blocking atomic.Bool
+}
+
+func (uc *unhealthyConnector) HealthCheck(ctx context.Context, configuration *mockConfiguration, state *mockState) error {
+	if uc.blocking.Load() {
+		<-ctx.Done()
+		return nil
+	}
+	if err := uc.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func TestServerReadiness(t *testing.T) {
+	connector := &unhealthyConnector{}
+	server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+		Configuration:      "{}",
+		InlineConfig:       true,
+		HealthCheckTimeout: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	assertGet := func(t *testing.T, path string, status int, body any) {
+		res, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		if body == nil {
+			assertHTTPResponseStatus(t, "GET "+path, res, status)
+		} else {
+			assertHTTPResponse(t, res, status, body)
+		}
+	}
+
+	t.Run("not_checked", func(t *testing.T) {
+		assertGet(t, "/livez", http.StatusOK, nil)
+		assertGet(t, "/readyz", http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the health check hasn't completed yet",
+			Details: map[string]any{},
+		})
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		if err := server.checkHealth(context.Background()); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertGet(t, "/readyz", http.StatusOK, nil)
+	})
+
+	t.Run("not_ready", func(t *testing.T) {
+		healthErr := errors.New("database is unreachable")
+		connector.err.Store(&healthErr)
+		defer connector.err.Store(nil)
+
+		if err := server.checkHealth(context.Background()); err == nil {
+			t.Error("expected health check error, got nil")
+			t.FailNow()
+		}
+		assertGet(t, "/readyz", http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the connector is not ready",
+			Details: map[string]any{
+				"cause": "database is unreachable",
+			},
+		})
+		// the liveness and the compatible health endpoint don't use the cached result
+		assertGet(t, "/livez", http.StatusOK, nil)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		connector.blocking.Store(true)
+		defer connector.blocking.Store(false)
+
+		err := server.checkHealth(context.Background())
+		if err == nil || err.Error() != "the health check timed out after 10ms" {
+			t.Errorf("expected timeout error, got %v", err)
+		}
+		assertGet(t, "/readyz", http.StatusServiceUnavailable, nil)
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		if err := server.checkHealth(context.Background()); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		server.draining.Store(true)
+		defer server.draining.Store(false)
+		assertGet(t, "/readyz", http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the server is shutting down",
+			Details: map[string]any{},
+		})
+		assertGet(t, "/livez", http.StatusOK, nil)
+	})
+}
@@ -2,6 +2,7 @@ package connector
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -19,6 +20,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -38,21 +40,54 @@ var allowedTraceEndpoints = map[string]string{
 	"/mutation/explain": "ndc_mutation_explain",
 }
 
-// define a custom response write to capture response information for logging
+// spans that start from requests of health probes and metrics scrapes aren't sampled
+var untracedEndpoints = map[string]bool{
+	"/health":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// define a custom response write to capture response information for logging.
+// It counts the size of the response body, and copies the body up to the log size limit
+// only in debug mode or for error responses, so large responses aren't kept in memory
 type customResponseWriter struct {
 	http.ResponseWriter
-	statusCode int
-	body       []byte
+	statusCode  int
+	size        int
+	body        []byte
+	captureBody bool
+	maxBodySize int
 }
 
 func (cw *customResponseWriter) WriteHeader(statusCode int) {
 	cw.statusCode = statusCode
+	if statusCode >= 400 {
+		cw.captureBody = true
+	}
 	cw.ResponseWriter.WriteHeader(statusCode)
 }
 
 func (cw *customResponseWriter) Write(body []byte) (int, error) {
-	cw.body = body
-	return cw.ResponseWriter.Write(body)
+	if cw.captureBody && len(cw.body) < cw.maxBodySize {
+		cw.body = append(cw.body, body[:min(len(body), cw.maxBodySize-len(cw.body))]...)
+	}
+	n, err := cw.ResponseWriter.Write(body)
+	cw.size += n
+	return n, err
+}
+
+// loggedBody returns the captured body with redacted fields, and the size of the part that isn't captured.
+// A partially captured body can't be decoded, so only its size is returned if fields must be redacted
+func (cw *customResponseWriter) loggedBody(rd *redactor) string {
+	if cw.size > len(cw.body) {
+		if len(rd.paths) > 0 {
+			return rd.redactedBodySize(cw.size)
+		}
+		// the capture may end in the middle of a multi-byte character
+		return fmt.Sprintf("%s...(truncated %d bytes)", strings.ToValidUTF8(string(cw.body), ""), cw.size-len(cw.body))
+	}
+	return rd.loggedBody(cw.body, cw.maxBodySize)
 }
 
 // implements a simple router to reuse for both configuration and connector servers
@@ -61,14 +96,30 @@ type router struct {
 	logger          *slog.Logger
 	telemetry       *TelemetryState
 	recoveryEnabled bool
+	// maximum sizes in bytes of request bodies, keyed by path
+	bodyLimits map[string]int64
+	// maximum size in bytes of request and response bodies in debug logs and spans
+	maxLogBodySize int
+	// compress responses with the encoding that the client accepts
+	compressionEnabled bool
+	// minimum size in bytes of compressed responses
+	compressionMinSize int
+	// masks sensitive headers and body fields in logs and span attributes
+	redactor *redactor
 }
 
 func newRouter(logger *slog.Logger, telemetry *TelemetryState, enableRecovery bool) *router {
+	// the default policy has no body paths so it's always valid
+	defaultRedactor, _ := newRedactor(RedactionPolicy{})
 	return &router{
-		routes:          make(map[string]map[string]http.HandlerFunc),
-		logger:          logger,
-		telemetry:       telemetry,
-		recoveryEnabled: enableRecovery,
+		routes:             make(map[string]map[string]http.HandlerFunc),
+		logger:             logger,
+		telemetry:          telemetry,
+		recoveryEnabled:    enableRecovery,
+		bodyLimits:         make(map[string]int64),
+		maxLogBodySize:     defaultDebugBodyLogSize,
+		compressionMinSize: defaultCompressionMinSize,
+		redactor:           defaultRedactor,
 	}
 }
 
@@ -79,21 +130,62 @@ func (rt *router) Use(path string, method string, handler http.HandlerFunc) {
 	rt.routes[path][method] = handler
 }
 
+// SetBodyLimit sets the maximum size in bytes of request bodies of the path. The size is unlimited if the limit isn't positive
+func (rt *router) SetBodyLimit(path string, limit int64) {
+	rt.bodyLimits[path] = limit
+}
+
 func (rt *router) Build() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	handleFunc := func(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	handleFunc := func(handlers map[string]http.HandlerFunc, bodyLimit int64) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			startTime := time.Now()
 			isDebug := rt.logger.Enabled(context.Background(), slog.LevelDebug)
 			requestID := getRequestID(r)
+			endpointAttr := metric.WithAttributes(attribute.String("endpoint", r.URL.Path))
+			rt.telemetry.inflightRequests.Add(r.Context(), 1, endpointAttr, tenantAttributes(r.Context()))
+			defer rt.telemetry.inflightRequests.Add(r.Context(), -1, endpointAttr, tenantAttributes(r.Context()))
 			requestLogData := map[string]any{
 				"url":            r.URL.String(),
 				"method":         r.Method,
 				"remote_address": r.RemoteAddr,
 			}
+			tenant := tenantName(r.Context())
+			if tenant != "" {
+				requestLogData["tenant"] = tenant
+			}
+
+			if encoding := r.Header.Get("Content-Encoding"); encoding != "" && r.Body != nil {
+				statusCode, err := decodeRequestBody(r, encoding)
+				if err != nil {
+					rt.logger.Error("failed to decode request body",
+						slog.String("request_id", requestID),
+						slog.Duration("latency", time.Since(startTime)),
+						slog.Any("request", requestLogData),
+						slog.Any("error", err),
+					)
+					writeJson(w, rt.logger, statusCode, schema.ErrorResponse{
+						Message: err.Error(),
+						Details: map[string]any{},
+					})
+					return
+				}
+			}
+			// the limit applies to the decompressed body so compressed requests can't expand beyond it
+			if bodyLimit > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, bodyLimit)
+			}
+			var requestBody *countingReadCloser
+			if r.Body != nil && r.Body != http.NoBody {
+				requestBody = &countingReadCloser{ReadCloser: r.Body}
+				r.Body = requestBody
+			}
 
 			ctx := r.Context()
+			if untracedEndpoints[strings.ToLower(r.URL.Path)] {
+				ctx = withoutTracing(ctx)
+			}
 			//lint:ignore SA1012 possible to set nil
 			span := trace.SpanFromContext(nil) //nolint:all
 			spanName, spanOk := allowedTraceEndpoints[strings.ToLower(r.URL.Path)]
@@ -105,11 +197,14 @@ func (rt *router) Build() *http.ServeMux {
 				)
 			}
 			defer span.End()
+			if tenant != "" {
+				span.SetAttributes(attribute.String("tenant", tenant))
+			}
 
 			if isDebug {
-				requestLogData["headers"] = r.Header
+				requestLogData["headers"] = rt.redactor.redactHeaders(r.Header)
 				if spanOk {
-					setSpanHeadersAttributes(span, r.Header, isDebug)
+					setSpanHeadersAttributes(span, r.Header, isDebug, rt.redactor)
 				}
 				if r.Body != nil {
 					bodyBytes, err := io.ReadAll(r.Body)
@@ -121,19 +216,23 @@ func (rt *router) Build() *http.ServeMux {
 							slog.Any("error", err),
 						)
 
-						writeJson(w, rt.logger, http.StatusUnprocessableEntity, schema.ErrorResponse{
-							Message: "failed to read request",
-							Details: map[string]any{
-								"cause": err,
-							},
-						})
+						if limit, ok := isRequestBodyTooLarge(err); ok {
+							writeRequestBodyTooLarge(w, rt.logger, limit)
+						} else {
+							writeJson(w, rt.logger, http.StatusUnprocessableEntity, schema.ErrorResponse{
+								Message: "failed to read request",
+								Details: map[string]any{
+									"cause": err,
+								},
+							})
+						}
 
 						span.SetStatus(codes.Error, "read_request_body_failure")
 						span.RecordError(err)
 						return
 					}
 
-					bodyStr := string(bodyBytes)
+					bodyStr := rt.redactor.loggedBody(bodyBytes, rt.maxLogBodySize)
 					span.SetAttributes(attribute.String("request.body", bodyStr))
 					requestLogData["body"] = bodyStr
 					r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
@@ -207,22 +306,45 @@ func (rt *router) Build() *http.ServeMux {
 				}
 			}
 
-			logger := rt.logger.With(slog.String("request_id", requestID))
+			logger := withTraceContext(ctx, rt.logger.With(slog.String("request_id", requestID)))
 			req := r.WithContext(context.WithValue(ctx, logContextKey, logger))
-			writer := &customResponseWriter{ResponseWriter: w}
+			var responseWriter http.ResponseWriter = w
+			var compressor *compressResponseWriter
+			if rt.compressionEnabled {
+				if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+					compressor = newCompressResponseWriter(w, r, rt.telemetry, encoding, rt.compressionMinSize)
+					responseWriter = compressor
+				}
+			}
+			writer := &customResponseWriter{
+				ResponseWriter: responseWriter,
+				captureBody:    isDebug,
+				maxBodySize:    rt.maxLogBodySize,
+			}
 			h(writer, req)
+			if compressor != nil {
+				if err := compressor.Close(); err != nil {
+					logger.Error("failed to compress response", slog.Any("error", err))
+				}
+			}
+			if requestBody != nil {
+				rt.telemetry.requestSizeHistogram.Record(r.Context(), requestBody.size, endpointAttr, tenantAttributes(r.Context()))
+			}
+			rt.telemetry.responseSizeHistogram.Record(r.Context(), int64(writer.size), endpointAttr, tenantAttributes(r.Context()))
 
 			responseLogData := map[string]any{
 				"status": writer.statusCode,
+				"size":   writer.size,
 			}
 			if isDebug || writer.statusCode >= 400 {
-				responseLogData["headers"] = writer.Header()
+				responseLogData["headers"] = rt.redactor.redactHeaders(writer.Header())
 				if len(writer.body) > 0 {
-					responseLogData["body"] = string(writer.body)
-					span.SetAttributes(attribute.String("response.body", string(writer.body)))
+					bodyStr := writer.loggedBody(rt.redactor)
+					responseLogData["body"] = bodyStr
+					span.SetAttributes(attribute.String("response.body", bodyStr))
 				}
 			}
-			setSpanHeadersAttributes(span, w.Header(), isDebug)
+			setSpanHeadersAttributes(span, w.Header(), isDebug, rt.redactor)
 
 			if writer.statusCode >= 400 {
 				logger.Error(
@@ -245,7 +367,7 @@ func (rt *router) Build() *http.ServeMux {
 	}
 
 	for path, handlers := range rt.routes {
-		handler := handleFunc(handlers)
+		handler := handleFunc(handlers, rt.bodyLimits[path])
 		mux.HandleFunc(path, handler)
 	}
 
@@ -289,15 +411,44 @@ func writeJson(w http.ResponseWriter, logger *slog.Logger, statusCode int, body
 	})
 }
 
-// GetLogger gets the logger instance from context
+// GetLogger gets the logger instance from context.
+// The logger adds the trace and span IDs of the context to logs
 func GetLogger(ctx context.Context) *slog.Logger {
 	value := ctx.Value(logContextKey)
 	if value != nil {
 		if logger, ok := value.(*slog.Logger); ok {
-			return logger
+			return withTraceContext(ctx, logger)
 		}
 	}
-	return slog.Default()
+	return withTraceContext(ctx, slog.Default())
+}
+
+// decodeRequestBody decompresses the request body of the content encoding, and returns the error status if it fails
+func decodeRequestBody(r *http.Request, encoding string) (int, error) {
+	if !strings.EqualFold(encoding, encodingGzip) {
+		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding %s, accept %s only", encoding, encodingGzip)
+	}
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid gzip request body: %w", err)
+	}
+	r.Body = &gzipRequestBody{
+		Reader: reader,
+		body:   r.Body,
+	}
+	r.Header.Del("Content-Encoding")
+	r.ContentLength = -1
+	return 0, nil
+}
+
+// writeRequestBodyTooLarge writes the 413 error of a request body that exceeds its size limit
+func writeRequestBodyTooLarge(w http.ResponseWriter, logger *slog.Logger, limit int64) {
+	writeJson(w, logger, http.StatusRequestEntityTooLarge, schema.ErrorResponse{
+		Message: fmt.Sprintf("request body is too large, the limit is %d bytes", limit),
+		Details: map[string]any{
+			"limit": limit,
+		},
+	})
 }
 
 func writeError(w http.ResponseWriter, logger *slog.Logger, err error) int {
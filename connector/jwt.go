@@ -0,0 +1,228 @@
+package connector
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwtAlgorithmHS256 = "HS256"
+	jwtAlgorithmRS256 = "RS256"
+	// the clock skew that is tolerated when validating the time claims of a token
+	jwtLeeway = time.Minute
+)
+
+// JWTAuthenticatorOptions are the options of a JWTAuthenticator
+type JWTAuthenticatorOptions struct {
+	// JWKSFile is the path of a local JSON Web Key Set file with the keys that sign tokens.
+	// The file is read again when it changes
+	JWKSFile string
+	// Issuer is the expected iss claim of tokens. It isn't validated if empty
+	Issuer string
+	// Audience is the expected aud claim of tokens. It isn't validated if empty
+	Audience string
+}
+
+// JWTAuthenticator accepts bearer tokens that are JSON Web Tokens signed with HS256 or RS256
+// by a key of a JSON Web Key Set. The claims of a valid token are put into the request context
+type JWTAuthenticator struct {
+	options JWTAuthenticatorOptions
+	file    *watchedFile
+	lock    sync.RWMutex
+	keys    []jsonWebKey
+	now     func() time.Time
+}
+
+// jsonWebKey is a verification key of a JSON Web Key Set
+type jsonWebKey struct {
+	id        string
+	algorithm string
+	secret    []byte
+	publicKey *rsa.PublicKey
+}
+
+// NewJWTAuthenticator creates a JWTAuthenticator instance
+func NewJWTAuthenticator(options JWTAuthenticatorOptions) (*JWTAuthenticator, error) {
+	ja := &JWTAuthenticator{
+		options: options,
+		now:     time.Now,
+	}
+	ja.file = newWatchedFile(options.JWKSFile, ja.load)
+	if err := ja.file.Reload(); err != nil {
+		return nil, err
+	}
+	return ja, nil
+}
+
+func (ja *JWTAuthenticator) load(data []byte) error {
+	var jwks struct {
+		Keys []struct {
+			KeyType   string `json:"kty"`
+			KeyID     string `json:"kid"`
+			Algorithm string `json:"alg"`
+			Use       string `json:"use"`
+			K         string `json:"k"`
+			N         string `json:"n"`
+			E         string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return err
+	}
+
+	keys := make([]jsonWebKey, 0, len(jwks.Keys))
+	for i, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key := jsonWebKey{
+			id:        k.KeyID,
+			algorithm: k.Algorithm,
+		}
+		switch k.KeyType {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("invalid secret of key %d", i)
+			}
+			key.secret = secret
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return fmt.Errorf("invalid public key %d", i)
+			}
+			key.publicKey = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		default:
+			// other key types can't verify the supported algorithms
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	ja.lock.Lock()
+	ja.keys = keys
+	ja.lock.Unlock()
+	return nil
+}
+
+// Authenticate validates the bearer token of the request and returns its claims
+func (ja *JWTAuthenticator) Authenticate(r *http.Request) (Claims, error) {
+	ja.file.ReloadIfChanged()
+
+	token, ok := getBearerToken(r)
+	if !ok {
+		return nil, errors.New("Bearer token is required.") //nolint:all
+	}
+	return ja.Validate(token)
+}
+
+// Validate verifies the signature and the registered claims of the token, and returns its claims
+func (ja *JWTAuthenticator) Validate(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid JWT header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT signature: %w", err)
+	}
+	if err := ja.verify(header.Algorithm, header.KeyID, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	if err := ja.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verify checks the signature with the keys of the algorithm, which match the key ID of the token if it has one
+func (ja *JWTAuthenticator) verify(algorithm string, keyID string, signingInput string, signature []byte) error {
+	if algorithm != jwtAlgorithmHS256 && algorithm != jwtAlgorithmRS256 {
+		return fmt.Errorf("unsupported JWT algorithm: %s", algorithm)
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+
+	ja.lock.RLock()
+	defer ja.lock.RUnlock()
+	for _, key := range ja.keys {
+		if (keyID != "" && key.id != keyID) || (key.algorithm != "" && key.algorithm != algorithm) {
+			continue
+		}
+		switch {
+		case algorithm == jwtAlgorithmHS256 && key.secret != nil:
+			mac := hmac.New(sha256.New, key.secret)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return nil
+			}
+		case algorithm == jwtAlgorithmRS256 && key.publicKey != nil:
+			if rsa.VerifyPKCS1v15(key.publicKey, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		}
+	}
+	return errors.New("invalid JWT signature")
+}
+
+func (ja *JWTAuthenticator) validateClaims(claims Claims) error {
+	now := ja.now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return errors.New("JWT is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("JWT is not valid yet")
+	}
+	if ja.options.Issuer != "" && claims["iss"] != ja.options.Issuer {
+		return errors.New("invalid JWT issuer")
+	}
+	if ja.options.Audience != "" {
+		var audiences []any
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []any{aud}
+		case []any:
+			audiences = aud
+		}
+		if !slices.Contains(audiences, any(ja.options.Audience)) {
+			return errors.New("invalid JWT audience")
+		}
+	}
+	return nil
+}
+
+func decodeJWTSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
@@ -0,0 +1,127 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	traceapi "go.opentelemetry.io/otel/trace"
+)
+
+// logHandler writes log records to the base handler with the trace and span IDs of the context,
+// and fans them out to the OpenTelemetry slog bridge if the logs exporter is enabled
+type logHandler struct {
+	slog.Handler
+	// the base handler before the first group, so the trace and span IDs are added out of groups
+	root slog.Handler
+	// the groups of the handler after root, with their attributes
+	groups []logGroup
+	// the otelslog bridge handler, which exports records through OTLP. It is nil if the logs exporter is disabled
+	otlp slog.Handler
+	// the context that the logger is bound to by GetLogger, used if the record context has no span
+	ctx context.Context
+}
+
+type logGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+// newLogHandler wraps the base handler, or the base handler of a log handler, so trace IDs are never added twice
+func newLogHandler(base slog.Handler, otlp slog.Handler) *logHandler {
+	if lh, ok := base.(*logHandler); ok {
+		result := *lh
+		result.otlp = otlp
+		result.ctx = nil
+		return &result
+	}
+	return &logHandler{
+		Handler: base,
+		root:    base,
+		otlp:    otlp,
+	}
+}
+
+// Handle implements slog.Handler
+func (h *logHandler) Handle(ctx context.Context, record slog.Record) error {
+	spanContext := traceapi.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() && h.ctx != nil {
+		ctx = h.ctx
+		spanContext = traceapi.SpanContextFromContext(ctx)
+	}
+
+	var otlpErr error
+	if h.otlp != nil && h.otlp.Enabled(ctx, record.Level) {
+		// OTLP records carry the trace context of ctx, so the IDs aren't added as attributes
+		otlpErr = h.otlp.Handle(ctx, record)
+	}
+	if !spanContext.IsValid() {
+		return errors.Join(h.Handler.Handle(ctx, record), otlpErr)
+	}
+
+	traceAttrs := []slog.Attr{
+		slog.String("trace_id", spanContext.TraceID().String()),
+		slog.String("span_id", spanContext.SpanID().String()),
+	}
+	if len(h.groups) == 0 {
+		record = record.Clone()
+		record.AddAttrs(traceAttrs...)
+		return errors.Join(h.Handler.Handle(ctx, record), otlpErr)
+	}
+	// attributes of records are added to the last group, so the groups are rebuilt after the IDs
+	handler := h.root.WithAttrs(traceAttrs)
+	for _, group := range h.groups {
+		handler = handler.WithGroup(group.name)
+		if len(group.attrs) > 0 {
+			handler = handler.WithAttrs(group.attrs)
+		}
+	}
+	return errors.Join(handler.Handle(ctx, record), otlpErr)
+}
+
+// WithAttrs implements slog.Handler
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	result := *h
+	result.Handler = h.Handler.WithAttrs(attrs)
+	if len(h.groups) == 0 {
+		result.root = result.Handler
+	} else {
+		result.groups = append([]logGroup{}, h.groups...)
+		last := &result.groups[len(result.groups)-1]
+		last.attrs = append(append([]slog.Attr{}, last.attrs...), attrs...)
+	}
+	if h.otlp != nil {
+		result.otlp = h.otlp.WithAttrs(attrs)
+	}
+	return &result
+}
+
+// WithGroup implements slog.Handler
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	result := *h
+	result.Handler = h.Handler.WithGroup(name)
+	result.groups = append(append([]logGroup{}, h.groups...), logGroup{name: name})
+	if h.otlp != nil {
+		result.otlp = h.otlp.WithGroup(name)
+	}
+	return &result
+}
+
+// withTraceContext binds the logger to the span of the context, so records logged without a context carry its trace and span IDs
+func withTraceContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if !traceapi.SpanContextFromContext(ctx).IsValid() {
+		return logger
+	}
+	handler := logHandler{
+		Handler: logger.Handler(),
+		root:    logger.Handler(),
+	}
+	if lh, ok := logger.Handler().(*logHandler); ok {
+		handler = *lh
+	}
+	handler.ctx = ctx
+	return slog.New(&handler)
+}
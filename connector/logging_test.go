@@ -0,0 +1,146 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// recordingLogExporter keeps the exported log records in memory
+type recordingLogExporter struct {
+	lock    sync.Mutex
+	records []sdklog.Record
+}
+
+func (rle *recordingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	rle.lock.Lock()
+	defer rle.lock.Unlock()
+	for _, record := range records {
+		rle.records = append(rle.records, record.Clone())
+	}
+	return nil
+}
+
+func (rle *recordingLogExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (rle *recordingLogExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var results []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var line map[string]any
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, line)
+	}
+	return results
+}
+
+func TestGetLoggerTraceContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newLogHandler(slog.NewJSONHandler(&buf, nil), nil))
+	tracer := trace.NewTracerProvider().Tracer("test")
+	ctx, span := tracer.Start(context.WithValue(context.Background(), logContextKey, logger), "parent")
+	defer span.End()
+	childCtx, childSpan := tracer.Start(ctx, "child")
+	defer childSpan.End()
+
+	GetLogger(context.WithValue(context.Background(), logContextKey, logger)).Info("no span")
+	GetLogger(ctx).Info("bound span")
+	GetLogger(ctx).With(slog.String("request_id", "abc")).InfoContext(childCtx, "record span")
+
+	lines := decodeLogLines(t, &buf)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 log lines, got %d", len(lines))
+	}
+	if _, ok := lines[0]["trace_id"]; ok {
+		t.Errorf("expected no trace id without a span, got %v", lines[0])
+	}
+	testCases := []struct {
+		line   map[string]any
+		spanID string
+	}{
+		{line: lines[1], spanID: span.SpanContext().SpanID().String()},
+		{line: lines[2], spanID: childSpan.SpanContext().SpanID().String()},
+	}
+	for _, tc := range testCases {
+		if tc.line["trace_id"] != span.SpanContext().TraceID().String() {
+			t.Errorf("expected trace id %s, got %v", span.SpanContext().TraceID(), tc.line["trace_id"])
+		}
+		if tc.line["span_id"] != tc.spanID {
+			t.Errorf("expected span id %s, got %v", tc.spanID, tc.line["span_id"])
+		}
+	}
+	if lines[2]["request_id"] != "abc" {
+		t.Errorf("expected the request id attribute, got %v", lines[2])
+	}
+}
+
+func TestOTLPLogHandler(t *testing.T) {
+	exporter := &recordingLogExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	var buf bytes.Buffer
+	logger := slog.New(newLogHandler(slog.NewJSONHandler(&buf, nil), otelslog.NewHandler("test", otelslog.WithLoggerProvider(provider))))
+
+	ctx, span := trace.NewTracerProvider().Tracer("test").Start(context.Background(), "query")
+	defer span.End()
+	logger.With(slog.String("request_id", "abc")).
+		WithGroup("response").
+		WarnContext(ctx, "slow response", slog.Int("status", 200), slog.Any("headers", map[string]any{"x": "y"}))
+	logger.Debug("disabled")
+
+	if len(exporter.records) != 1 {
+		t.Fatalf("expected 1 exported record, got %d", len(exporter.records))
+	}
+	record := exporter.records[0]
+	if body := record.Body().AsString(); body != "slow response" {
+		t.Errorf("expected the message body, got %s", body)
+	}
+	if record.Severity() != otellog.SeverityWarn {
+		t.Errorf("expected the warn severity, got %s", record.Severity())
+	}
+	if record.TraceID() != span.SpanContext().TraceID() || record.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected the span context of the record, got %s %s", record.TraceID(), record.SpanID())
+	}
+
+	expected := []otellog.KeyValue{
+		otellog.String("request_id", "abc"),
+		otellog.Map("response",
+			otellog.Int("status", 200),
+			otellog.Map("headers", otellog.String("x", "y")),
+		),
+	}
+	var attrs []otellog.KeyValue
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs = append(attrs, kv)
+		return true
+	})
+	if len(attrs) != len(expected) {
+		t.Fatalf("expected attributes %v, got %v", expected, attrs)
+	}
+	for i, kv := range expected {
+		if !kv.Equal(attrs[i]) {
+			t.Errorf("expected attribute %s, got %s", kv, attrs[i])
+		}
+	}
+
+	// the trace IDs are only added to the records of the base handler
+	lines := decodeLogLines(t, &buf)
+	if len(lines) != 1 || lines[0]["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("expected a log line with the trace id, got %v", lines)
+	}
+}
@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+	"io"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// the attribute value of collection and operation names that aren't in the metrics allowlist
+const otherMetricsOperation = "other"
+
+// metricsOperationName returns the collection, function or procedure name as a metric attribute value.
+// Names out of the allowlist are replaced, so clients can't grow the cardinality of metrics with arbitrary names
+func (s *Server[Configuration, State]) metricsOperationName(name string) string {
+	if s.metricsAllowlist == nil || s.metricsAllowlist[name] {
+		return name
+	}
+	return otherMetricsOperation
+}
+
+// newMetricsAllowlist returns the set of the allowed names, or nil if all names are allowed
+func newMetricsAllowlist(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	result := make(map[string]bool, len(names))
+	for _, name := range names {
+		result[name] = true
+	}
+	return result
+}
+
+// metricsMutationOperations returns the operations attribute of mutation metrics. It is the name of the operation
+// of single-operation requests, and "other" for requests of several operations, whose name combinations are unbounded
+func (s *Server[Configuration, State]) metricsMutationOperations(operations []schema.MutationOperation) attribute.KeyValue {
+	if len(operations) != 1 {
+		return attribute.String("operations", otherMetricsOperation)
+	}
+	return attribute.String("operations", s.metricsOperationName(operations[0].Name))
+}
+
+// recordMutationOperations counts the operations of a mutation request by their types and names
+func (s *Server[Configuration, State]) recordMutationOperations(ctx context.Context, operations []schema.MutationOperation, status attribute.KeyValue) {
+	for _, op := range operations {
+		s.telemetry.mutationOperationCounter.Add(ctx, 1, tenantAttributes(ctx), metric.WithAttributes(
+			attribute.String("operation.type", string(op.Type)),
+			attribute.String("operation.name", s.metricsOperationName(op.Name)),
+			status,
+		))
+	}
+}
+
+// countingReadCloser counts the bytes that are read from the request body
+type countingReadCloser struct {
+	io.ReadCloser
+	size int64
+}
+
+func (cr *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.size += int64(n)
+	return n, err
+}
+
+// countingRowIterator counts the rows that are iterated
+type countingRowIterator struct {
+	RowIterator
+	count int64
+}
+
+func (ci *countingRowIterator) Next() (map[string]any, error) {
+	row, err := ci.RowIterator.Next()
+	if err == nil {
+		ci.count++
+	}
+	return row, err
+}
+
+// countRows wraps the row iterators of the response, and returns a function that records the numbers of iterated rows of row sets
+func (s *Server[Configuration, State]) countRows(ctx context.Context, response StreamingQueryResponse, collection string) func() {
+	counters := make([]*countingRowIterator, len(response))
+	for i, rowSet := range response {
+		if rowSet.Rows != nil {
+			counters[i] = &countingRowIterator{RowIterator: rowSet.Rows}
+			response[i].Rows = counters[i]
+		}
+	}
+	return func() {
+		collectionAttr := metric.WithAttributes(attribute.String("collection", collection))
+		for _, counter := range counters {
+			var count int64
+			if counter != nil {
+				count = counter.count
+			}
+			s.telemetry.queryRowsHistogram.Record(ctx, count, collectionAttr, tenantAttributes(ctx))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"github.com/hasura/ndc-sdk-go/schema"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func findMetric(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Aggregation {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return nil
+}
+
+func TestServerMetrics(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration:             "{}",
+		InlineConfig:              true,
+		MetricsOperationAllowlist: []string{"articles", "upsert_article"},
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	reader := metric.NewManualReader()
+	server.telemetry.Meter = metric.NewMeterProvider(metric.WithReader(reader)).Meter("test")
+	if err := setupMetrics(server.telemetry, ""); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	queryRequest := func(collection string) schema.QueryRequest {
+		return schema.QueryRequest{
+			Collection:              collection,
+			Arguments:               schema.QueryRequestArguments{},
+			CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+			Query:                   schema.Query{},
+			Variables:               []schema.QueryRequestVariablesElem{},
+		}
+	}
+	mutationRequest := func(name string) schema.MutationRequest {
+		return schema.MutationRequest{
+			Operations: []schema.MutationOperation{
+				{
+					Type:      schema.MutationOperationProcedure,
+					Name:      name,
+					Arguments: []byte("{}"),
+				},
+			},
+			CollectionRelationships: schema.MutationRequestCollectionRelationships{},
+		}
+	}
+
+	for _, tc := range []struct {
+		path   string
+		body   any
+		status int
+	}{
+		{path: "/query", body: queryRequest("articles"), status: http.StatusOK},
+		{path: "/query", body: queryRequest("authors"), status: http.StatusBadRequest},
+		{path: "/mutation", body: mutationRequest("upsert_article"), status: http.StatusOK},
+		{path: "/mutation", body: mutationRequest("delete_article"), status: http.StatusBadRequest},
+	} {
+		res, err := httpPostJSON(httpServer.URL+tc.path, tc.body)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponseStatus(t, "POST "+tc.path, res, tc.status)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("query_rows", func(t *testing.T) {
+		rows := findMetric(t, rm, "query.rows").(metricdata.Histogram[int64])
+		if len(rows.DataPoints) != 1 {
+			t.Fatalf("expected 1 data point, got %d", len(rows.DataPoints))
+		}
+		point := rows.DataPoints[0]
+		if point.Count != 1 || point.Sum != 1 {
+			t.Errorf("expected 1 row set of 1 row, got count %d, sum %d", point.Count, point.Sum)
+		}
+		if value, _ := point.Attributes.Value("collection"); value.AsString() != "articles" {
+			t.Errorf("expected the articles collection, got %s", value.AsString())
+		}
+	})
+
+	t.Run("allowlist", func(t *testing.T) {
+		queries := findMetric(t, rm, "query.total").(metricdata.Sum[int64])
+		collections := map[string]bool{}
+		for _, point := range queries.DataPoints {
+			value, _ := point.Attributes.Value("collection")
+			collections[value.AsString()] = true
+		}
+		if !internal.DeepEqual(collections, map[string]bool{"articles": true, otherMetricsOperation: true}) {
+			t.Errorf("expected the articles and other collections, got %v", collections)
+		}
+
+		operations := findMetric(t, rm, "mutation.operation_total").(metricdata.Sum[int64])
+		results := map[string]bool{}
+		for _, point := range operations.DataPoints {
+			name, _ := point.Attributes.Value("operation.name")
+			status, _ := point.Attributes.Value("status")
+			results[name.AsString()+":"+status.AsString()] = true
+		}
+		expected := map[string]bool{
+			"upsert_article:success":           true,
+			otherMetricsOperation + ":failure": true,
+		}
+		if !internal.DeepEqual(results, expected) {
+			t.Errorf("expected operations %v, got %v", expected, results)
+		}
+	})
+
+	t.Run("mutation_operations", func(t *testing.T) {
+		mutations := findMetric(t, rm, "mutation.total").(metricdata.Sum[int64])
+		results := map[string]bool{}
+		for _, point := range mutations.DataPoints {
+			name, _ := point.Attributes.Value("operations")
+			status, _ := point.Attributes.Value("status")
+			results[name.AsString()+":"+status.AsString()] = true
+		}
+		expected := map[string]bool{
+			"upsert_article:success":           true,
+			otherMetricsOperation + ":failure": true,
+		}
+		if !internal.DeepEqual(results, expected) {
+			t.Errorf("expected operations %v, got %v", expected, results)
+		}
+	})
+
+	t.Run("body_size", func(t *testing.T) {
+		for _, name := range []string{"http.request.body_size", "http.response.body_size"} {
+			histogram := findMetric(t, rm, name).(metricdata.Histogram[int64])
+			for _, point := range histogram.DataPoints {
+				if point.Sum <= 0 {
+					t.Errorf("%s: expected a positive size, got %d", name, point.Sum)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package connector
+
+import (
+	"net/http"
+)
+
+// Middleware wraps an HTTP handler with cross-cutting behavior, such as tenant extraction or custom authentication.
+//
+// Middlewares run in the following order for every request:
+//   - the built-in handler of the router, which recovers from panics, starts the trace span,
+//     injects the request logger into the context and validates the content type;
+//   - global middlewares registered with [WithMiddleware], in the order of registration;
+//   - route middlewares registered with [WithRouteMiddleware], in the order of registration;
+//   - the tenant resolution of the endpoint, which rejects unknown tenants, if the endpoint is served per tenant;
+//   - the authentication of the endpoint, if any;
+//   - the concurrency limit of the route per tenant, if any;
+//   - the endpoint handler.
+type Middleware func(http.Handler) http.Handler
+
+// WithMiddleware adds middlewares that apply to all routes of the server
+func WithMiddleware(middlewares ...Middleware) ServeOption {
+	return func(so *serveOptions) {
+		so.middlewares = append(so.middlewares, middlewares...)
+	}
+}
+
+// WithRouteMiddleware adds middlewares that apply to the route of the path only, for example /query or /mutation
+func WithRouteMiddleware(path string, middlewares ...Middleware) ServeOption {
+	return func(so *serveOptions) {
+		if so.routeMiddlewares == nil {
+			so.routeMiddlewares = make(map[string][]Middleware)
+		}
+		so.routeMiddlewares[path] = append(so.routeMiddlewares[path], middlewares...)
+	}
+}
+
+// withMiddlewares wraps the handler of the path with the global and route middlewares
+func (so *serveOptions) withMiddlewares(path string, handler http.HandlerFunc) http.HandlerFunc {
+	middlewares := append(append([]Middleware{}, so.middlewares...), so.routeMiddlewares[path]...)
+	var result http.Handler = handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		result = middlewares[i](result)
+	}
+	return result.ServeHTTP
+}
@@ -0,0 +1,71 @@
+package connector
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+)
+
+func TestServerMiddleware(t *testing.T) {
+	var lock sync.Mutex
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				calls = append(calls, name)
+				lock.Unlock()
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration:      "{}",
+		InlineConfig:       true,
+		ServiceTokenSecret: "random-secret",
+	}, WithMiddleware(record("global_1"), record("global_2")), WithRouteMiddleware("/schema", record("schema")))
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	testCases := []struct {
+		path     string
+		status   int
+		expected []string
+	}{
+		{
+			path:     "/health",
+			status:   http.StatusOK,
+			expected: []string{"global_1", "global_2"},
+		},
+		{
+			// middlewares run before authentication
+			path:     "/schema",
+			status:   http.StatusUnauthorized,
+			expected: []string{"global_1", "global_2", "schema"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			calls = nil
+			res, err := http.Get(fmt.Sprintf("%s%s", httpServer.URL, tc.path))
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			assertHTTPResponseStatus(t, tc.path, res, tc.status)
+			if !internal.DeepEqual(tc.expected, calls) {
+				t.Errorf("expected middleware calls %v, got %v", tc.expected, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package connector
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// the headers that are always redacted in logs and span attributes
+var defaultRedactedHeaders = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"x-hasura-admin-secret",
+}
+
+const redactedValue = "[REDACTED]"
+
+// RedactionPolicy configures the masking of sensitive headers and body fields in debug logs and span attributes
+type RedactionPolicy struct {
+	// Headers are the names of headers that are redacted in addition to the defaults:
+	// Authorization, Proxy-Authorization, Cookie, Set-Cookie, X-Api-Key and X-Hasura-Admin-Secret
+	Headers []string
+	// BodyPaths are JSON paths of fields of request and response bodies that are redacted,
+	// for example $.arguments.*, $.query.predicate or $..value.
+	// A * segment matches any object key or array element, and a .. prefix matches the field at any depth
+	BodyPaths []string
+	// Hash replaces redacted values with their SHA-256 hashes instead of a fixed mask,
+	// so equal values can still be correlated
+	Hash bool
+}
+
+type redactionPathSegment struct {
+	name string
+	// matches the segment at any depth
+	recursive bool
+}
+
+// redactor applies a redaction policy to headers and bodies
+type redactor struct {
+	headers map[string]bool
+	paths   [][]redactionPathSegment
+	hash    bool
+}
+
+func newRedactor(policy RedactionPolicy) (*redactor, error) {
+	rd := &redactor{
+		headers: make(map[string]bool, len(defaultRedactedHeaders)+len(policy.Headers)),
+		hash:    policy.Hash,
+	}
+	for _, name := range defaultRedactedHeaders {
+		rd.headers[name] = true
+	}
+	for _, name := range policy.Headers {
+		rd.headers[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, path := range policy.BodyPaths {
+		segments, err := parseRedactionPath(path)
+		if err != nil {
+			return nil, err
+		}
+		rd.paths = append(rd.paths, segments)
+	}
+	return rd, nil
+}
+
+// parseRedactionPath parses a JSON path of dot separated segments, with an optional $ root
+func parseRedactionPath(path string) ([]redactionPathSegment, error) {
+	input := strings.ReplaceAll(strings.TrimSpace(path), "[*]", ".*")
+	input = strings.TrimPrefix(input, "$")
+	if input == "" {
+		return nil, fmt.Errorf("invalid redaction path %q: the path is empty", path)
+	}
+	if !strings.HasPrefix(input, ".") {
+		input = "." + input
+	}
+	var segments []redactionPathSegment
+	for input != "" {
+		var segment redactionPathSegment
+		if strings.HasPrefix(input, "..") {
+			segment.recursive = true
+			input = input[2:]
+		} else {
+			input = input[1:]
+		}
+		end := strings.IndexByte(input, '.')
+		if end < 0 {
+			end = len(input)
+		}
+		segment.name = input[:end]
+		if segment.name == "" {
+			return nil, fmt.Errorf("invalid redaction path %q: empty segment", path)
+		}
+		segments = append(segments, segment)
+		input = input[end:]
+	}
+	return segments, nil
+}
+
+// mask returns the replacement of a redacted value
+func (rd *redactor) mask(value any) string {
+	if !rd.hash {
+		return redactedValue
+	}
+	var input []byte
+	if str, ok := value.(string); ok {
+		input = []byte(str)
+	} else {
+		input, _ = json.Marshal(value)
+	}
+	sum := sha256.Sum256(input)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// isRedactedHeader checks if the values of the header are redacted
+func (rd *redactor) isRedactedHeader(name string) bool {
+	return rd.headers[strings.ToLower(name)]
+}
+
+// redactHeaders returns a copy of the headers with masked values of sensitive headers
+func (rd *redactor) redactHeaders(header http.Header) http.Header {
+	result := make(http.Header, len(header))
+	for key, values := range header {
+		if !rd.isRedactedHeader(key) {
+			result[key] = values
+			continue
+		}
+		masked := make([]string, len(values))
+		for i, value := range values {
+			masked[i] = rd.mask(value)
+		}
+		result[key] = masked
+	}
+	return result
+}
+
+// loggedBody returns the body for logs and span attributes, with redacted fields and truncated at the maximum size.
+// If fields must be redacted but the body can't be decoded as JSON, only its size is returned
+func (rd *redactor) loggedBody(body []byte, maxSize int) string {
+	if len(rd.paths) == 0 || len(body) == 0 {
+		return truncateBody(body, maxSize)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return rd.redactedBodySize(len(body))
+	}
+	for _, segments := range rd.paths {
+		value = rd.redactPath(value, segments)
+	}
+	result, err := json.Marshal(value)
+	if err != nil {
+		return rd.redactedBodySize(len(body))
+	}
+	return truncateBody(result, maxSize)
+}
+
+func (rd *redactor) redactedBodySize(size int) string {
+	return fmt.Sprintf("(%d bytes redacted)", size)
+}
+
+// redactPath masks the values that match the path segments
+func (rd *redactor) redactPath(value any, segments []redactionPathSegment) any {
+	if len(segments) == 0 {
+		return rd.mask(value)
+	}
+	segment := segments[0]
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if segment.name == "*" || segment.name == key {
+				item = rd.redactPath(item, segments[1:])
+			}
+			if segment.recursive {
+				item = rd.redactPath(item, segments)
+			}
+			v[key] = item
+		}
+	case []any:
+		for i, item := range v {
+			if segment.name == "*" || segment.name == strconv.Itoa(i) {
+				item = rd.redactPath(item, segments[1:])
+			}
+			if segment.recursive {
+				item = rd.redactPath(item, segments)
+			}
+			v[i] = item
+		}
+	}
+	return value
+}
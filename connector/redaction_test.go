@@ -0,0 +1,141 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"github.com/hasura/ndc-sdk-go/schema"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRedactorLoggedBody(t *testing.T) {
+	rd, err := newRedactor(RedactionPolicy{
+		BodyPaths: []string{"$.arguments.*", "..value", "items[*].secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"collection":"articles","arguments":{"id":{"type":"literal","value":1}},"predicate":{"type":"binary_comparison_operator","value":{"type":"scalar","value":"alice@example.com"}},"items":[{"secret":"a","name":"b"}]}`
+	expected := `{"arguments":{"id":"[REDACTED]"},"collection":"articles","items":[{"name":"b","secret":"[REDACTED]"}],"predicate":{"type":"binary_comparison_operator","value":"[REDACTED]"}}`
+	if result := rd.loggedBody([]byte(body), 4096); result != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+	if result := rd.loggedBody([]byte(`{"arguments":`), 4096); result != "(13 bytes redacted)" {
+		t.Errorf("expected the size of the invalid body, got %s", result)
+	}
+
+	hashRedactor, err := newRedactor(RedactionPolicy{BodyPaths: []string{"email"}, Hash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := hashRedactor.loggedBody([]byte(`{"email":"alice@example.com"}`), 4096)
+	second := hashRedactor.loggedBody([]byte(`{"email":"alice@example.com"}`), 4096)
+	if first != second || !strings.Contains(first, "sha256:") || strings.Contains(first, "alice") {
+		t.Errorf("expected equal hashes of the value, got %s and %s", first, second)
+	}
+
+	for _, path := range []string{"", "$", "$.a..", "a.b."} {
+		if _, err := newRedactor(RedactionPolicy{BodyPaths: []string{path}}); err == nil {
+			t.Errorf("%q: expected an error", path)
+		}
+	}
+}
+
+func TestRedactorHeaders(t *testing.T) {
+	rd, err := newRedactor(RedactionPolicy{Headers: []string{"X-Session-Id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{
+		"Authorization": []string{"Bearer secret"},
+		"Cookie":        []string{"a=b", "c=d"},
+		"X-Session-Id":  []string{"abc"},
+		"Content-Type":  []string{"application/json"},
+	}
+	expected := http.Header{
+		"Authorization": []string{redactedValue},
+		"Cookie":        []string{redactedValue, redactedValue},
+		"X-Session-Id":  []string{redactedValue},
+		"Content-Type":  []string{"application/json"},
+	}
+	if result := rd.redactHeaders(header); !internal.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("expected the original headers unchanged, got %v", header)
+	}
+}
+
+func TestServerRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration:      "{}",
+		InlineConfig:       true,
+		ServiceTokenSecret: "random-secret",
+		Redaction: RedactionPolicy{
+			BodyPaths: []string{"$.arguments.*"},
+		},
+	}, WithLogger(logger))
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	recorder := tracetest.NewSpanRecorder()
+	server.telemetry.Tracer = &Tracer{trace.NewTracerProvider(trace.WithSpanProcessor(recorder)).Tracer("test")}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	rawBody, err := json.Marshal(schema.QueryRequest{
+		Collection: "articles",
+		Arguments: schema.QueryRequestArguments{
+			"email": {Type: schema.ArgumentTypeLiteral, Value: "alice@example.com"},
+		},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables:               []schema.QueryRequestVariablesElem{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, httpServer.URL+"/query", bytes.NewBuffer(rawBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer random-secret")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "POST /query", res, http.StatusOK)
+
+	if !strings.Contains(buf.String(), redactedValue) {
+		t.Errorf("expected redacted values in logs, got %s", buf.String())
+	}
+	for _, secret := range []string{"random-secret", "alice@example.com"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("expected %s to be redacted in logs, got %s", secret, buf.String())
+		}
+	}
+
+	spans := recorder.Ended()
+	if len(spans) == 0 {
+		t.Fatal("expected recorded spans")
+	}
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			value := attr.Value.Emit()
+			if strings.Contains(value, "random-secret") || strings.Contains(value, "alice@example.com") {
+				t.Errorf("expected %s of span %s to be redacted, got %s", attr.Key, span.Name(), value)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// serverRuntime holds a configuration and the state that is initialized with it.
+// Requests acquire the current runtime of the server, so a reload can drain them before it releases the previous state
+type serverRuntime[Configuration any, State any] struct {
+	configuration *Configuration
+	state         *State
+	// generation increases on every reload of the configuration, from 1
+	generation uint64
+
+	// the encoded responses of the configuration, which a reload invalidates with the runtime
+	schemaResponse       responseCache
+	capabilitiesResponse responseCache
+
+	// the request validator is built lazily from the schema of the configuration
+	validatorLock sync.Mutex
+	validator     *RequestValidator
+
+	// the capabilities of the configuration are parsed once to enforce them on requests
+	capabilitiesOnce sync.Once
+	capabilities     *connectorCapabilities
+	capabilitiesErr  error
+
+	lock    sync.Mutex
+	active  int
+	retired bool
+	drained chan struct{}
+}
+
+func newServerRuntime[Configuration any, State any](configuration *Configuration, state *State) *serverRuntime[Configuration, State] {
+	return &serverRuntime[Configuration, State]{
+		configuration: configuration,
+		state:         state,
+		generation:    1,
+		drained:       make(chan struct{}),
+	}
+}
+
+// acquire registers an in-flight request. It returns false if the runtime is retired
+func (sr *serverRuntime[Configuration, State]) acquire() bool {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if sr.retired {
+		return false
+	}
+	sr.active++
+	return true
+}
+
+// release completes an in-flight request
+func (sr *serverRuntime[Configuration, State]) release() {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	sr.active--
+	if sr.retired && sr.active == 0 {
+		close(sr.drained)
+	}
+}
+
+// retire stops new requests from acquiring the runtime.
+// The returned channel is closed when all in-flight requests complete
+func (sr *serverRuntime[Configuration, State]) retire() <-chan struct{} {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if !sr.retired {
+		sr.retired = true
+		if sr.active == 0 {
+			close(sr.drained)
+		}
+	}
+	return sr.drained
+}
+
+// acquireRuntime acquires the current runtime of the default tenant. The caller must release it when the request completes
+func (s *Server[Configuration, State]) acquireRuntime() *serverRuntime[Configuration, State] {
+	return s.defaultTenant.acquireRuntime()
+}
+
+// Reload parses the configuration and initializes a new state of every tenant.
+// New requests are served with the new configuration and state once they are ready,
+// while in-flight requests complete with the previous ones, which are released afterwards.
+// If the reload fails, the server keeps serving with the previous configuration
+func (s *Server[Configuration, State]) Reload() error {
+	return s.reloadAll()
+}
+
+func (s *Server[Configuration, State]) reloadTenant(t *tenant[Configuration, State]) error {
+	t.reloadLock.Lock()
+	defer t.reloadLock.Unlock()
+	logger := s.logger.With(t.logAttributes()...)
+
+	configuration, err := s.connector.ParseConfiguration(s.context, t.configuration)
+	if err != nil {
+		logger.Error("failed to reload the configuration, keep serving with the previous one", slog.Any("error", err))
+		return err
+	}
+	state, err := s.connector.TryInitState(s.context, configuration, s.telemetry)
+	if err != nil {
+		logger.Error("failed to initialize the state of the reloaded configuration, keep serving with the previous one", slog.Any("error", err))
+		return err
+	}
+
+	next := newServerRuntime(configuration, state)
+	next.generation = t.runtime.Load().generation + 1
+	previous := t.runtime.Swap(next)
+	logger.Info("reloaded the connector configuration")
+
+	go s.releaseRuntime(previous)
+	return nil
+}
+
+// releaseRuntime waits for the in-flight requests of the runtime to complete, then closes its state.
+// Both steps are bounded by the drain timeout
+func (s *Server[Configuration, State]) releaseRuntime(rt *serverRuntime[Configuration, State]) {
+	timeout := s.drainTimeout()
+	select {
+	case <-rt.retire():
+	case <-time.After(timeout):
+		s.logger.Warn("timed out waiting for in-flight requests to complete", slog.Duration("timeout", timeout))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.closeState(ctx, rt.state); err != nil {
+		s.logger.Error("failed to close the connector state", slog.Any("error", err))
+		return
+	}
+	s.logger.Debug("released the connector state")
+}
+
+func (s *Server[Configuration, State]) drainTimeout() time.Duration {
+	if s.options.DrainTimeout <= 0 {
+		return defaultDrainTimeout
+	}
+	return s.options.DrainTimeout
+}
+
+// closeState closes the state with the StateCloser of the connector, or with its own Close method if it implements io.Closer
+func (s *Server[Configuration, State]) closeState(ctx context.Context, state *State) error {
+	var closeFunc func() error
+	if closer, ok := any(s.connector).(StateCloser[State]); ok {
+		closeFunc = func() error {
+			return closer.Close(ctx, state)
+		}
+	} else if closer, ok := any(state).(io.Closer); ok {
+		closeFunc = closer.Close
+	} else {
+		return nil
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- closeFunc()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out closing the state: %w", ctx.Err())
+	}
+}
+
+// handleReloadSignal reloads the connector on SIGHUP until the server stops
+func (s *Server[Configuration, State]) handleReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-s.context.Done():
+			return
+		case <-signals:
+			s.logger.Info("received the reload signal, reloading the configuration...")
+			_ = s.Reload()
+		}
+	}
+}
+
+// watchConfiguration polls the configuration directory of the tenant and reloads it when its files change
+func (s *Server[Configuration, State]) watchConfiguration(t *tenant[Configuration, State], interval time.Duration) {
+	directory := t.configuration
+	logger := s.logger.With(t.logAttributes()...)
+	last, err := fingerprintDirectory(directory)
+	if err != nil {
+		logger.Error("failed to watch the configuration directory", slog.String("directory", directory), slog.Any("error", err))
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.context.Done():
+			return
+		case <-ticker.C:
+			current, err := fingerprintDirectory(directory)
+			if err != nil {
+				logger.Warn("failed to read the configuration directory", slog.String("directory", directory), slog.Any("error", err))
+				continue
+			}
+			if current == last {
+				continue
+			}
+			// a failed reload is retried on the next change only
+			last = current
+			logger.Info("the configuration directory changed, reloading the configuration...")
+			_ = s.reloadTenant(t)
+		}
+	}
+}
+
+// fingerprintDirectory hashes the paths, sizes and modification times of the files in the directory
+func fingerprintDirectory(directory string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(hash, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *Server[Configuration, State]) withAdminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("authorization")), []byte("Bearer "+s.options.AdminTokenSecret)) != 1 {
+			writeJson(w, GetLogger(r.Context()), http.StatusUnauthorized, schema.ErrorResponse{
+				Message: "Unauthorized",
+				Details: map[string]any{
+					"cause": "Bearer token does not match.",
+				},
+			})
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// ReloadHandler implements a handler for the /admin/reload endpoint, POST method that reloads the configuration.
+// It reloads the tenant that the request is routed to, or all tenants
+func (s *Server[Configuration, State]) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	logger := GetLogger(r.Context())
+	reload := s.Reload
+	if name := tenantName(r.Context()); name != "" {
+		reload = func() error {
+			return s.ReloadTenant(name)
+		}
+	}
+	if err := reload(); err != nil {
+		writeError(w, logger, schema.InternalServerError("failed to reload the configuration", map[string]any{
+			"cause": err.Error(),
+		}))
+		return
+	}
+
+	writeJson(w, logger, http.StatusOK, map[string]any{
+		"reloaded": true,
+	})
+}
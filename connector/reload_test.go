@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// reloadConnector increases the configuration version on every parse and records closed states
+type reloadConnector struct {
+	mockConnector
+	version int
+	err     error
+	closed  chan *mockState
+	// blocks Close until the context is canceled
+	blockClose atomic.Bool
+}
+
+func (rc *reloadConnector) Close(ctx context.Context, state *mockState) error {
+	if rc.blockClose.Load() {
+		<-ctx.Done()
+	}
+	rc.closed <- state
+	return nil
+}
+
+func (rc *reloadConnector) ParseConfiguration(ctx context.Context, configurationDir string) (*mockConfiguration, error) {
+	if rc.err != nil {
+		return nil, rc.err
+	}
+	rc.version++
+	return &mockConfiguration{
+		Version: rc.version,
+	}, nil
+}
+
+func TestServerReload(t *testing.T) {
+	connector := &reloadConnector{
+		closed: make(chan *mockState, 10),
+	}
+	server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+		Configuration:    "{}",
+		InlineConfig:     true,
+		AdminTokenSecret: "admin-secret",
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+
+	t.Run("drain_previous_runtime", func(t *testing.T) {
+		inflight := server.acquireRuntime()
+		if err := server.Reload(); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+
+		rt := server.acquireRuntime()
+		defer rt.release()
+		if rt.configuration.Version != 2 || inflight.configuration.Version != 1 {
+			t.Errorf("expected configuration versions 2 and 1, got %d and %d", rt.configuration.Version, inflight.configuration.Version)
+			t.FailNow()
+		}
+		drained := inflight.retire()
+		select {
+		case <-drained:
+			t.Error("expected the previous runtime to wait for in-flight requests")
+			t.FailNow()
+		default:
+		}
+		inflight.release()
+		<-drained
+
+		select {
+		case state := <-connector.closed:
+			if state != inflight.state {
+				t.Error("expected the previous state to be closed")
+			}
+		case <-time.After(time.Second):
+			t.Error("expected the previous state to be closed after draining")
+		}
+	})
+
+	t.Run("close_timeout", func(t *testing.T) {
+		connector.blockClose.Store(true)
+		defer connector.blockClose.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := server.closeState(ctx, &mockState{}); err == nil {
+			t.Error("expected timeout error, got nil")
+		}
+	})
+
+	t.Run("keep_previous_configuration", func(t *testing.T) {
+		connector.err = errors.New("invalid configuration")
+		defer func() {
+			connector.err = nil
+		}()
+		if err := server.Reload(); err == nil {
+			t.Error("expected error, got nil")
+			t.FailNow()
+		}
+
+		rt := server.acquireRuntime()
+		defer rt.release()
+		if rt.configuration.Version != 2 {
+			t.Errorf("expected configuration version 2, got %d", rt.configuration.Version)
+		}
+	})
+
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	t.Run("Unauthorized POST /admin/reload", func(t *testing.T) {
+		res, err := httpPostJSON(fmt.Sprintf("%s/admin/reload", httpServer.URL), map[string]any{})
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponse(t, res, http.StatusUnauthorized, schema.ErrorResponse{
+			Message: "Unauthorized",
+			Details: map[string]any{
+				"cause": "Bearer token does not match.",
+			},
+		})
+	})
+
+	t.Run("Authorized POST /admin/reload", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/reload", httpServer.URL), nil)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponse(t, res, http.StatusOK, map[string]any{
+			"reloaded": true,
+		})
+
+		rt := server.acquireRuntime()
+		defer rt.release()
+		if rt.configuration.Version != 3 {
+			t.Errorf("expected configuration version 3, got %d", rt.configuration.Version)
+		}
+	})
+}
+
+func TestServerRetiredRuntime(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	// the runtime is retired without a replacement, as at shutdown
+	<-server.defaultTenant.runtime.Load().retire()
+	if rt := server.acquireRuntime(); rt != nil {
+		t.Error("expected no runtime after the runtime is retired")
+	}
+	res, err := http.Get(httpServer.URL + "/schema")
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponse(t, res, http.StatusServiceUnavailable, schema.ErrorResponse{
+		Message: "the server is shutting down",
+		Details: map[string]any{},
+	})
+}
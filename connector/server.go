@@ -2,8 +2,11 @@ package connector
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
@@ -11,6 +14,8 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/hasura/ndc-sdk-go/schema"
@@ -24,12 +29,82 @@ import (
 // ServerOptions presents the configuration object of the connector http server
 type ServerOptions struct {
 	OTLPConfig
+	TLSConfig
 
 	Configuration      string
 	InlineConfig       bool
 	ServiceTokenSecret string
+	// ServiceTokenSecrets are additional accepted service tokens, to rotate them without downtime
+	ServiceTokenSecrets []string
+	// ServiceTokenFile is the path of a file that lists accepted service tokens, one per line.
+	// It is read again when it changes
+	ServiceTokenFile string
+	// JWKSFile is the path of a JSON Web Key Set file to validate HS256 and RS256 JSON Web Tokens
+	JWKSFile string
+	// JWTIssuer is the expected issuer of JSON Web Tokens
+	JWTIssuer string
+	// JWTAudience is the expected audience of JSON Web Tokens
+	JWTAudience string
+	// AdminTokenSecret authorizes the admin endpoints, which are disabled if it is empty
+	AdminTokenSecret string
+	// AdminPort is the port of the admin listener, which serves the log level, build info and pprof endpoints
+	// with the admin token. The listener is disabled if it is zero, and requires AdminTokenSecret otherwise
+	AdminPort uint
+	// DrainTimeout is the maximum duration to wait for in-flight requests and to close the state
+	// on shutdown and after a reload. The default is 30 seconds
+	DrainTimeout time.Duration
+	// ShutdownDelay is the duration to keep serving requests with the failing health check after the quit signal,
+	// so load balancers stop sending new requests before the listener closes
+	ShutdownDelay time.Duration
+	// ConfigurationWatchInterval is the interval to poll the configuration directory for changes
+	// and reload the connector. Watching is disabled if it is zero
+	ConfigurationWatchInterval time.Duration
+	// QueryConcurrencyLimit is the maximum number of queries that execute at the same time. It is unlimited if zero
+	QueryConcurrencyLimit int
+	// QueryQueueSize is the maximum number of queries that wait for an execution slot
+	QueryQueueSize int
+	// MutationConcurrencyLimit is the maximum number of mutations that execute at the same time. It is unlimited if zero
+	MutationConcurrencyLimit int
+	// MutationQueueSize is the maximum number of mutations that wait for an execution slot
+	MutationQueueSize int
+	// QueueTimeout is the maximum duration that a request waits for an execution slot. The default is 10 seconds
+	QueueTimeout time.Duration
+	// MaxRequestBodySize is the maximum size in bytes of request bodies. The default is 10 MiB.
+	// The body size is unlimited if it is negative
+	MaxRequestBodySize int64
+	// MaxJSONDepth is the maximum nesting depth of JSON request bodies. The default is 64
+	MaxJSONDepth int
+	// DebugBodyLogSize is the maximum size in bytes of request and response bodies in debug logs and spans.
+	// The default is 4096
+	DebugBodyLogSize int
+	// DisableCompression disables the gzip and zstd compression of responses
+	DisableCompression bool
+	// CompressionMinSize is the minimum size in bytes of compressed responses. The default is 1024
+	CompressionMinSize int
+	// CapabilityEnforcement is the mode to handle requests that use capabilities that the connector doesn't advertise.
+	// The default is warn, which logs them. The strict mode rejects them with the 501 status
+	CapabilityEnforcement CapabilityEnforcement
+	// HealthCheckInterval is the interval of the background health checks that drive the /readyz endpoint.
+	// The default is 10 seconds
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout is the maximum duration of a background health check. The default is 5 seconds
+	HealthCheckTimeout time.Duration
+	// Tenants are configurations that the server serves alongside the default one, each with its own state and service token.
+	// Requests are routed to a tenant by the /{tenant} path prefix or the tenant header.
+	// If the configuration of the default tenant is empty, requests without a tenant are rejected
+	Tenants []Tenant
+	// TenantHeader is the request header that selects the tenant. The default is X-Hasura-Tenant
+	TenantHeader string
+	// MetricsOperationAllowlist is the list of collection, function and procedure names that are attached to metrics.
+	// Other names are attached as "other", so the cardinality of metric attributes is capped. All names are attached if it is empty
+	MetricsOperationAllowlist []string
+	// Redaction is the policy to mask sensitive headers and body fields in debug logs and span attributes.
+	// The Authorization, Cookie and other credential headers are always redacted
+	Redaction RedactionPolicy
 }
 
+const defaultDrainTimeout = 30 * time.Second
+
 // Server implements the [NDC API specification] for the connector
 //
 // [NDC API specification]: https://hasura.github.io/ndc-spec/specification/index.html
@@ -39,10 +114,22 @@ type Server[Configuration any, State any] struct {
 	context       context.Context
 	stop          context.CancelFunc
 	connector     Connector[Configuration, State]
-	state         *State
-	configuration *Configuration
 	options       *ServerOptions
+	tlsConfig     *tls.Config
 	telemetry     *TelemetryState
+	authenticator Authenticator
+	// the TLS configuration of the Prometheus and admin listeners, which don't require client certificates unless the mode is mtls
+	auxiliaryTLSConfig *tls.Config
+	// serves requests without a tenant. It is nil if the server only serves named tenants
+	defaultTenant *tenant[Configuration, State]
+	// named tenants, keyed by name
+	tenants map[string]*tenant[Configuration, State]
+	// set when the server is shutting down, so the health check fails while in-flight requests drain
+	draining atomic.Bool
+	// the collection and operation names that are attached to metrics. It is nil if all names are allowed
+	metricsAllowlist map[string]bool
+	// masks sensitive headers and body fields in logs and span attributes
+	redactor *redactor
 }
 
 // NewServer creates a Server instance
@@ -61,76 +148,193 @@ func NewServer[Configuration any, State any](connector Connector[Configuration,
 		slog.String("metrics_prefix", defaultOptions.metricsPrefix),
 	)
 
-	// Handle SIGINT (CTRL+C) gracefully.
-	ctx, stop := signal.NotifyContext(context.WithValue(context.TODO(), logContextKey, defaultOptions.logger), os.Interrupt)
+	// Handle SIGINT (CTRL+C) and SIGTERM gracefully.
+	ctx, stop := signal.NotifyContext(context.WithValue(context.TODO(), logContextKey, defaultOptions.logger), os.Interrupt, syscall.SIGTERM)
 
-	configuration, err := connector.ParseConfiguration(ctx, options.Configuration)
+	if err := options.CapabilityEnforcement.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateTenants(options.Tenants); err != nil {
+		return nil, err
+	}
+	if options.AdminPort > 0 && options.AdminTokenSecret == "" {
+		return nil, errors.New("the admin listener requires an admin token secret")
+	}
+	redactor, err := newRedactor(options.Redaction)
 	if err != nil {
 		return nil, err
 	}
 
+	// a server of named tenants only has a default tenant if its configuration is set
+	hasDefaultTenant := len(options.Tenants) == 0 || options.Configuration != ""
+	var configuration *Configuration
+	if hasDefaultTenant {
+		configuration, err = connector.ParseConfiguration(ctx, options.Configuration)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	telemetry, err := setupOTelSDK(ctx, &options.OTLPConfig, defaultOptions.version, defaultOptions.metricsPrefix, defaultOptions.logger)
 	if err != nil {
 		return nil, err
 	}
+	// the telemetry logger adds trace IDs to logs and exports them
+	defaultOptions.logger = telemetry.Logger
+	ctx = context.WithValue(ctx, logContextKey, telemetry.Logger)
+
+	var state *State
+	if hasDefaultTenant {
+		state, err = connector.TryInitState(ctx, configuration, telemetry)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	state, err := connector.TryInitState(ctx, configuration, telemetry)
+	tlsConfig, err := newTLSConfig(options.TLSConfig, defaultOptions.logger)
+	if err != nil {
+		return nil, err
+	}
+	auxiliaryTLSConfig, err := newAuxiliaryTLSConfig(options.TLSConfig, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Server[Configuration, State]{
+	authenticator := defaultOptions.authenticator
+	if authenticator == nil {
+		authenticator, err = newAuthenticator(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server := &Server[Configuration, State]{
 		context:       ctx,
 		stop:          stop,
 		connector:     connector,
-		state:         state,
-		configuration: configuration,
 		options:       options,
+		tlsConfig:     tlsConfig,
 		telemetry:     telemetry,
+		authenticator: authenticator,
 		serveOptions:  defaultOptions,
-	}, nil
+		tenants:       make(map[string]*tenant[Configuration, State]),
+
+		auxiliaryTLSConfig: auxiliaryTLSConfig,
+
+		metricsAllowlist: newMetricsAllowlist(options.MetricsOperationAllowlist),
+		redactor:         redactor,
+	}
+	if hasDefaultTenant {
+		server.defaultTenant = &tenant[Configuration, State]{
+			configuration: options.Configuration,
+			authenticator: authenticator,
+		}
+		server.defaultTenant.runtime.Store(newServerRuntime(configuration, state))
+	}
+	for _, tenantOptions := range options.Tenants {
+		t, err := server.newTenant(ctx, tenantOptions)
+		if err != nil {
+			return nil, err
+		}
+		server.tenants[t.name] = t
+	}
+
+	return server, nil
 }
 
 func (s *Server[Configuration, State]) withAuth(handler http.HandlerFunc) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		authenticator := s.authenticator
+		if t := s.requestTenant(r.Context()); t != nil {
+			authenticator = t.authenticator
+		}
+		if authenticator == nil {
+			handler(w, r)
+			return
+		}
+
 		logger := GetLogger(r.Context())
-		if s.options.ServiceTokenSecret != "" && r.Header.Get("authorization") != fmt.Sprintf("Bearer %s", s.options.ServiceTokenSecret) {
+		claims, err := authenticator.Authenticate(r)
+		if err != nil {
 			writeJson(w, logger, http.StatusUnauthorized, schema.ErrorResponse{
 				Message: "Unauthorized",
 				Details: map[string]any{
-					"cause": "Bearer token does not match.",
+					"cause": err.Error(),
 				},
 			})
 
-			s.telemetry.queryCounter.Add(r.Context(), 1, metric.WithAttributes(
+			s.telemetry.queryCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
 				failureStatusAttribute,
 				httpStatusAttribute(http.StatusUnauthorized),
 			))
 			return
 		}
 
+		if claims != nil {
+			r = r.WithContext(context.WithValue(r.Context(), claimsContextKey, claims))
+		}
 		handler(w, r)
 	}
 }
 
+// acquireRequestRuntime acquires the runtime of the tenant of the request. It responds 503 and returns nil
+// if the runtime was retired without a replacement, when the server shuts down
+func (s *Server[Configuration, State]) acquireRequestRuntime(w http.ResponseWriter, r *http.Request) *serverRuntime[Configuration, State] {
+	rt := s.requestTenant(r.Context()).acquireRuntime()
+	if rt == nil {
+		writeJson(w, GetLogger(r.Context()), http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the server is shutting down",
+			Details: map[string]any{},
+		})
+	}
+	return rt
+}
+
 // GetCapabilities get the connector's capabilities. Implement a handler for the /capabilities endpoint, GET method.
 func (s *Server[Configuration, State]) GetCapabilities(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
-	capabilities := s.connector.GetCapabilities(s.configuration)
-	if capabilities == nil {
-		writeError(w, logger, schema.InternalServerError("capabilities is empty", nil))
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
 		return
 	}
-	writeJsonFunc(w, logger, http.StatusOK, func() ([]byte, error) {
-		return capabilities.MarshalCapabilitiesJSON()
+	defer rt.release()
+	response, err := rt.capabilitiesResponse.get(func() ([]byte, error) {
+		capabilities := s.connector.GetCapabilities(rt.configuration)
+		if capabilities == nil {
+			return nil, schema.InternalServerError("capabilities is empty", nil)
+		}
+		capabilitiesBytes, err := capabilities.MarshalCapabilitiesJSON()
+		if err != nil {
+			return nil, schema.InternalServerError("failed to encode capabilities", map[string]any{
+				"cause": err.Error(),
+			})
+		}
+		return capabilitiesBytes, nil
 	})
+	if err != nil {
+		writeError(w, logger, err)
+		return
+	}
+	writeCachedResponse(w, r, logger, response)
 }
 
 // Health checks the health of the connector. Implement a handler for the /health endpoint, GET method.
 func (s *Server[Configuration, State]) Health(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
-	if err := s.connector.HealthCheck(r.Context(), s.configuration, s.state); err != nil {
+	if s.draining.Load() {
+		writeJson(w, logger, http.StatusServiceUnavailable, schema.ErrorResponse{
+			Message: "the server is shutting down",
+			Details: map[string]any{},
+		})
+		return
+	}
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
+		return
+	}
+	defer rt.release()
+	if err := s.connector.HealthCheck(r.Context(), rt.configuration, rt.state); err != nil {
 		writeError(w, logger, err)
 		return
 	}
@@ -139,21 +343,46 @@ func (s *Server[Configuration, State]) Health(w http.ResponseWriter, r *http.Req
 }
 
 // GetSchema implements a handler for the /schema endpoint, GET method.
+// The encoded schema is cached for the configuration, unless the server is created with [WithDynamicSchema]
 func (s *Server[Configuration, State]) GetSchema(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r.Context())
-	schemaResult, err := s.connector.GetSchema(r.Context(), s.configuration, s.state)
-	if err != nil {
-		writeError(w, logger, err)
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
 		return
 	}
-	if schemaResult == nil {
-		writeError(w, logger, schema.InternalServerError("schema is empty", nil))
-		return
+	defer rt.release()
+	encodeSchema := func() ([]byte, error) {
+		schemaResult, err := s.connector.GetSchema(r.Context(), rt.configuration, rt.state)
+		if err != nil {
+			return nil, err
+		}
+		if schemaResult == nil {
+			return nil, schema.InternalServerError("schema is empty", nil)
+		}
+		schemaBytes, err := schemaResult.MarshalSchemaJSON()
+		if err != nil {
+			return nil, schema.InternalServerError("failed to encode schema", map[string]any{
+				"cause": err.Error(),
+			})
+		}
+		return schemaBytes, nil
 	}
 
-	writeJsonFunc(w, logger, http.StatusOK, func() ([]byte, error) {
-		return schemaResult.MarshalSchemaJSON()
-	})
+	var response *cachedResponse
+	var err error
+	if s.dynamicSchema {
+		var schemaBytes []byte
+		if schemaBytes, err = encodeSchema(); err == nil {
+			response = newCachedResponse(schemaBytes)
+		}
+	} else {
+		response, err = rt.schemaResponse.get(encodeSchema)
+	}
+	if err != nil {
+		writeError(w, logger, err)
+		return
+	}
+	writeCachedResponse(w, r, logger, response)
 }
 
 // Query implements a handler for the /query endpoint, POST method that executes a query.
@@ -166,19 +395,26 @@ func (s *Server[Configuration, State]) Query(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	collectionAttr := attribute.String("collection", body.Collection)
-	span.SetAttributes(collectionAttr)
+	// spans have the names out of the metrics allowlist too
+	spanCollectionAttr := attribute.String("collection", body.Collection)
+	collectionAttr := attribute.String("collection", s.metricsOperationName(body.Collection))
+	span.SetAttributes(spanCollectionAttr)
 	span.AddEvent("execute_query", trace.WithAttributes(
-		collectionAttr,
+		spanCollectionAttr,
 	))
 	execQueryCtx, execQuerySpan := s.telemetry.Tracer.Start(r.Context(), "ndc_execute_query")
 	defer execQuerySpan.End()
 
-	response, err := s.connector.Query(execQueryCtx, s.configuration, s.state, &body)
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
+		return
+	}
+	defer rt.release()
+	response, err := s.executeQuery(execQueryCtx, rt, &body)
 
 	if err != nil {
 		status := writeError(w, logger, err)
-		s.telemetry.queryCounter.Add(r.Context(), 1, metric.WithAttributes(
+		s.telemetry.queryCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
 			collectionAttr,
 			failureStatusAttribute,
 			httpStatusAttribute(status),
@@ -188,11 +424,56 @@ func (s *Server[Configuration, State]) Query(w http.ResponseWriter, r *http.Requ
 	execQuerySpan.End()
 
 	span.AddEvent("ndc_query_response")
-	writeJson(w, logger, http.StatusOK, response)
+	recordRows := s.countRows(r.Context(), response, collectionAttr.Value.AsString())
+	err = writeQueryResponse(w, response)
+	recordRows()
+	if closeErr := response.Close(); closeErr != nil {
+		logger.Error("failed to close row iterators", slog.Any("error", closeErr))
+	}
+	if err != nil {
+		logger.Error("failed to write query response", slog.Any("error", err))
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+		s.telemetry.queryCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
+			collectionAttr,
+			failureStatusAttribute,
+			httpStatusAttribute(http.StatusOK),
+		))
+		return
+	}
 
-	s.telemetry.queryCounter.Add(r.Context(), 1, metric.WithAttributes(collectionAttr, successStatusAttribute))
+	s.telemetry.queryCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(collectionAttr, successStatusAttribute))
 	// record latency for success requests only
-	s.telemetry.queryLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), metric.WithAttributes(collectionAttr))
+	s.telemetry.queryLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), tenantAttributes(r.Context()), metric.WithAttributes(collectionAttr))
+}
+
+// executeQuery executes the query with the streaming method of the connector if it implements [QueryStreamer]
+func (s *Server[Configuration, State]) executeQuery(ctx context.Context, rt *serverRuntime[Configuration, State], body *schema.QueryRequest) (StreamingQueryResponse, error) {
+	if err := s.checkQueryCapabilities(ctx, rt, body, false); err != nil {
+		return nil, err
+	}
+	if err := s.validateQueryRequest(ctx, rt, body); err != nil {
+		return nil, err
+	}
+	if streamer, ok := any(s.connector).(QueryStreamer[Configuration, State]); ok {
+		return streamer.QueryStream(ctx, rt.configuration, rt.state, body)
+	}
+	response, err := s.connector.Query(ctx, rt.configuration, rt.state, body)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamingQueryResponse(response), nil
+}
+
+// explainQuery validates the query request and explains it
+func (s *Server[Configuration, State]) explainQuery(ctx context.Context, rt *serverRuntime[Configuration, State], body *schema.QueryRequest) (*schema.ExplainResponse, error) {
+	if err := s.checkQueryCapabilities(ctx, rt, body, true); err != nil {
+		return nil, err
+	}
+	if err := s.validateQueryRequest(ctx, rt, body); err != nil {
+		return nil, err
+	}
+	return s.connector.QueryExplain(ctx, rt.configuration, rt.state, body)
 }
 
 // QueryExplain implements a handler for the /query/explain endpoint, POST method that explains a query by creating an execution plan.
@@ -206,22 +487,28 @@ func (s *Server[Configuration, State]) QueryExplain(w http.ResponseWriter, r *ht
 		return
 	}
 
-	collectionAttr := attribute.String("collection", body.Collection)
-	span.SetAttributes(collectionAttr)
+	spanCollectionAttr := attribute.String("collection", body.Collection)
+	collectionAttr := attribute.String("collection", s.metricsOperationName(body.Collection))
+	span.SetAttributes(spanCollectionAttr)
 
 	span.AddEvent("execute_query_plain", trace.WithAttributes(
-		collectionAttr,
+		spanCollectionAttr,
 	))
 	execCtx, execSpan := s.telemetry.Tracer.Start(r.Context(), "ndc_execute_plan")
 	defer execSpan.End()
 
-	response, err := s.connector.QueryExplain(execCtx, s.configuration, s.state, &body)
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
+		return
+	}
+	defer rt.release()
+	response, err := s.explainQuery(execCtx, rt, &body)
 	if err != nil {
 		status := writeError(w, logger, err)
 		span.SetStatus(codes.Error, err.Error())
 		span.RecordError(err)
 
-		s.telemetry.queryExplainCounter.Add(r.Context(), 1, metric.WithAttributes(
+		s.telemetry.queryExplainCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
 			failureStatusAttribute,
 			httpStatusAttribute(status),
 			collectionAttr,
@@ -232,10 +519,21 @@ func (s *Server[Configuration, State]) QueryExplain(w http.ResponseWriter, r *ht
 
 	span.AddEvent("query_explain_response")
 	writeJson(w, logger, http.StatusOK, response)
-	s.telemetry.queryExplainCounter.Add(r.Context(), 1, metric.WithAttributes(successStatusAttribute, collectionAttr))
+	s.telemetry.queryExplainCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(successStatusAttribute, collectionAttr))
 
 	// record latency for success requests only
-	s.telemetry.queryExplainLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), metric.WithAttributes(collectionAttr))
+	s.telemetry.queryExplainLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), tenantAttributes(r.Context()), metric.WithAttributes(collectionAttr))
+}
+
+// explainMutation validates the mutation request and explains it
+func (s *Server[Configuration, State]) explainMutation(ctx context.Context, rt *serverRuntime[Configuration, State], body *schema.MutationRequest) (*schema.ExplainResponse, error) {
+	if err := s.checkMutationCapabilities(ctx, rt, body, true); err != nil {
+		return nil, err
+	}
+	if err := s.validateMutationRequest(ctx, rt, body); err != nil {
+		return nil, err
+	}
+	return s.connector.MutationExplain(ctx, rt.configuration, rt.state, body)
 }
 
 // MutationExplain implements a handler for the /mutation/explain endpoint, POST method that explains a mutation by creating an execution plan.
@@ -253,22 +551,28 @@ func (s *Server[Configuration, State]) MutationExplain(w http.ResponseWriter, r
 		operationNames = append(operationNames, op.Name)
 	}
 
-	operationAttr := attribute.String("operations", strings.Join(operationNames, ","))
-	span.SetAttributes(operationAttr)
+	spanOperationAttr := attribute.String("operations", strings.Join(operationNames, ","))
+	operationAttr := s.metricsMutationOperations(body.Operations)
+	span.SetAttributes(spanOperationAttr)
 
 	span.AddEvent("execute_mutation_plain", trace.WithAttributes(
-		operationAttr,
+		spanOperationAttr,
 	))
 	execCtx, execSpan := s.telemetry.Tracer.Start(r.Context(), "ndc_execute_plan")
 	defer execSpan.End()
 
-	response, err := s.connector.MutationExplain(execCtx, s.configuration, s.state, &body)
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
+		return
+	}
+	defer rt.release()
+	response, err := s.explainMutation(execCtx, rt, &body)
 	if err != nil {
 		status := writeError(w, logger, err)
 
 		span.SetStatus(codes.Error, err.Error())
 		span.RecordError(err)
-		s.telemetry.mutationExplainCounter.Add(r.Context(), 1, metric.WithAttributes(
+		s.telemetry.mutationExplainCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
 			failureStatusAttribute,
 			httpStatusAttribute(status),
 			operationAttr,
@@ -279,10 +583,21 @@ func (s *Server[Configuration, State]) MutationExplain(w http.ResponseWriter, r
 
 	span.AddEvent("mutation_explain_response")
 	writeJson(w, logger, http.StatusOK, response)
-	s.telemetry.mutationExplainCounter.Add(r.Context(), 1, metric.WithAttributes(successStatusAttribute, operationAttr))
+	s.telemetry.mutationExplainCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(successStatusAttribute, operationAttr))
 
 	// record latency for success requests only
-	s.telemetry.mutationExplainLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), metric.WithAttributes(operationAttr))
+	s.telemetry.mutationExplainLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), tenantAttributes(r.Context()), metric.WithAttributes(operationAttr))
+}
+
+// executeMutation validates the mutation request and executes it
+func (s *Server[Configuration, State]) executeMutation(ctx context.Context, rt *serverRuntime[Configuration, State], body *schema.MutationRequest) (*schema.MutationResponse, error) {
+	if err := s.checkMutationCapabilities(ctx, rt, body, false); err != nil {
+		return nil, err
+	}
+	if err := s.validateMutationRequest(ctx, rt, body); err != nil {
+		return nil, err
+	}
+	return s.connector.Mutation(ctx, rt.configuration, rt.state, body)
 }
 
 // Mutation implements a handler for the /mutation endpoint, POST method that executes a mutation.
@@ -301,25 +616,32 @@ func (s *Server[Configuration, State]) Mutation(w http.ResponseWriter, r *http.R
 		operationNames = append(operationNames, op.Name)
 	}
 
-	operationAttr := attribute.String("operations", strings.Join(operationNames, ","))
-	span.SetAttributes(operationAttr)
+	spanOperationAttr := attribute.String("operations", strings.Join(operationNames, ","))
+	operationAttr := s.metricsMutationOperations(body.Operations)
+	span.SetAttributes(spanOperationAttr)
 
 	span.AddEvent("execute_mutation", trace.WithAttributes(
-		operationAttr,
+		spanOperationAttr,
 	))
 	execCtx, execSpan := s.telemetry.Tracer.Start(r.Context(), "ndc_execute_mutation")
 	defer execSpan.End()
-	response, err := s.connector.Mutation(execCtx, s.configuration, s.state, &body)
+	rt := s.acquireRequestRuntime(w, r)
+	if rt == nil {
+		return
+	}
+	defer rt.release()
+	response, err := s.executeMutation(execCtx, rt, &body)
 	if err != nil {
 		status := writeError(w, logger, err)
 		span.SetStatus(codes.Error, err.Error())
 		span.RecordError(err)
 
-		s.telemetry.mutationCounter.Add(r.Context(), 1, metric.WithAttributes(
+		s.telemetry.mutationCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
 			failureStatusAttribute,
 			httpStatusAttribute(status),
 			operationAttr,
 		))
+		s.recordMutationOperations(r.Context(), body.Operations, failureStatusAttribute)
 		return
 	}
 	execSpan.End()
@@ -327,24 +649,52 @@ func (s *Server[Configuration, State]) Mutation(w http.ResponseWriter, r *http.R
 	span.AddEvent("mutation_response")
 	writeJson(w, logger, http.StatusOK, response)
 
-	s.telemetry.mutationCounter.Add(r.Context(), 1, metric.WithAttributes(successStatusAttribute, operationAttr))
+	s.telemetry.mutationCounter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(successStatusAttribute, operationAttr))
+	s.recordMutationOperations(r.Context(), body.Operations, successStatusAttribute)
 
 	// record latency for success requests only
-	s.telemetry.mutationLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), metric.WithAttributes(operationAttr))
+	s.telemetry.mutationLatencyHistogram.Record(r.Context(), time.Since(startTime).Seconds(), tenantAttributes(r.Context()), metric.WithAttributes(operationAttr))
 }
 
 // the common unmarshal json body method
 func (s *Server[Configuration, State]) unmarshalBodyJSON(w http.ResponseWriter, r *http.Request, span trace.Span, counter metric.Int64Counter, body any) error {
 	span.AddEvent("decode_body_json")
-	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
-		writeJson(w, GetLogger(r.Context()), http.StatusUnprocessableEntity, schema.ErrorResponse{
+	logger := GetLogger(r.Context())
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		statusCode := http.StatusUnprocessableEntity
+		if limit, ok := isRequestBodyTooLarge(err); ok {
+			statusCode = http.StatusRequestEntityTooLarge
+			writeRequestBodyTooLarge(w, logger, limit)
+		} else {
+			writeJson(w, logger, statusCode, schema.ErrorResponse{
+				Message: "failed to read request body",
+				Details: map[string]any{
+					"cause": err.Error(),
+				},
+			})
+		}
+
+		counter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
+			failureStatusAttribute,
+			httpStatusAttribute(statusCode),
+		))
+		return err
+	}
+
+	err = checkJSONDepth(bodyBytes, s.maxJSONDepth())
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, body)
+	}
+	if err != nil {
+		writeJson(w, logger, http.StatusUnprocessableEntity, schema.ErrorResponse{
 			Message: "failed to decode json request body",
 			Details: map[string]any{
 				"cause": err.Error(),
 			},
 		})
 
-		counter.Add(r.Context(), 1, metric.WithAttributes(
+		counter.Add(r.Context(), 1, tenantAttributes(r.Context()), metric.WithAttributes(
 			failureStatusAttribute,
 			httpStatusAttribute(http.StatusUnprocessableEntity),
 		))
@@ -354,19 +704,39 @@ func (s *Server[Configuration, State]) unmarshalBodyJSON(w http.ResponseWriter,
 	return nil
 }
 
-func (s *Server[Configuration, State]) buildHandler() *http.ServeMux {
+func (s *Server[Configuration, State]) buildHandler() http.Handler {
 	router := newRouter(s.logger, s.telemetry, !s.withoutRecovery)
-	router.Use("/capabilities", http.MethodGet, s.withAuth(s.GetCapabilities))
-	router.Use("/schema", http.MethodGet, s.withAuth(s.GetSchema))
-	router.Use("/query", http.MethodPost, s.withAuth(s.Query))
-	router.Use("/query/explain", http.MethodPost, s.withAuth(s.QueryExplain))
-	router.Use("/mutation/explain", http.MethodPost, s.withAuth(s.MutationExplain))
-	router.Use("/mutation", http.MethodPost, s.withAuth(s.Mutation))
-	router.Use("/health", http.MethodGet, s.Health)
+	router.maxLogBodySize = s.debugBodyLogSize()
+	router.compressionEnabled = !s.options.DisableCompression
+	router.redactor = s.redactor
+	if s.options.CompressionMinSize > 0 {
+		router.compressionMinSize = s.options.CompressionMinSize
+	}
+	use := func(path string, method string, handler http.HandlerFunc) {
+		router.Use(path, method, s.withMiddlewares(path, handler))
+		router.SetBodyLimit(path, s.requestBodyLimit(path))
+	}
+	// the concurrency limit applies after the authentication, so unauthorized requests don't take execution slots
+	limit := s.withConcurrencyLimit
+	use("/capabilities", http.MethodGet, s.withTenant(s.withAuth(limit("/capabilities", s.GetCapabilities))))
+	use("/schema", http.MethodGet, s.withTenant(s.withAuth(limit("/schema", s.GetSchema))))
+	use("/query", http.MethodPost, s.withTenant(s.withAuth(limit("/query", s.Query))))
+	use("/query/explain", http.MethodPost, s.withTenant(s.withAuth(limit("/query/explain", s.QueryExplain))))
+	use("/mutation/explain", http.MethodPost, s.withTenant(s.withAuth(limit("/mutation/explain", s.MutationExplain))))
+	use("/mutation", http.MethodPost, s.withTenant(s.withAuth(limit("/mutation", s.Mutation))))
+	use("/health", http.MethodGet, s.withTenant(limit("/health", s.Health)))
+	use("/livez", http.MethodGet, limit("/livez", s.Livez))
+	use("/readyz", http.MethodGet, limit("/readyz", s.Readyz))
+	if s.options.AdminTokenSecret != "" {
+		use("/admin/reload", http.MethodPost, s.withAdminAuth(s.ReloadHandler))
+	}
 	if s.options.MetricsExporter == string(otelMetricsExporterPrometheus) && s.options.PrometheusPort == nil {
-		router.Use("/metrics", http.MethodGet, s.withAuth(promhttp.Handler().ServeHTTP))
+		use("/metrics", http.MethodGet, s.withAuth(promhttp.Handler().ServeHTTP))
 	}
 
+	if len(s.tenants) > 0 {
+		return s.routeTenant(router.Build())
+	}
 	return router.Build()
 }
 
@@ -388,31 +758,59 @@ func (s *Server[Configuration, State]) ListenAndServe(port uint) error {
 			)
 		}
 	}()
+	defer func() {
+		for _, t := range s.allTenants() {
+			s.releaseRuntime(t.runtime.Load())
+		}
+	}()
 
 	server := http.Server{
 		Addr: fmt.Sprintf(":%d", port),
 		BaseContext: func(_ net.Listener) context.Context {
-			return s.context
+			// in-flight requests must not be canceled by the quit signal while they drain
+			return context.WithoutCancel(s.context)
 		},
-		Handler: s.buildHandler(),
+		Handler:   s.buildHandler(),
+		TLSConfig: s.tlsConfig,
 	}
 
 	serverErr := make(chan error, 1)
 	go func() {
 		s.logger.Info(fmt.Sprintf("Listening server on %s", server.Addr))
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		if err := listenAndServe(&server); err != http.ErrServerClosed {
 			serverErr <- err
 		}
 	}()
 
+	go s.handleReloadSignal()
+	go s.watchHealth(s.healthCheckInterval())
+	if s.options.ConfigurationWatchInterval > 0 {
+		for _, t := range s.allTenants() {
+			go s.watchConfiguration(t, s.options.ConfigurationWatchInterval)
+		}
+	}
+
+	if s.options.AdminPort > 0 {
+		adminServer := createAdminServer(s.options.AdminPort, s.buildAdminHandler(), s.auxiliaryTLSConfig)
+		defer func() {
+			_ = shutdownHTTPServer(adminServer, s.drainTimeout())
+		}()
+		go func() {
+			s.logger.Info(fmt.Sprintf("Listening admin server on %d", s.options.AdminPort))
+			if err := listenAndServe(adminServer); err != http.ErrServerClosed {
+				serverErr <- err
+			}
+		}()
+	}
+
 	if s.options.MetricsExporter == string(otelMetricsExporterPrometheus) && s.options.PrometheusPort != nil {
-		promServer := createPrometheusServer(*s.options.PrometheusPort)
+		promServer := createPrometheusServer(*s.options.PrometheusPort, s.auxiliaryTLSConfig)
 		defer func() {
-			_ = promServer.Shutdown(context.Background())
+			_ = shutdownHTTPServer(promServer, s.drainTimeout())
 		}()
 		go func() {
 			s.logger.Info(fmt.Sprintf("Listening prometheus server on %d", *s.options.PrometheusPort))
-			if err := promServer.ListenAndServe(); err != http.ErrServerClosed {
+			if err := listenAndServe(promServer); err != http.ErrServerClosed {
 				serverErr <- err
 			}
 		}()
@@ -424,21 +822,50 @@ func (s *Server[Configuration, State]) ListenAndServe(port uint) error {
 		// Error when starting HTTP server.
 		return err
 	case <-s.context.Done():
-		// Wait for first CTRL+C.
-		s.logger.Info("received the quit signal, exiting...")
+		// Wait for first CTRL+C or SIGTERM.
+		s.logger.Info("received the quit signal, draining in-flight requests...",
+			slog.Duration("shutdown_delay", s.options.ShutdownDelay),
+			slog.Duration("drain_timeout", s.drainTimeout()),
+		)
 		// Stop receiving signal notifications as soon as possible.
 		s.stop()
+		// the health check fails during the delay, so load balancers stop sending requests before the listener closes
+		s.draining.Store(true)
+		if s.options.ShutdownDelay > 0 {
+			time.Sleep(s.options.ShutdownDelay)
+		}
 		// When Shutdown is called, ListenAndServe immediately returns ErrServerClosed.
-		return server.Shutdown(context.Background())
+		return shutdownHTTPServer(&server, s.drainTimeout())
 	}
 }
 
-func createPrometheusServer(port uint) *http.Server {
+// shutdownHTTPServer gracefully shuts down the server, and closes the remaining connections if they don't finish before the timeout
+func shutdownHTTPServer(server *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.Join(err, server.Close())
+	}
+	return err
+}
+
+func createPrometheusServer(port uint, tlsConfig *tls.Config) *http.Server {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 
 	return &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+}
+
+// listenAndServe serves HTTPS if the server has a TLS configuration, otherwise HTTP
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		// certificates are provided by the TLS configuration so they can be reloaded
+		return server.ListenAndServeTLS("", "")
 	}
+	return server.ListenAndServe()
 }
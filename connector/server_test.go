@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"testing"
 	"time"
@@ -27,6 +29,10 @@ var mockCapabilities = schema.CapabilitiesResponse{
 		Query: schema.QueryCapabilities{
 			Aggregates: schema.LeafCapability{},
 			Variables:  schema.LeafCapability{},
+			Explain:    schema.LeafCapability{},
+		},
+		Mutation: schema.MutationCapabilities{
+			Explain: schema.LeafCapability{},
 		},
 		Relationships: schema.RelationshipCapabilities{
 			OrderByAggregate:    schema.LeafCapability{},
@@ -233,6 +239,76 @@ func TestNewServer(t *testing.T) {
 	})
 }
 
+func TestServerShutdownDelay(t *testing.T) {
+	s, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		ShutdownDelay: time.Second,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ListenAndServe(18081)
+	}()
+	time.Sleep(500 * time.Millisecond)
+	s.stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// the listener keeps serving with the failing health check during the delay
+	res, err := http.Get("http://localhost:18081/health")
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "GET /health", res, http.StatusServiceUnavailable)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the server to shut down after the delay")
+	}
+}
+
+func TestShutdownHTTPServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+		}),
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	requestErr := make(chan error, 1)
+	go func() {
+		_, err := http.Get("http://" + listener.Addr().String())
+		requestErr <- err
+	}()
+	<-started
+
+	// the blocked request doesn't finish before the timeout, so its connection is closed
+	if err := shutdownHTTPServer(server, 100*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline exceeded error, got %v", err)
+	}
+	select {
+	case err := <-requestErr:
+		if err == nil {
+			t.Error("expected an error of the closed connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the connection to be closed")
+	}
+}
+
 func TestServerAuth(t *testing.T) {
 	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
 		Configuration:      "{}",
@@ -527,3 +603,35 @@ func TestConnectorWithPrometheusEnabled(t *testing.T) {
 		}
 	})
 }
+
+func TestServerDraining(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	res, err := http.Get(fmt.Sprintf("%s/health", httpServer.URL))
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "GET /health", res, http.StatusOK)
+
+	server.draining.Store(true)
+	res, err = http.Get(fmt.Sprintf("%s/health", httpServer.URL))
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponse(t, res, http.StatusServiceUnavailable, schema.ErrorResponse{
+		Message: "the server is shutting down",
+		Details: map[string]any{},
+	})
+}
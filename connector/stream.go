@@ -0,0 +1,160 @@
+package connector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+const streamBufferSize = 32 * 1024
+
+// RowIterator iterates the rows of a row set, so a connector can stream rows to the response
+// without materializing the whole result in memory
+type RowIterator interface {
+	// Next returns the next row, or io.EOF when there are no more rows
+	Next() (map[string]any, error)
+	// Close releases the resources of the iterator, such as database cursors
+	Close() error
+}
+
+// StreamingRowSet is a row set whose rows are written to the response as they are iterated
+type StreamingRowSet struct {
+	// The results of the aggregates returned by the query
+	Aggregates schema.RowSetAggregates
+	// The rows returned by the query. The rows field is omitted if it is nil
+	Rows RowIterator
+}
+
+// StreamingQueryResponse is the streaming alternative of [schema.QueryResponse]
+type StreamingQueryResponse []StreamingRowSet
+
+// Close closes the row iterators of all row sets
+func (sqr StreamingQueryResponse) Close() error {
+	var errs []error
+	for _, rowSet := range sqr {
+		if rowSet.Rows != nil {
+			errs = append(errs, rowSet.Rows.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueryStreamer is an optional interface that a connector implements to stream the rows of query results.
+// If the connector implements it, the server calls QueryStream instead of Query for the /query endpoint
+type QueryStreamer[Configuration any, State any] interface {
+	// QueryStream executes a query and returns row iterators of the results.
+	// The server closes the iterators after the response is written
+	QueryStream(ctx context.Context, configuration *Configuration, state *State, request *schema.QueryRequest) (StreamingQueryResponse, error)
+}
+
+// sliceRowIterator iterates materialized rows
+type sliceRowIterator struct {
+	rows  []map[string]any
+	index int
+}
+
+// NewSliceRowIterator creates a RowIterator of materialized rows
+func NewSliceRowIterator(rows []map[string]any) RowIterator {
+	return &sliceRowIterator{
+		rows: rows,
+	}
+}
+
+func (si *sliceRowIterator) Next() (map[string]any, error) {
+	if si.index >= len(si.rows) {
+		return nil, io.EOF
+	}
+	row := si.rows[si.index]
+	si.index++
+	return row, nil
+}
+
+func (si *sliceRowIterator) Close() error {
+	return nil
+}
+
+// newStreamingQueryResponse wraps the row sets of a materialized query response
+func newStreamingQueryResponse(response schema.QueryResponse) StreamingQueryResponse {
+	result := make(StreamingQueryResponse, len(response))
+	for i, rowSet := range response {
+		result[i].Aggregates = rowSet.Aggregates
+		if len(rowSet.Rows) > 0 {
+			result[i].Rows = NewSliceRowIterator(rowSet.Rows)
+		}
+	}
+	return result
+}
+
+// writeQueryResponse encodes the row sets and rows of the query response incrementally to the response writer.
+// The response status is sent before the rows are iterated, so an iteration error truncates the response body
+// and leaves it as invalid JSON, which the client can't mistake for a complete result
+func writeQueryResponse(w http.ResponseWriter, response StreamingQueryResponse) error {
+	w.Header().Set(headerContentType, contentTypeJson)
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+	if err := encodeQueryResponse(bw, response); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func encodeQueryResponse(bw *bufio.Writer, response StreamingQueryResponse) error {
+	_ = bw.WriteByte('[')
+	for i, rowSet := range response {
+		if i > 0 {
+			_ = bw.WriteByte(',')
+		}
+		_ = bw.WriteByte('{')
+		if len(rowSet.Aggregates) > 0 {
+			aggregates, err := json.Marshal(rowSet.Aggregates)
+			if err != nil {
+				return err
+			}
+			_, _ = bw.WriteString(`"aggregates":`)
+			_, _ = bw.Write(aggregates)
+		}
+		if rowSet.Rows != nil {
+			if len(rowSet.Aggregates) > 0 {
+				_ = bw.WriteByte(',')
+			}
+			_, _ = bw.WriteString(`"rows":[`)
+			if err := encodeRows(bw, rowSet.Rows); err != nil {
+				return err
+			}
+			_ = bw.WriteByte(']')
+		}
+		if err := bw.WriteByte('}'); err != nil {
+			return err
+		}
+	}
+	return bw.WriteByte(']')
+}
+
+func encodeRows(bw *bufio.Writer, rows RowIterator) error {
+	for i := 0; ; i++ {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			_ = bw.WriteByte(',')
+		}
+		rowBytes, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		// the buffered writer keeps the first write error, so it is enough to check the last one of each row
+		if _, err := bw.Write(rowBytes); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// errorRowIterator returns an error after the rows are iterated
+type errorRowIterator struct {
+	RowIterator
+	err error
+}
+
+func (ei *errorRowIterator) Next() (map[string]any, error) {
+	row, err := ei.RowIterator.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, ei.err
+	}
+	return row, err
+}
+
+// streamingConnector streams the rows of the mock connector and records closed iterators
+type streamingConnector struct {
+	mockConnector
+	closed int
+}
+
+type closeRecorder struct {
+	RowIterator
+	connector *streamingConnector
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.connector.closed++
+	return cr.RowIterator.Close()
+}
+
+func (sc *streamingConnector) QueryStream(ctx context.Context, configuration *mockConfiguration, state *mockState, request *schema.QueryRequest) (StreamingQueryResponse, error) {
+	response, err := sc.mockConnector.Query(ctx, configuration, state, request)
+	if err != nil {
+		return nil, err
+	}
+	result := newStreamingQueryResponse(response)
+	for i := range result {
+		result[i].Rows = &closeRecorder{
+			RowIterator: result[i].Rows,
+			connector:   sc,
+		}
+	}
+	return result, nil
+}
+
+func TestEncodeQueryResponse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response schema.QueryResponse
+	}{
+		{
+			name:     "empty",
+			response: schema.QueryResponse{},
+		},
+		{
+			name: "rows",
+			response: schema.QueryResponse{
+				{
+					Rows: []map[string]any{
+						{"id": 1, "title": "Hello world"},
+						{"id": 2, "title": "<escaped & \"quoted\">"},
+					},
+				},
+				{
+					Rows: []map[string]any{},
+				},
+			},
+		},
+		{
+			name: "aggregates",
+			response: schema.QueryResponse{
+				{
+					Aggregates: schema.RowSetAggregates{"count": 2},
+				},
+				{
+					Aggregates: schema.RowSetAggregates{"count": 1},
+					Rows: []map[string]any{
+						{"id": 1, "author": schema.RowSet{Rows: []map[string]any{{"name": "Peter"}}}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, err := json.Marshal(tc.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			bw := bufio.NewWriter(&buf)
+			if err := encodeQueryResponse(bw, newStreamingQueryResponse(tc.response)); err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			_ = bw.Flush()
+			if buf.String() != string(expected) {
+				t.Errorf("expected %s, got %s", expected, buf.String())
+			}
+		})
+	}
+
+	t.Run("iteration_error", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		err := encodeQueryResponse(bw, StreamingQueryResponse{
+			{
+				Rows: &errorRowIterator{
+					RowIterator: NewSliceRowIterator([]map[string]any{{"id": 1}}),
+					err:         errors.New("connection reset"),
+				},
+			},
+		})
+		if err == nil || err.Error() != "connection reset" {
+			t.Errorf("expected connection reset error, got %v", err)
+		}
+		_ = bw.Flush()
+		if json.Valid(buf.Bytes()) {
+			t.Errorf("expected the truncated response to be invalid JSON, got %s", buf.String())
+		}
+	})
+}
+
+func TestServerQueryStream(t *testing.T) {
+	connector := &streamingConnector{}
+	server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	res, err := httpPostJSON(fmt.Sprintf("%s/query", httpServer.URL), schema.QueryRequest{
+		Collection:              "articles",
+		Arguments:               schema.QueryRequestArguments{},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables:               []schema.QueryRequestVariablesElem{},
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponse(t, res, http.StatusOK, schema.QueryResponse{
+		{
+			Aggregates: schema.RowSetAggregates{},
+			Rows: []map[string]any{
+				{
+					"id":        1,
+					"title":     "Hello world",
+					"author_id": 1,
+				},
+			},
+		},
+	})
+	if connector.closed != 1 {
+		t.Errorf("expected the row iterator to be closed, got %d closed iterators", connector.closed)
+	}
+}
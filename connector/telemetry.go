@@ -14,21 +14,26 @@ import (
 	"github.com/hasura/ndc-sdk-go/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/contrib/propagators/b3"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	otelPrometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	logglobal "go.opentelemetry.io/otel/log/global"
 	metricapi "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
 	traceapi "go.opentelemetry.io/otel/trace"
 )
 
@@ -67,23 +72,33 @@ type OTLPConfig struct {
 	OtlpEndpoint           string `help:"OpenTelemetry receiver endpoint that is set as default for all types." env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
 	OtlpTracesEndpoint     string `help:"OpenTelemetry endpoint for traces." env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
 	OtlpMetricsEndpoint    string `help:"OpenTelemetry endpoint for metrics." env:"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"`
+	OtlpLogsEndpoint       string `help:"OpenTelemetry endpoint for logs." env:"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"`
 	OtlpInsecure           *bool  `help:"Disable LTS for OpenTelemetry exporters." env:"OTEL_EXPORTER_OTLP_INSECURE"`
 	OtlpTracesInsecure     *bool  `help:"Disable LTS for OpenTelemetry traces exporter." env:"OTEL_EXPORTER_OTLP_TRACES_INSECURE"`
 	OtlpMetricsInsecure    *bool  `help:"Disable LTS for OpenTelemetry metrics exporter." env:"OTEL_EXPORTER_OTLP_METRICS_INSECURE"`
+	OtlpLogsInsecure       *bool  `help:"Disable LTS for OpenTelemetry logs exporter." env:"OTEL_EXPORTER_OTLP_LOGS_INSECURE"`
 	OtlpProtocol           string `help:"OpenTelemetry receiver protocol for all types." env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
 	OtlpTracesProtocol     string `help:"OpenTelemetry receiver protocol for traces." env:"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"`
 	OtlpMetricsProtocol    string `help:"OpenTelemetry receiver protocol for metrics." env:"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"`
+	OtlpLogsProtocol       string `help:"OpenTelemetry receiver protocol for logs." env:"OTEL_EXPORTER_OTLP_LOGS_PROTOCOL"`
 	OtlpCompression        string `help:"Enable compression for OTLP exporters. Accept: none, gzip" env:"OTEL_EXPORTER_OTLP_COMPRESSION" default:"gzip"`
 	OtlpTraceCompression   string `help:"Enable compression for OTLP traces exporter. Accept: none, gzip" env:"OTEL_EXPORTER_OTLP_TRACES_COMPRESSION"`
 	OtlpMetricsCompression string `help:"Enable compression for OTLP metrics exporter. Accept: none, gzip." env:"OTEL_EXPORTER_OTLP_METRICS_COMPRESSION"`
+	OtlpLogsCompression    string `help:"Enable compression for OTLP logs exporter. Accept: none, gzip." env:"OTEL_EXPORTER_OTLP_LOGS_COMPRESSION"`
 
 	MetricsExporter string `help:"Metrics export type. Accept: none, otlp, prometheus." env:"OTEL_METRICS_EXPORTER" default:"none"`
 	PrometheusPort  *uint  `help:"Prometheus port for the Prometheus HTTP server. Use /metrics endpoint of the connector server if empty" env:"OTEL_EXPORTER_PROMETHEUS_PORT"`
+
+	TracesSampler    string   `help:"Traces sampler. Accept: always_on, always_off, traceidratio, parentbased_always_on, parentbased_always_off, parentbased_traceidratio. The default is parentbased_always_on" env:"OTEL_TRACES_SAMPLER"`
+	TracesSamplerArg *float64 `help:"Sampling ratio of the traceidratio and parentbased_traceidratio samplers, between 0 and 1. The default is 1" env:"OTEL_TRACES_SAMPLER_ARG"`
+	Propagators      []string `help:"Propagators of the trace context. Accept: tracecontext, baggage, b3, b3multi, none. The default is tracecontext,b3multi" env:"OTEL_PROPAGATORS"`
 }
 
 type TelemetryState struct {
-	Tracer                          *Tracer
-	Meter                           metricapi.Meter
+	Tracer *Tracer
+	Meter  metricapi.Meter
+	// Logger writes logs with the trace and span IDs of the context, and exports them if the OTLP logs endpoint is set
+	Logger                          *slog.Logger
 	Shutdown                        func(context.Context) error
 	queryCounter                    metricapi.Int64Counter
 	mutationCounter                 metricapi.Int64Counter
@@ -93,6 +108,18 @@ type TelemetryState struct {
 	queryExplainLatencyHistogram    metricapi.Float64Histogram
 	mutationExplainLatencyHistogram metricapi.Float64Histogram
 	mutationLatencyHistogram        metricapi.Float64Histogram
+	inflightRequests                metricapi.Int64UpDownCounter
+	queueLatencyHistogram           metricapi.Float64Histogram
+	rejectedRequestsCounter         metricapi.Int64Counter
+	uncompressedResponseBytes       metricapi.Int64Counter
+	compressedResponseBytes         metricapi.Int64Counter
+	healthCheckHistogram            metricapi.Float64Histogram
+	healthTransitionsCounter        metricapi.Int64Counter
+	readyGauge                      metricapi.Int64UpDownCounter
+	requestSizeHistogram            metricapi.Int64Histogram
+	responseSizeHistogram           metricapi.Int64Histogram
+	queryRowsHistogram              metricapi.Int64Histogram
+	mutationOperationCounter        metricapi.Int64Counter
 }
 
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
@@ -102,6 +129,16 @@ func setupOTelSDK(ctx context.Context, config *OTLPConfig, serviceVersion, metri
 	otel.SetLogger(logr.FromSlogHandler(logger.Handler()))
 	tracesEndpoint := utils.GetDefault(config.OtlpTracesEndpoint, config.OtlpEndpoint)
 	metricsEndpoint := utils.GetDefault(config.OtlpMetricsEndpoint, config.OtlpEndpoint)
+	logsEndpoint := utils.GetDefault(config.OtlpLogsEndpoint, config.OtlpEndpoint)
+
+	sampler, err := newSampler(config.TracesSampler, config.TracesSamplerArg)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := newPropagator(config.Propagators)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set up resource.
 	res, err := newResource(config.ServiceName, serviceVersion)
@@ -126,7 +163,6 @@ func setupOTelSDK(ctx context.Context, config *OTLPConfig, serviceVersion, metri
 		}
 
 		// Set up propagator.
-		prop := newPropagator()
 		otel.SetTextMapPropagator(prop)
 
 		var traceExporter *otlptrace.Exporter
@@ -162,10 +198,11 @@ func setupOTelSDK(ctx context.Context, config *OTLPConfig, serviceVersion, metri
 
 		traceProvider = trace.NewTracerProvider(
 			trace.WithResource(res),
+			trace.WithSampler(sampler),
 			trace.WithBatcher(traceExporter, trace.WithBatchTimeout(5*time.Second)),
 		)
 	} else {
-		traceProvider = trace.NewTracerProvider()
+		traceProvider = trace.NewTracerProvider(trace.WithSampler(sampler))
 	}
 	otel.SetTracerProvider(traceProvider)
 
@@ -244,18 +281,76 @@ func setupOTelSDK(ctx context.Context, config *OTLPConfig, serviceVersion, metri
 	meterProvider := metric.NewMeterProvider(metricOptions...)
 	otel.SetMeterProvider(meterProvider)
 
+	var loggerProvider *sdklog.LoggerProvider
+	var otlpHandler slog.Handler
+	if logsEndpoint != "" {
+		endpoint, protocol, insecure, err := parseOTLPEndpoint(
+			logsEndpoint,
+			utils.GetDefault(config.OtlpLogsProtocol, config.OtlpProtocol),
+			utils.GetDefaultPtr(config.OtlpLogsInsecure, config.OtlpInsecure),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse OTLP logs endpoint: %s", err)
+		}
+
+		compressorStr, compressorInt, err := parseOTLPCompression(utils.GetDefault(config.OtlpLogsCompression, config.OtlpCompression))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse OTLP logs compression: %s", err)
+		}
+
+		var logExporter sdklog.Exporter
+		if protocol == otlpProtocolGRPC {
+			options := []otlploggrpc.Option{
+				otlploggrpc.WithEndpoint(endpoint),
+				otlploggrpc.WithCompressor(compressorStr),
+			}
+
+			if insecure {
+				options = append(options, otlploggrpc.WithInsecure())
+			}
+
+			logExporter, err = otlploggrpc.New(ctx, options...)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			options := []otlploghttp.Option{
+				otlploghttp.WithEndpoint(endpoint),
+				otlploghttp.WithCompression(otlploghttp.Compression(compressorInt)),
+			}
+			if insecure {
+				options = append(options, otlploghttp.WithInsecure())
+			}
+
+			logExporter, err = otlploghttp.New(ctx, options...)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		loggerProvider = sdklog.NewLoggerProvider(
+			sdklog.WithResource(res),
+			sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		)
+		logglobal.SetLoggerProvider(loggerProvider)
+		otlpHandler = otelslog.NewHandler(config.ServiceName, otelslog.WithLoggerProvider(loggerProvider))
+	}
+
 	shutdownFunc := func(ctx context.Context) error {
-		tErr := traceProvider.Shutdown(ctx)
-		mErr := meterProvider.Shutdown(ctx)
-		if tErr != nil || mErr != nil {
-			return errors.New(strings.Join([]string{tErr.Error(), mErr.Error()}, ","))
+		errs := []error{
+			traceProvider.Shutdown(ctx),
+			meterProvider.Shutdown(ctx),
+		}
+		if loggerProvider != nil {
+			errs = append(errs, loggerProvider.Shutdown(ctx))
 		}
-		return nil
+		return errors.Join(errs...)
 	}
 
 	state := &TelemetryState{
 		Tracer:   &Tracer{traceProvider.Tracer(config.ServiceName)},
 		Meter:    meterProvider.Meter(config.ServiceName),
+		Logger:   slog.New(newLogHandler(logger.Handler(), otlpHandler)),
 		Shutdown: shutdownFunc,
 	}
 
@@ -272,11 +367,85 @@ func newResource(serviceName, serviceVersion string) (*resource.Resource, error)
 		))
 }
 
-func newPropagator() propagation.TextMapPropagator {
-	return propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)),
-	)
+// newPropagator creates a composite propagator of the names. The default is tracecontext and b3multi
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = []string{"tracecontext", "b3multi"}
+	}
+	var propagators []propagation.TextMapPropagator
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "none":
+		default:
+			return nil, fmt.Errorf("invalid propagator: %s", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+// newSampler creates the traces sampler of the name, which doesn't sample spans of untraced contexts.
+// The default is parentbased_always_on
+func newSampler(name string, ratioPtr *float64) (trace.Sampler, error) {
+	ratio := 1.0
+	if ratioPtr != nil {
+		ratio = *ratioPtr
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid traces sampler ratio %v, expected a value between 0 and 1", ratio)
+		}
+	}
+	var sampler trace.Sampler
+	switch strings.ToLower(name) {
+	case "always_on":
+		sampler = trace.AlwaysSample()
+	case "always_off":
+		sampler = trace.NeverSample()
+	case "traceidratio":
+		sampler = trace.TraceIDRatioBased(ratio)
+	case "parentbased_always_on", "":
+		sampler = trace.ParentBased(trace.AlwaysSample())
+	case "parentbased_always_off":
+		sampler = trace.ParentBased(trace.NeverSample())
+	case "parentbased_traceidratio":
+		sampler = trace.ParentBased(trace.TraceIDRatioBased(ratio))
+	default:
+		return nil, fmt.Errorf("invalid traces sampler: %s", name)
+	}
+	return untracedSampler{Sampler: sampler}, nil
+}
+
+type untracedContextKey struct{}
+
+// withoutTracing marks the context so spans that start from it aren't sampled,
+// e.g. of health checks and metrics scrapes that would flood the traces backend
+func withoutTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, untracedContextKey{}, true)
+}
+
+// untracedSampler drops spans of untraced contexts, and delegates other decisions to the wrapped sampler
+type untracedSampler struct {
+	trace.Sampler
+}
+
+func (us untracedSampler) ShouldSample(params trace.SamplingParameters) trace.SamplingResult {
+	if untraced, _ := params.ParentContext.Value(untracedContextKey{}).(bool); untraced {
+		return trace.SamplingResult{
+			Decision:   trace.Drop,
+			Tracestate: traceapi.SpanContextFromContext(params.ParentContext).TraceState(),
+		}
+	}
+	return us.Sampler.ShouldSample(params)
+}
+
+func (us untracedSampler) Description() string {
+	return fmt.Sprintf("Untraced{%s}", us.Sampler.Description())
 }
 
 func setupMetrics(telemetry *TelemetryState, metricsPrefix string) error {
@@ -348,6 +517,106 @@ func setupMetrics(telemetry *TelemetryState, metricsPrefix string) error {
 		fmt.Sprintf("%smutation.explain_total_time", metricsPrefix),
 		metricapi.WithDescription("Total time taken to plan and execute an explain mutation request, in seconds"),
 	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.inflightRequests, err = meter.Int64UpDownCounter(
+		fmt.Sprintf("%shttp.inflight_requests", metricsPrefix),
+		metricapi.WithDescription("Number of HTTP requests that are being served"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.queueLatencyHistogram, err = meter.Float64Histogram(
+		fmt.Sprintf("%shttp.queue_time", metricsPrefix),
+		metricapi.WithDescription("Time that requests wait for an execution slot of the concurrency limit, in seconds"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.rejectedRequestsCounter, err = meter.Int64Counter(
+		fmt.Sprintf("%shttp.rejected_total", metricsPrefix),
+		metricapi.WithDescription("Total number of requests that are rejected by the concurrency limit"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.uncompressedResponseBytes, err = meter.Int64Counter(
+		fmt.Sprintf("%shttp.response.uncompressed_bytes", metricsPrefix),
+		metricapi.WithDescription("Total size of compressed response bodies before compression, in bytes"),
+		metricapi.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.compressedResponseBytes, err = meter.Int64Counter(
+		fmt.Sprintf("%shttp.response.compressed_bytes", metricsPrefix),
+		metricapi.WithDescription("Total size of compressed response bodies after compression, in bytes"),
+		metricapi.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.healthCheckHistogram, err = meter.Float64Histogram(
+		fmt.Sprintf("%shealth.check_time", metricsPrefix),
+		metricapi.WithDescription("Time taken by background health checks of the connector, in seconds"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.healthTransitionsCounter, err = meter.Int64Counter(
+		fmt.Sprintf("%shealth.transitions_total", metricsPrefix),
+		metricapi.WithDescription("Total number of changes of the connector readiness"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.readyGauge, err = meter.Int64UpDownCounter(
+		fmt.Sprintf("%shealth.ready", metricsPrefix),
+		metricapi.WithDescription("Whether the latest background health check of the connector succeeded, 1 if it is ready"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.requestSizeHistogram, err = meter.Int64Histogram(
+		fmt.Sprintf("%shttp.request.body_size", metricsPrefix),
+		metricapi.WithDescription("Size of request bodies after decompression, in bytes"),
+		metricapi.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.responseSizeHistogram, err = meter.Int64Histogram(
+		fmt.Sprintf("%shttp.response.body_size", metricsPrefix),
+		metricapi.WithDescription("Size of response bodies before compression, in bytes"),
+		metricapi.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.queryRowsHistogram, err = meter.Int64Histogram(
+		fmt.Sprintf("%squery.rows", metricsPrefix),
+		metricapi.WithDescription("Number of rows of row sets of query responses"),
+	)
+	if err != nil {
+		return err
+	}
+
+	telemetry.mutationOperationCounter, err = meter.Int64Counter(
+		fmt.Sprintf("%smutation.operation_total", metricsPrefix),
+		metricapi.WithDescription("Total number of mutation operations, by the procedure name"),
+	)
 
 	return err
 }
@@ -446,7 +715,7 @@ var allowedTraceHeaders = []string{
 	"project-id",
 }
 
-func setSpanHeadersAttributes(span traceapi.Span, header http.Header, isDebug bool) {
+func setSpanHeadersAttributes(span traceapi.Span, header http.Header, isDebug bool, rd *redactor) {
 	for k, h := range header {
 		if len(h) == 0 {
 			continue
@@ -454,13 +723,17 @@ func setSpanHeadersAttributes(span traceapi.Span, header http.Header, isDebug bo
 
 		lowerKey := strings.ToLower(k)
 		attrKey := fmt.Sprintf("header.%s", lowerKey)
+		value := h[0]
+		if rd.isRedactedHeader(lowerKey) {
+			value = rd.mask(value)
+		}
 		if isDebug {
-			span.SetAttributes(attribute.String(attrKey, h[0]))
+			span.SetAttributes(attribute.String(attrKey, value))
 			continue
 		}
 		for _, allowedKey := range allowedTraceHeaders {
 			if lowerKey == allowedKey {
-				span.SetAttributes(attribute.String(attrKey, h[0]))
+				span.SetAttributes(attribute.String(attrKey, value))
 				break
 			}
 		}
@@ -0,0 +1,139 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tracingConnector starts a span in the health check
+type tracingConnector struct {
+	mockConnector
+	tracer *Tracer
+}
+
+func (tc *tracingConnector) HealthCheck(ctx context.Context, configuration *mockConfiguration, state *mockState) error {
+	_, span := tc.tracer.Start(ctx, "health_check")
+	span.End()
+	return nil
+}
+
+func TestNewSampler(t *testing.T) {
+	ratio := 0.5
+	invalidRatio := 2.0
+	for _, tc := range []struct {
+		name        string
+		ratio       *float64
+		description string
+		isError     bool
+	}{
+		{name: "", description: "Untraced{ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}}"},
+		{name: "always_off", description: "Untraced{AlwaysOffSampler}"},
+		{name: "traceidratio", ratio: &ratio, description: "Untraced{TraceIDRatioBased{0.5}}"},
+		{name: "parentbased_traceidratio", ratio: &ratio, description: "Untraced{ParentBased{root:TraceIDRatioBased{0.5},remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}}"},
+		{name: "traceidratio", ratio: &invalidRatio, isError: true},
+		{name: "random", isError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sampler, err := newSampler(tc.name, tc.ratio)
+			if tc.isError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if sampler.Description() != tc.description {
+				t.Errorf("expected %s, got %s", tc.description, sampler.Description())
+			}
+		})
+	}
+}
+
+func TestNewPropagator(t *testing.T) {
+	for _, tc := range []struct {
+		names   []string
+		fields  []string
+		isError bool
+	}{
+		{fields: []string{"traceparent", "tracestate", "x-b3-traceid", "x-b3-spanid", "x-b3-sampled", "x-b3-flags"}},
+		{names: []string{"tracecontext", "baggage"}, fields: []string{"traceparent", "tracestate", "baggage"}},
+		{names: []string{"b3"}, fields: []string{"b3"}},
+		{names: []string{"none"}},
+		{names: []string{"jaeger"}, isError: true},
+	} {
+		prop, err := newPropagator(tc.names)
+		if tc.isError {
+			if err == nil {
+				t.Errorf("%v: expected an error, got nil", tc.names)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: expected no error, got %s", tc.names, err)
+			continue
+		}
+		fields := prop.Fields()
+		slices.Sort(fields)
+		slices.Sort(tc.fields)
+		if !internal.DeepEqual(fields, tc.fields) {
+			t.Errorf("%v: expected fields %v, got %v", tc.names, tc.fields, fields)
+		}
+	}
+}
+
+func TestUntracedEndpoints(t *testing.T) {
+	sampler, err := newSampler("always_on", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	tracer := &Tracer{trace.NewTracerProvider(trace.WithSampler(sampler), trace.WithSpanProcessor(recorder)).Tracer("test")}
+	server, err := NewServer[mockConfiguration, mockState](&tracingConnector{tracer: tracer}, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	server.telemetry.Tracer = tracer
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	res, err := http.Get(httpServer.URL + "/health")
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "GET /health", res, http.StatusOK)
+	if err := server.checkHealth(context.Background()); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("expected no sampled spans of health checks, got %d", len(spans))
+	}
+
+	res, err = httpPostJSON(httpServer.URL+"/query", map[string]any{
+		"collection":               "articles",
+		"arguments":                map[string]any{},
+		"collection_relationships": map[string]any{},
+		"query":                    map[string]any{},
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "POST /query", res, http.StatusOK)
+	if spans := recorder.Ended(); len(spans) == 0 {
+		t.Errorf("expected sampled spans of the query")
+	}
+}
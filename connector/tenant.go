@@ -0,0 +1,261 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	tenantContextKey    serverContextKey = "hasura-tenant"
+	defaultTenantHeader                  = "X-Hasura-Tenant"
+)
+
+// the first path segments of the server routes, which can't be tenant names
+var reservedTenantNames = map[string]bool{
+	"capabilities": true,
+	"schema":       true,
+	"query":        true,
+	"mutation":     true,
+	"health":       true,
+	"livez":        true,
+	"readyz":       true,
+	"admin":        true,
+	"metrics":      true,
+}
+
+// Tenant is a configuration that the server serves alongside others, with its own state and service token
+type Tenant struct {
+	// Name identifies the tenant in the path prefix or the tenant header of requests
+	Name string `json:"name"`
+	// Configuration is the configuration directory of the tenant
+	Configuration string `json:"configuration"`
+	// ServiceTokenSecret authorizes the requests of the tenant.
+	// The authenticators of the server options are used if it is empty
+	ServiceTokenSecret string `json:"service_token_secret,omitempty"`
+}
+
+// LoadTenantsFile reads the list of tenants from a JSON file
+func LoadTenantsFile(path string) ([]Tenant, error) {
+	rawTenants, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tenants file: %w", err)
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(rawTenants, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to decode the tenants file: %w", err)
+	}
+	return tenants, nil
+}
+
+func validateTenants(tenants []Tenant) error {
+	names := make(map[string]bool, len(tenants))
+	for _, tenant := range tenants {
+		if tenant.Name == "" || strings.Contains(tenant.Name, "/") {
+			return fmt.Errorf("invalid tenant name %q", tenant.Name)
+		}
+		if reservedTenantNames[tenant.Name] {
+			return fmt.Errorf("the tenant name %s is reserved for the server routes", tenant.Name)
+		}
+		if names[tenant.Name] {
+			return fmt.Errorf("duplicated tenant %s", tenant.Name)
+		}
+		names[tenant.Name] = true
+	}
+	return nil
+}
+
+// tenant serves a configuration with its own runtime and authenticator.
+// The default tenant of a server has an empty name
+type tenant[Configuration any, State any] struct {
+	name          string
+	configuration string
+	runtime       atomic.Pointer[serverRuntime[Configuration, State]]
+	reloadLock    sync.Mutex
+	authenticator Authenticator
+	// the result of the latest background health check
+	health healthState
+}
+
+// acquireRuntime acquires the current runtime for a request. The caller must release it when the request completes.
+// It returns nil if the runtime was retired without a replacement, when the server shuts down
+func (t *tenant[Configuration, State]) acquireRuntime() *serverRuntime[Configuration, State] {
+	for {
+		rt := t.runtime.Load()
+		if rt.acquire() {
+			return rt
+		}
+		if t.runtime.Load() == rt {
+			return nil
+		}
+		// the runtime was retired by a reload after it had been loaded, retry with the new one
+	}
+}
+
+// logAttributes returns the tenant attribute of logs, which is empty for the default tenant
+func (t *tenant[Configuration, State]) logAttributes() []any {
+	if t.name == "" {
+		return nil
+	}
+	return []any{slog.String("tenant", t.name)}
+}
+
+// newTenant parses the configuration of the tenant and initializes its state
+func (s *Server[Configuration, State]) newTenant(ctx context.Context, options Tenant) (*tenant[Configuration, State], error) {
+	configuration, err := s.connector.ParseConfiguration(ctx, options.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the configuration of tenant %s: %w", options.Name, err)
+	}
+	state, err := s.connector.TryInitState(ctx, configuration, s.telemetry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the state of tenant %s: %w", options.Name, err)
+	}
+
+	result := &tenant[Configuration, State]{
+		name:          options.Name,
+		configuration: options.Configuration,
+		authenticator: s.authenticator,
+	}
+	if options.ServiceTokenSecret != "" {
+		result.authenticator = NewStaticTokenAuthenticator(options.ServiceTokenSecret)
+	}
+	result.runtime.Store(newServerRuntime(configuration, state))
+	return result, nil
+}
+
+// allTenants returns the default tenant, if any, and the named tenants in the order of their names
+func (s *Server[Configuration, State]) allTenants() []*tenant[Configuration, State] {
+	result := make([]*tenant[Configuration, State], 0, len(s.tenants)+1)
+	if s.defaultTenant != nil {
+		result = append(result, s.defaultTenant)
+	}
+	names := make([]string, 0, len(s.tenants))
+	for name := range s.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, s.tenants[name])
+	}
+	return result
+}
+
+// requestTenant returns the tenant that the request is routed to, or the default tenant if the request has no tenant.
+// It returns nil if the server has no default tenant
+func (s *Server[Configuration, State]) requestTenant(ctx context.Context) *tenant[Configuration, State] {
+	if name, ok := ctx.Value(tenantContextKey).(string); ok {
+		return s.tenants[name]
+	}
+	return s.defaultTenant
+}
+
+func (s *Server[Configuration, State]) tenantHeader() string {
+	if s.options.TenantHeader != "" {
+		return s.options.TenantHeader
+	}
+	return defaultTenantHeader
+}
+
+// routeTenant routes requests to tenants by the /{tenant} path prefix, or by the tenant header.
+// The prefix is removed from the path of routed requests, so they are handled by the same routes as the default tenant
+func (s *Server[Configuration, State]) routeTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var name, path string
+		if segment, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/"); ok {
+			if _, ok := s.tenants[segment]; ok {
+				name, path = segment, "/"+rest
+			}
+		}
+		if name == "" {
+			name = r.Header.Get(s.tenantHeader())
+			if name == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if _, ok := s.tenants[name]; !ok {
+				writeJson(w, s.logger, http.StatusNotFound, schema.ErrorResponse{
+					Message: fmt.Sprintf("unknown tenant %s", name),
+					Details: map[string]any{},
+				})
+				return
+			}
+		}
+
+		req := r.WithContext(context.WithValue(r.Context(), tenantContextKey, name))
+		if path != "" {
+			u := *r.URL
+			u.Path = path
+			u.RawPath = ""
+			req.URL = &u
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// withTenant rejects requests of routes that require a tenant if the server has no default tenant
+func (s *Server[Configuration, State]) withTenant(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.requestTenant(r.Context()) == nil {
+			writeJson(w, GetLogger(r.Context()), http.StatusNotFound, schema.ErrorResponse{
+				Message: fmt.Sprintf("the tenant is required, set the /{tenant} path prefix or the %s header", s.tenantHeader()),
+				Details: map[string]any{},
+			})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// ReloadTenant reloads the configuration and the state of a tenant. See [Server.Reload]
+func (s *Server[Configuration, State]) ReloadTenant(name string) error {
+	t, ok := s.tenants[name]
+	if !ok {
+		return fmt.Errorf("unknown tenant %s", name)
+	}
+	return s.reloadTenant(t)
+}
+
+// reloadAll reloads all tenants, and continues with the next ones if a reload fails
+func (s *Server[Configuration, State]) reloadAll() error {
+	var errs []error
+	for _, t := range s.allTenants() {
+		if err := s.reloadTenant(t); err != nil {
+			if t.name != "" {
+				err = fmt.Errorf("tenant %s: %w", t.name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tenantName returns the name of the tenant that the request is routed to, or an empty string
+func tenantName(ctx context.Context) string {
+	name, _ := ctx.Value(tenantContextKey).(string)
+	return name
+}
+
+// tenantAttributes returns the tenant attribute of the metrics of a request,
+// which is empty if the request isn't routed to a named tenant
+func tenantAttributes(ctx context.Context) metric.MeasurementOption {
+	return metricTenantAttributes(tenantName(ctx))
+}
+
+func metricTenantAttributes(name string) metric.MeasurementOption {
+	if name == "" {
+		return metric.WithAttributes()
+	}
+	return metric.WithAttributes(attribute.String("tenant", name))
+}
@@ -0,0 +1,195 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// tenantConnector numbers the configurations of every directory by hundreds,
+// and increases the version on every parse, so responses show the tenant and the reload that served them
+type tenantConnector struct {
+	mockConnector
+	lock   sync.Mutex
+	parses map[string]int
+}
+
+func (tc *tenantConnector) ParseConfiguration(ctx context.Context, configurationDir string) (*mockConfiguration, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	bases := map[string]int{"tenant-a": 100, "tenant-b": 200}
+	base, ok := bases[configurationDir]
+	if !ok {
+		return nil, fmt.Errorf("configuration %s not found", configurationDir)
+	}
+	tc.parses[configurationDir]++
+	return &mockConfiguration{
+		Version: base + tc.parses[configurationDir],
+	}, nil
+}
+
+func (tc *tenantConnector) Query(ctx context.Context, configuration *mockConfiguration, state *mockState, request *schema.QueryRequest) (schema.QueryResponse, error) {
+	return schema.QueryResponse{
+		{
+			Rows: []map[string]any{
+				{"version": configuration.Version},
+			},
+		},
+	}, nil
+}
+
+func TestServerTenants(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&tenantConnector{parses: map[string]int{}}, &ServerOptions{
+		Tenants: []Tenant{
+			{Name: "a", Configuration: "tenant-a"},
+			{Name: "b", Configuration: "tenant-b", ServiceTokenSecret: "b-secret"},
+		},
+	})
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	queryBody, err := json.Marshal(schema.QueryRequest{
+		Collection:              "articles",
+		Arguments:               schema.QueryRequestArguments{},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables:               []schema.QueryRequestVariablesElem{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := func(t *testing.T, path string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, httpServer.URL+path, bytes.NewReader(queryBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		return res
+	}
+	versionResponse := func(version int) schema.QueryResponse {
+		return schema.QueryResponse{
+			{
+				Rows: []map[string]any{
+					{"version": version},
+				},
+			},
+		}
+	}
+
+	t.Run("path_prefix", func(t *testing.T) {
+		res := query(t, "/a/query", nil)
+		assertHTTPResponse(t, res, http.StatusOK, versionResponse(101))
+	})
+
+	t.Run("header", func(t *testing.T) {
+		res := query(t, "/query", map[string]string{
+			"X-Hasura-Tenant": "b",
+			"Authorization":   "Bearer b-secret",
+		})
+		assertHTTPResponse(t, res, http.StatusOK, versionResponse(201))
+	})
+
+	t.Run("tenant_service_token", func(t *testing.T) {
+		res := query(t, "/b/query", nil)
+		assertHTTPResponseStatus(t, "POST /b/query", res, http.StatusUnauthorized)
+	})
+
+	t.Run("tenant_required", func(t *testing.T) {
+		res := query(t, "/query", nil)
+		assertHTTPResponse(t, res, http.StatusNotFound, schema.ErrorResponse{
+			Message: "the tenant is required, set the /{tenant} path prefix or the X-Hasura-Tenant header",
+			Details: map[string]any{},
+		})
+	})
+
+	t.Run("unknown_tenant", func(t *testing.T) {
+		res := query(t, "/query", map[string]string{
+			"X-Hasura-Tenant": "c",
+		})
+		assertHTTPResponse(t, res, http.StatusNotFound, schema.ErrorResponse{
+			Message: "unknown tenant c",
+			Details: map[string]any{},
+		})
+	})
+
+	t.Run("reload_tenant", func(t *testing.T) {
+		if err := server.ReloadTenant("a"); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		assertHTTPResponse(t, query(t, "/a/query", nil), http.StatusOK, versionResponse(102))
+		assertHTTPResponse(t, query(t, "/b/query", map[string]string{
+			"Authorization": "Bearer b-secret",
+		}), http.StatusOK, versionResponse(201))
+
+		if err := server.ReloadTenant("c"); err == nil || err.Error() != "unknown tenant c" {
+			t.Errorf("expected unknown tenant error, got %v", err)
+		}
+	})
+
+	t.Run("readiness", func(t *testing.T) {
+		if err := server.checkHealth(context.Background()); err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		for _, path := range []string{"/readyz", "/a/readyz"} {
+			res, err := http.Get(httpServer.URL + path)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			assertHTTPResponseStatus(t, "GET "+path, res, http.StatusOK)
+		}
+	})
+}
+
+func TestValidateTenants(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tenants []Tenant
+		err     string
+	}{
+		{
+			name:    "empty_name",
+			tenants: []Tenant{{Configuration: "tenant-a"}},
+			err:     `invalid tenant name ""`,
+		},
+		{
+			name:    "reserved_name",
+			tenants: []Tenant{{Name: "query", Configuration: "tenant-a"}},
+			err:     "the tenant name query is reserved for the server routes",
+		},
+		{
+			name:    "duplicated",
+			tenants: []Tenant{{Name: "a", Configuration: "tenant-a"}, {Name: "a", Configuration: "tenant-b"}},
+			err:     "duplicated tenant a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTenants(tc.tenants)
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expected error %s, got %v", tc.err, err)
+			}
+		})
+	}
+}
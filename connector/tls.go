@@ -0,0 +1,192 @@
+package connector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// TLSConfig contains the TLS settings of the connector HTTP server, and of the Prometheus and admin listeners
+type TLSConfig struct {
+	TLSCertFile     string `help:"Path of the PEM encoded TLS certificate. The server serves HTTPS if it is set. The file is read again when it changes." env:"HASURA_TLS_CERT_FILE"`
+	TLSKeyFile      string `help:"Path of the PEM encoded private key of the TLS certificate." env:"HASURA_TLS_KEY_FILE"`
+	TLSClientCAFile string `help:"Path of the PEM encoded CA certificates that verify client certificates. Client certificates are required by the connector listener if it is set." env:"HASURA_TLS_CLIENT_CA_FILE"`
+	// TLSAuxiliaryMode is the TLS mode of the Prometheus and admin listeners. The default is tls
+	TLSAuxiliaryMode TLSAuxiliaryMode `help:"TLS mode of the Prometheus and admin listeners. tls serves HTTPS with the connector certificate without requiring client certificates, mtls also requires client certificates like the connector listener, and off serves HTTP." env:"HASURA_TLS_AUXILIARY_MODE" enum:"tls,mtls,off" default:"tls"`
+}
+
+// TLSAuxiliaryMode is the TLS mode of the Prometheus and admin listeners
+type TLSAuxiliaryMode string
+
+const (
+	// TLSAuxiliaryModeTLS serves HTTPS with the connector certificate, without requiring client certificates,
+	// so metrics scrapers and operators don't need the client certificates of the connector listener
+	TLSAuxiliaryModeTLS TLSAuxiliaryMode = "tls"
+	// TLSAuxiliaryModeMTLS serves HTTPS with the same settings as the connector listener
+	TLSAuxiliaryModeMTLS TLSAuxiliaryMode = "mtls"
+	// TLSAuxiliaryModeOff serves HTTP
+	TLSAuxiliaryModeOff TLSAuxiliaryMode = "off"
+)
+
+// Enabled checks if the server serves HTTPS
+func (tc TLSConfig) Enabled() bool {
+	return tc.TLSCertFile != ""
+}
+
+// tlsFiles loads the certificate and client CA files, and reloads them when any of them changes.
+// If reading changed files fails, the previously loaded certificates are kept
+type tlsFiles struct {
+	config      TLSConfig
+	logger      *slog.Logger
+	lock        sync.Mutex
+	signatures  map[string]string
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// newTLSConfig creates the TLS configuration of an HTTP server, or nil if TLS is disabled
+func newTLSConfig(config TLSConfig, logger *slog.Logger) (*tls.Config, error) {
+	if !config.Enabled() {
+		if config.TLSKeyFile != "" || config.TLSClientCAFile != "" {
+			return nil, errors.New("the TLS certificate file is required")
+		}
+		return nil, nil
+	}
+	if config.TLSKeyFile == "" {
+		return nil, errors.New("the TLS key file is required")
+	}
+
+	files := &tlsFiles{
+		config: config,
+		logger: logger,
+	}
+	if err := files.reload(files.fileSignatures()); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			certificate, _ := files.load()
+			return certificate, nil
+		},
+	}
+	if config.TLSClientCAFile != "" {
+		// client certificates are verified with the current CA pool on every handshake,
+		// because the ClientCAs field of the config can't change after the server starts
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			_, clientCAs := files.load()
+			return verifyClientCertificate(rawCerts, clientCAs)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// newAuxiliaryTLSConfig creates the TLS configuration of the Prometheus and admin listeners
+// from the TLS configuration of the connector listener, or nil if they serve HTTP
+func newAuxiliaryTLSConfig(config TLSConfig, tlsConfig *tls.Config) (*tls.Config, error) {
+	switch config.TLSAuxiliaryMode {
+	case "", TLSAuxiliaryModeTLS:
+		if tlsConfig == nil {
+			return nil, nil
+		}
+		result := tlsConfig.Clone()
+		result.ClientAuth = tls.NoClientCert
+		result.VerifyPeerCertificate = nil
+		return result, nil
+	case TLSAuxiliaryModeMTLS:
+		return tlsConfig, nil
+	case TLSAuxiliaryModeOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid TLS auxiliary mode %s, expected one of tls, mtls, off", config.TLSAuxiliaryMode)
+	}
+}
+
+// load returns the current certificates, after reloading the files if they changed
+func (tf *tlsFiles) load() (*tls.Certificate, *x509.CertPool) {
+	tf.lock.Lock()
+	defer tf.lock.Unlock()
+
+	signatures := tf.fileSignatures()
+	for path, signature := range signatures {
+		if tf.signatures[path] == signature {
+			continue
+		}
+		if err := tf.reload(signatures); err != nil {
+			tf.logger.Error("failed to reload TLS certificates", slog.Any("error", err))
+		} else {
+			tf.logger.Info("reloaded TLS certificates")
+		}
+		break
+	}
+	return tf.certificate, tf.clientCAs
+}
+
+func (tf *tlsFiles) reload(signatures map[string]string) error {
+	certificate, err := tls.LoadX509KeyPair(tf.config.TLSCertFile, tf.config.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if tf.config.TLSClientCAFile != "" {
+		caBytes, err := os.ReadFile(tf.config.TLSClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the TLS client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return fmt.Errorf("no valid certificate in the TLS client CA file %s", tf.config.TLSClientCAFile)
+		}
+	}
+
+	tf.certificate = &certificate
+	tf.clientCAs = clientCAs
+	tf.signatures = signatures
+	return nil
+}
+
+// fileSignatures returns the size and modification time of the files, which change when the files are replaced
+func (tf *tlsFiles) fileSignatures() map[string]string {
+	signatures := make(map[string]string)
+	for _, path := range []string{tf.config.TLSCertFile, tf.config.TLSKeyFile, tf.config.TLSClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			signatures[path] = fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return signatures
+}
+
+func verifyClientCertificate(rawCerts [][]byte, clientCAs *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("a client certificate is required")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse the client certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
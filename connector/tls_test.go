@@ -0,0 +1,246 @@
+package connector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func createTestCertificate(t *testing.T, serial int64, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	ca := createTestCertificate(t, 1, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+	newServerCertificate := func(serial int64) *testCertificate {
+		return createTestCertificate(t, serial, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "localhost"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca)
+	}
+	serverCert := newServerCertificate(2)
+	clientCert := createTestCertificate(t, 3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	dir := t.TempDir()
+	config := TLSConfig{
+		TLSCertFile:     filepath.Join(dir, "tls.crt"),
+		TLSKeyFile:      filepath.Join(dir, "tls.key"),
+		TLSClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	modTime := time.Now()
+	writeTestFile(t, config.TLSCertFile, serverCert.certPEM, modTime)
+	writeTestFile(t, config.TLSKeyFile, serverCert.keyPEM, modTime)
+	writeTestFile(t, config.TLSClientCAFile, ca.certPEM, modTime)
+
+	tlsConfig, err := newTLSConfig(config, slog.Default())
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		TLSConfig: tlsConfig,
+	}
+	go func() {
+		_ = server.ServeTLS(listener, "", "")
+	}()
+	defer server.Close()
+	serverURL := "https://" + listener.Addr().String()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	newClient := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      rootCAs,
+					Certificates: certificates,
+				},
+			},
+		}
+	}
+	clientKeyPair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("client_certificate_required", func(t *testing.T) {
+		res, err := newClient().Get(serverURL)
+		if err == nil {
+			res.Body.Close()
+			t.Error("expected error, got nil")
+		}
+	})
+
+	t.Run("mutual_tls", func(t *testing.T) {
+		res, err := newClient(clientKeyPair).Get(serverURL)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, got %d", http.StatusNoContent, res.StatusCode)
+		}
+		if serial := res.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 2 {
+			t.Errorf("expected server certificate serial 2, got %d", serial)
+		}
+	})
+
+	t.Run("reload_certificate", func(t *testing.T) {
+		rotatedCert := newServerCertificate(4)
+		modTime = modTime.Add(time.Second)
+		writeTestFile(t, config.TLSCertFile, rotatedCert.certPEM, modTime)
+		writeTestFile(t, config.TLSKeyFile, rotatedCert.keyPEM, modTime)
+
+		res, err := newClient(clientKeyPair).Get(serverURL)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		defer res.Body.Close()
+		if serial := res.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 4 {
+			t.Errorf("expected server certificate serial 4, got %d", serial)
+		}
+	})
+
+	t.Run("keep_certificate_on_invalid_file", func(t *testing.T) {
+		modTime = modTime.Add(time.Second)
+		writeTestFile(t, config.TLSKeyFile, []byte("invalid"), modTime)
+
+		res, err := newClient(clientKeyPair).Get(serverURL)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		defer res.Body.Close()
+		if serial := res.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 4 {
+			t.Errorf("expected server certificate serial 4, got %d", serial)
+		}
+	})
+
+	t.Run("auxiliary_modes", func(t *testing.T) {
+		auxiliaryConfig, err := newAuxiliaryTLSConfig(config, tlsConfig)
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		auxiliaryListener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		auxiliaryServer := &http.Server{
+			Handler:   server.Handler,
+			TLSConfig: auxiliaryConfig,
+		}
+		go func() {
+			_ = auxiliaryServer.ServeTLS(auxiliaryListener, "", "")
+		}()
+		defer auxiliaryServer.Close()
+
+		res, err := newClient().Get("https://" + auxiliaryListener.Addr().String())
+		if err != nil {
+			t.Errorf("expected no error without a client certificate, got %s", err)
+			t.FailNow()
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("expected status %d, got %d", http.StatusNoContent, res.StatusCode)
+		}
+
+		mtlsConfig := config
+		mtlsConfig.TLSAuxiliaryMode = TLSAuxiliaryModeMTLS
+		if result, err := newAuxiliaryTLSConfig(mtlsConfig, tlsConfig); err != nil || result != tlsConfig {
+			t.Errorf("expected the connector TLS configuration, got %v, %v", result, err)
+		}
+		offConfig := config
+		offConfig.TLSAuxiliaryMode = TLSAuxiliaryModeOff
+		if result, err := newAuxiliaryTLSConfig(offConfig, tlsConfig); err != nil || result != nil {
+			t.Errorf("expected no TLS configuration, got %v, %v", result, err)
+		}
+		invalidConfig := config
+		invalidConfig.TLSAuxiliaryMode = "invalid"
+		if _, err := newAuxiliaryTLSConfig(invalidConfig, tlsConfig); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+
+	t.Run("missing_key_file", func(t *testing.T) {
+		if _, err := newTLSConfig(TLSConfig{TLSCertFile: config.TLSCertFile}, slog.Default()); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
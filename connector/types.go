@@ -76,6 +76,14 @@ type Connector[Configuration any, State any] interface {
 	Query(ctx context.Context, configuration *Configuration, state *State, request *schema.QueryRequest) (schema.QueryResponse, error)
 }
 
+// StateCloser is an optional interface that a connector implements to release the resources of its state,
+// such as connection pools. The server calls it on graceful shutdown and after a hot reload replaces the state.
+// If the connector doesn't implement it, the server closes the state if it implements io.Closer
+type StateCloser[State any] interface {
+	// Close releases the resources of the state. The context is canceled when the drain timeout expires
+	Close(ctx context.Context, state *State) error
+}
+
 // the common serve options for the server
 type serveOptions struct {
 	logger          *slog.Logger
@@ -85,6 +93,21 @@ type serveOptions struct {
 	serviceName     string
 	withoutConfig   bool
 	withoutRecovery bool
+	authenticator   Authenticator
+	// middlewares of all routes
+	middlewares []Middleware
+	// middlewares of routes, keyed by path
+	routeMiddlewares map[string][]Middleware
+	// concurrency limits of routes, keyed by path
+	concurrencyLimits map[string]ConcurrencyLimit
+	// maximum sizes in bytes of request bodies of routes, keyed by path
+	requestBodyLimits map[string]int64
+	// validate query and mutation requests against the schema before they are executed
+	requestValidation bool
+	// the schema changes without a configuration reload, so /schema responses aren't cached
+	dynamicSchema bool
+	// the level of the logger that the /admin/log-level endpoint changes
+	logLevelVar *slog.LevelVar
 }
 
 func defaultServeOptions() *serveOptions {
@@ -142,6 +165,13 @@ func WithoutRecovery() ServeOption {
 	}
 }
 
+// WithLogLevelVar sets the level variable of the logger, so the /admin/log-level endpoint of the admin listener can change the level
+func WithLogLevelVar(levelVar *slog.LevelVar) ServeOption {
+	return func(so *serveOptions) {
+		so.logLevelVar = levelVar
+	}
+}
+
 func withLogLevel(level slog.Level) ServeOption {
 	return func(so *serveOptions) {
 		so.logLevel = level
@@ -0,0 +1,834 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// functionValueField is the field that holds the result of a function that is queried as a collection
+const functionValueField = "__value"
+
+// RequestValidator validates query and mutation requests against the schema of the connector,
+// so connectors receive only requests that refer to known collections, columns, operators, functions and relationships,
+// with argument values of the declared types.
+// Errors are [schema.UnprocessableContentError] with the JSON path of the invalid node in the path detail
+type RequestValidator struct {
+	schema      *schema.SchemaResponse
+	collections map[string]collectionSchema
+	procedures  map[string]schema.ProcedureInfo
+}
+
+// WithRequestValidation validates query and mutation requests against the schema of the connector
+// before they are explained or executed. Invalid requests are rejected with the 422 status
+func WithRequestValidation() ServeOption {
+	return func(so *serveOptions) {
+		so.requestValidation = true
+	}
+}
+
+// collectionSchema is the row type and the arguments of a collection or a function
+type collectionSchema struct {
+	name      string
+	fields    schema.ObjectTypeFields
+	arguments map[string]schema.ArgumentInfo
+}
+
+// NewRequestValidator creates a RequestValidator instance for the schema
+func NewRequestValidator(schemaResponse *schema.SchemaResponse) *RequestValidator {
+	rv := &RequestValidator{
+		schema:      schemaResponse,
+		collections: make(map[string]collectionSchema),
+		procedures:  make(map[string]schema.ProcedureInfo),
+	}
+	for _, collection := range schemaResponse.Collections {
+		var fields schema.ObjectTypeFields
+		if objectType, ok := schemaResponse.ObjectTypes[collection.Type]; ok {
+			fields = objectType.Fields
+		}
+		rv.collections[collection.Name] = collectionSchema{
+			name:      collection.Name,
+			fields:    fields,
+			arguments: collection.Arguments,
+		}
+	}
+	for _, function := range schemaResponse.Functions {
+		rv.collections[function.Name] = collectionSchema{
+			name: function.Name,
+			fields: schema.ObjectTypeFields{
+				functionValueField: schema.ObjectField{Type: function.ResultType},
+			},
+			arguments: function.Arguments,
+		}
+	}
+	for _, procedure := range schemaResponse.Procedures {
+		rv.procedures[procedure.Name] = procedure
+	}
+	return rv
+}
+
+// newRequestValidator creates a RequestValidator instance from the schema of a connector
+func newRequestValidator(schemaResponse schema.SchemaResponseMarshaler) (*RequestValidator, error) {
+	switch sr := schemaResponse.(type) {
+	case *schema.SchemaResponse:
+		return NewRequestValidator(sr), nil
+	case schema.SchemaResponse:
+		return NewRequestValidator(&sr), nil
+	}
+	rawSchema, err := schemaResponse.MarshalSchemaJSON()
+	if err != nil {
+		return nil, err
+	}
+	var result schema.SchemaResponse
+	if err := json.Unmarshal(rawSchema, &result); err != nil {
+		return nil, err
+	}
+	return NewRequestValidator(&result), nil
+}
+
+func validationError(path string, format string, args ...any) error {
+	return schema.UnprocessableContentError(fmt.Sprintf(format, args...), map[string]any{
+		"path": path,
+	})
+}
+
+// ValidateQueryRequest validates the query request against the schema
+func (rv *RequestValidator) ValidateQueryRequest(request *schema.QueryRequest) error {
+	collection, ok := rv.collections[request.Collection]
+	if !ok {
+		return validationError("$.collection", "unknown collection %s", request.Collection)
+	}
+	arguments := make(map[string]any, len(request.Arguments))
+	for name, argument := range request.Arguments {
+		arguments[name] = argument
+	}
+	if err := rv.validateArguments("$.arguments", collection, arguments, request.Variables); err != nil {
+		return err
+	}
+
+	qv := &queryValidator{
+		RequestValidator: rv,
+		relationships:    request.CollectionRelationships,
+		variables:        request.Variables,
+		root:             collection,
+	}
+	return qv.validateQuery("$.query", collection, &request.Query)
+}
+
+// ValidateMutationRequest validates the mutation request against the schema
+func (rv *RequestValidator) ValidateMutationRequest(request *schema.MutationRequest) error {
+	for i, operation := range request.Operations {
+		path := fmt.Sprintf("$.operations[%d]", i)
+		procedure, ok := rv.procedures[operation.Name]
+		if !ok {
+			return validationError(path+".name", "unknown procedure %s", operation.Name)
+		}
+
+		var rawArguments map[string]json.RawMessage
+		if len(operation.Arguments) > 0 {
+			if err := json.Unmarshal(operation.Arguments, &rawArguments); err != nil {
+				return validationError(path+".arguments", "arguments of procedure %s must be an object", operation.Name)
+			}
+		}
+		arguments := make(map[string]any, len(rawArguments))
+		for name, value := range rawArguments {
+			arguments[name] = value
+		}
+		procedureSchema := collectionSchema{
+			name:      procedure.Name,
+			arguments: procedure.Arguments,
+		}
+		if err := rv.validateArguments(path+".arguments", procedureSchema, arguments, nil); err != nil {
+			return err
+		}
+
+		if len(operation.Fields) > 0 {
+			qv := &queryValidator{
+				RequestValidator: rv,
+				relationships:    request.CollectionRelationships,
+			}
+			if err := qv.validateNestedField(path+".fields", procedure.ResultType, operation.Fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateArguments checks that the arguments are declared, that non-nullable arguments aren't missing or null,
+// and that the values of the arguments match their types
+func (rv *RequestValidator) validateArguments(path string, collection collectionSchema, arguments map[string]any, variables []schema.QueryRequestVariablesElem) error {
+	for name, value := range arguments {
+		argumentPath := fmt.Sprintf("%s.%s", path, name)
+		info, ok := collection.arguments[name]
+		if !ok {
+			return validationError(argumentPath, "unknown argument %s of %s", name, collection.name)
+		}
+		if !isNullableType(info.Type) && isNullArgument(value) {
+			return validationError(argumentPath, "argument %s of %s must not be null", name, collection.name)
+		}
+		if err := rv.validateArgumentValue(argumentPath, info.Type, value, variables); err != nil {
+			return err
+		}
+	}
+	for name, info := range collection.arguments {
+		if _, ok := arguments[name]; !ok && !isNullableType(info.Type) {
+			return validationError(path, "missing required argument %s of %s", name, collection.name)
+		}
+	}
+	return nil
+}
+
+// isNullArgument checks if an argument is a null literal
+func isNullArgument(value any) bool {
+	switch arg := value.(type) {
+	case schema.Argument:
+		return arg.Type == schema.ArgumentTypeLiteral && arg.Value == nil
+	case schema.RelationshipArgument:
+		return arg.Type == schema.RelationshipArgumentTypeLiteral && arg.Value == nil
+	case json.RawMessage:
+		return string(arg) == "null"
+	default:
+		return false
+	}
+}
+
+// validateArgumentValue checks that the value of an argument matches its type.
+// Values of variables are checked in every variable set of the request, and column arguments of relationships aren't checked
+func (rv *RequestValidator) validateArgumentValue(path string, argumentType schema.Type, value any, variables []schema.QueryRequestVariablesElem) error {
+	switch arg := value.(type) {
+	case schema.Argument:
+		if arg.Type == schema.ArgumentTypeVariable {
+			return rv.validateVariable(argumentType, arg.Name, variables)
+		}
+		return rv.validateValue(path+".value", argumentType, arg.Value)
+	case schema.RelationshipArgument:
+		switch arg.Type {
+		case schema.RelationshipArgumentTypeLiteral:
+			return rv.validateValue(path+".value", argumentType, arg.Value)
+		case schema.RelationshipArgumentTypeVariable:
+			return rv.validateVariable(argumentType, arg.Name, variables)
+		}
+		return nil
+	case json.RawMessage:
+		var decoded any
+		if err := json.Unmarshal(arg, &decoded); err != nil {
+			return validationError(path, "invalid argument value: %s", err)
+		}
+		return rv.validateValue(path, argumentType, decoded)
+	default:
+		return nil
+	}
+}
+
+// validateVariable checks that the value of the variable matches the type in every variable set
+func (rv *RequestValidator) validateVariable(valueType schema.Type, name string, variables []schema.QueryRequestVariablesElem) error {
+	for i, variableSet := range variables {
+		value, ok := variableSet[name]
+		if !ok {
+			return validationError(fmt.Sprintf("$.variables[%d]", i), "missing variable %s", name)
+		}
+		if err := rv.validateValue(fmt.Sprintf("$.variables[%d].%s", i, name), valueType, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue checks that a decoded JSON value matches the type.
+// Scalar values are checked against the representation of the scalar type, and predicate values aren't checked
+func (rv *RequestValidator) validateValue(path string, valueType schema.Type, value any) error {
+	typeValue, err := valueType.InterfaceT()
+	if err != nil {
+		return validationError(path, "invalid type: %s", err)
+	}
+	switch t := typeValue.(type) {
+	case *schema.NullableType:
+		if value == nil {
+			return nil
+		}
+		return rv.validateValue(path, t.UnderlyingType, value)
+	case *schema.ArrayType:
+		values, ok := value.([]any)
+		if !ok {
+			return validationError(path, "expected an array, got %s", jsonKind(value))
+		}
+		for i, item := range values {
+			if err := rv.validateValue(fmt.Sprintf("%s[%d]", path, i), t.ElementType, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *schema.NamedType:
+		if value == nil {
+			return validationError(path, "value of type %s must not be null", t.Name)
+		}
+		if objectType, ok := rv.schema.ObjectTypes[t.Name]; ok {
+			return rv.validateObjectValue(path, t.Name, objectType, value)
+		}
+		if scalarType, ok := rv.schema.ScalarTypes[t.Name]; ok {
+			return validateScalarValue(path, t.Name, scalarType.Representation, value)
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (rv *RequestValidator) validateObjectValue(path string, name string, objectType schema.ObjectType, value any) error {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return validationError(path, "expected an object of type %s, got %s", name, jsonKind(value))
+	}
+	for key, fieldValue := range object {
+		field, ok := objectType.Fields[key]
+		if !ok {
+			return validationError(fmt.Sprintf("%s.%s", path, key), "unknown field %s of object type %s", key, name)
+		}
+		if err := rv.validateValue(fmt.Sprintf("%s.%s", path, key), field.Type, fieldValue); err != nil {
+			return err
+		}
+	}
+	for key, field := range objectType.Fields {
+		if _, ok := object[key]; !ok && !isNullableType(field.Type) {
+			return validationError(path, "missing required field %s of object type %s", key, name)
+		}
+	}
+	return nil
+}
+
+// validateScalarValue checks that the value matches the representation of the scalar type.
+// Scalar types without a representation are JSON and accept any value
+func validateScalarValue(path string, name string, representation schema.TypeRepresentation, value any) error {
+	if len(representation) == 0 {
+		return nil
+	}
+	representationType, err := representation.Type()
+	if err != nil {
+		return nil
+	}
+	var valid bool
+	switch representationType {
+	case schema.TypeRepresentationTypeBoolean:
+		_, valid = value.(bool)
+	case schema.TypeRepresentationTypeString, schema.TypeRepresentationTypeUUID, schema.TypeRepresentationTypeDate,
+		schema.TypeRepresentationTypeTimestamp, schema.TypeRepresentationTypeTimestampTZ, schema.TypeRepresentationTypeBytes:
+		_, valid = value.(string)
+	case schema.TypeRepresentationTypeEnum:
+		str, ok := value.(string)
+		enum, err := representation.AsEnum()
+		valid = ok && err == nil && slices.Contains(enum.OneOf, str)
+	case schema.TypeRepresentationTypeInteger, schema.TypeRepresentationTypeInt8, schema.TypeRepresentationTypeInt16,
+		schema.TypeRepresentationTypeInt32, schema.TypeRepresentationTypeInt64:
+		number, ok := jsonNumber(value)
+		valid = ok && number == math.Trunc(number)
+	case schema.TypeRepresentationTypeNumber, schema.TypeRepresentationTypeFloat32, schema.TypeRepresentationTypeFloat64:
+		_, valid = jsonNumber(value)
+	case schema.TypeRepresentationTypeBigInteger, schema.TypeRepresentationTypeBigDecimal:
+		// big numbers are usually encoded as strings to keep their precision
+		_, isString := value.(string)
+		_, isNumber := jsonNumber(value)
+		valid = isString || isNumber
+	default:
+		valid = true
+	}
+	if !valid {
+		return validationError(path, "expected a value of scalar type %s, got %s", name, jsonKind(value))
+	}
+	return nil
+}
+
+// jsonNumber returns the value of a decoded JSON number
+func jsonNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// jsonKind returns the JSON kind of a decoded value for error messages
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	if _, ok := jsonNumber(value); ok {
+		return "a number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// queryValidator validates the nodes of a request with its collection relationships
+type queryValidator struct {
+	*RequestValidator
+	relationships map[string]schema.Relationship
+	variables     []schema.QueryRequestVariablesElem
+	// the collection of the innermost query, which root collection columns refer to
+	root collectionSchema
+}
+
+func (qv *queryValidator) validateQuery(path string, collection collectionSchema, query *schema.Query) error {
+	for alias, field := range query.Fields {
+		if err := qv.validateField(fmt.Sprintf("%s.fields.%s", path, alias), collection, field); err != nil {
+			return err
+		}
+	}
+	for alias, aggregate := range query.Aggregates {
+		if err := qv.validateAggregate(fmt.Sprintf("%s.aggregates.%s", path, alias), collection, aggregate); err != nil {
+			return err
+		}
+	}
+	if query.OrderBy != nil {
+		for i, element := range query.OrderBy.Elements {
+			if err := qv.validateOrderByTarget(fmt.Sprintf("%s.order_by.elements[%d].target", path, i), collection, element.Target); err != nil {
+				return err
+			}
+		}
+	}
+	return qv.validateExpression(path+".predicate", collection, query.Predicate)
+}
+
+func (qv *queryValidator) validateField(path string, collection collectionSchema, field schema.Field) error {
+	value, err := field.InterfaceT()
+	if err != nil {
+		return validationError(path, "invalid field: %s", err)
+	}
+	switch f := value.(type) {
+	case *schema.ColumnField:
+		columnType, err := qv.columnType(path+".column", collection, f.Column)
+		if err != nil {
+			return err
+		}
+		if len(f.Fields) > 0 {
+			return qv.validateNestedField(path+".fields", columnType, f.Fields)
+		}
+		return nil
+	case *schema.RelationshipField:
+		target, err := qv.resolveRelationship(path+".relationship", collection, f.Relationship, relationshipArguments(f.Arguments))
+		if err != nil {
+			return err
+		}
+		// the nested query is the root of the root collection columns in its predicates
+		nested := *qv
+		nested.root = target
+		return nested.validateQuery(path+".query", target, &f.Query)
+	default:
+		return validationError(path, "invalid field type %T", value)
+	}
+}
+
+// validateNestedField validates the nested field selection of a column or a procedure result of the type
+func (qv *queryValidator) validateNestedField(path string, fieldType schema.Type, nestedField schema.NestedField) error {
+	fieldType = unwrapNullableType(fieldType)
+	value, err := nestedField.InterfaceT()
+	if err != nil {
+		return validationError(path, "invalid nested field: %s", err)
+	}
+	switch nf := value.(type) {
+	case *schema.NestedObject:
+		named, err := fieldType.AsNamed()
+		if err != nil {
+			return validationError(path, "nested object fields can't select a value of %s type", typeKind(fieldType))
+		}
+		objectType, ok := qv.schema.ObjectTypes[named.Name]
+		if !ok {
+			return validationError(path, "nested object fields can't select a value of scalar type %s", named.Name)
+		}
+		nestedCollection := collectionSchema{
+			name:   named.Name,
+			fields: objectType.Fields,
+		}
+		for alias, field := range nf.Fields {
+			if err := qv.validateField(fmt.Sprintf("%s.fields.%s", path, alias), nestedCollection, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *schema.NestedArray:
+		array, err := fieldType.AsArray()
+		if err != nil {
+			return validationError(path, "nested array fields can't select a value of %s type", typeKind(fieldType))
+		}
+		return qv.validateNestedField(path+".fields", array.ElementType, nf.Fields)
+	default:
+		return validationError(path, "invalid nested field type %T", value)
+	}
+}
+
+func (qv *queryValidator) validateAggregate(path string, collection collectionSchema, aggregate schema.Aggregate) error {
+	value, err := aggregate.InterfaceT()
+	if err != nil {
+		return validationError(path, "invalid aggregate: %s", err)
+	}
+	switch agg := value.(type) {
+	case *schema.AggregateStarCount:
+		return nil
+	case *schema.AggregateColumnCount:
+		_, err := qv.columnType(path+".column", collection, agg.Column)
+		return err
+	case *schema.AggregateSingleColumn:
+		return qv.validateAggregateFunction(path, collection, agg.Column, agg.Function)
+	default:
+		return validationError(path, "invalid aggregate type %T", value)
+	}
+}
+
+func (qv *queryValidator) validateAggregateFunction(path string, collection collectionSchema, column string, function string) error {
+	columnType, err := qv.columnType(path+".column", collection, column)
+	if err != nil {
+		return err
+	}
+	scalarName, scalarType, ok := qv.scalarType(columnType)
+	if !ok {
+		return validationError(path+".column", "column %s of %s isn't a scalar and can't be aggregated", column, collection.name)
+	}
+	if _, ok := scalarType.AggregateFunctions[function]; !ok {
+		return validationError(path+".function", "unknown aggregate function %s of scalar type %s", function, scalarName)
+	}
+	return nil
+}
+
+func (qv *queryValidator) validateOrderByTarget(path string, collection collectionSchema, target schema.OrderByTarget) error {
+	value, err := target.InterfaceT()
+	if err != nil {
+		return validationError(path, "invalid order by target: %s", err)
+	}
+	switch t := value.(type) {
+	case *schema.OrderByColumn:
+		target, err := qv.resolvePath(path+".path", collection, t.Path)
+		if err != nil {
+			return err
+		}
+		_, err = qv.columnType(path+".name", target, t.Name)
+		return err
+	case *schema.OrderBySingleColumnAggregate:
+		target, err := qv.resolvePath(path+".path", collection, t.Path)
+		if err != nil {
+			return err
+		}
+		return qv.validateAggregateFunction(path, target, t.Column, t.Function)
+	case *schema.OrderByStarCountAggregate:
+		_, err := qv.resolvePath(path+".path", collection, t.Path)
+		return err
+	default:
+		return validationError(path, "invalid order by target type %T", value)
+	}
+}
+
+func (qv *queryValidator) validateExpression(path string, collection collectionSchema, expression schema.Expression) error {
+	if len(expression) == 0 {
+		return nil
+	}
+	value, err := expression.InterfaceT()
+	if err != nil {
+		return validationError(path, "invalid expression: %s", err)
+	}
+	switch expr := value.(type) {
+	case *schema.ExpressionAnd:
+		for i, item := range expr.Expressions {
+			if err := qv.validateExpression(fmt.Sprintf("%s.expressions[%d]", path, i), collection, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *schema.ExpressionOr:
+		for i, item := range expr.Expressions {
+			if err := qv.validateExpression(fmt.Sprintf("%s.expressions[%d]", path, i), collection, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *schema.ExpressionNot:
+		return qv.validateExpression(path+".expression", collection, expr.Expression)
+	case *schema.ExpressionUnaryComparisonOperator:
+		_, err := qv.validateComparisonTarget(path+".column", collection, expr.Column)
+		return err
+	case *schema.ExpressionBinaryComparisonOperator:
+		return qv.validateBinaryComparison(path, collection, expr)
+	case *schema.ExpressionExists:
+		return qv.validateExists(path, collection, expr)
+	default:
+		return validationError(path, "invalid expression type %T", value)
+	}
+}
+
+func (qv *queryValidator) validateBinaryComparison(path string, collection collectionSchema, expr *schema.ExpressionBinaryComparisonOperator) error {
+	columnType, err := qv.validateComparisonTarget(path+".column", collection, expr.Column)
+	if err != nil {
+		return err
+	}
+	scalarName, scalarType, ok := qv.scalarType(columnType)
+	if !ok {
+		return validationError(path+".column", "column %s isn't a scalar and can't be compared", expr.Column.Name)
+	}
+	operator, ok := scalarType.ComparisonOperators[expr.Operator]
+	if !ok {
+		return validationError(path+".operator", "unknown comparison operator %s of scalar type %s", expr.Operator, scalarName)
+	}
+
+	comparisonValue, err := expr.Value.InterfaceT()
+	if err != nil {
+		return validationError(path+".value", "invalid comparison value: %s", err)
+	}
+	switch cv := comparisonValue.(type) {
+	case *schema.ComparisonValueColumn:
+		_, err := qv.validateComparisonTarget(path+".value.column", collection, cv.Column)
+		return err
+	case *schema.ComparisonValueScalar:
+		operatorType, err := operator.Type()
+		if err == nil && operatorType == schema.ComparisonOperatorDefinitionTypeIn {
+			if _, ok := cv.Value.([]any); !ok {
+				return validationError(path+".value.value", "the value of operator %s must be an array", expr.Operator)
+			}
+		}
+		argumentType, err := comparisonArgumentType(columnType, operator)
+		if err != nil {
+			return validationError(path+".operator", "invalid comparison operator %s: %s", expr.Operator, err)
+		}
+		return qv.validateValue(path+".value.value", argumentType, cv.Value)
+	case *schema.ComparisonValueVariable:
+		argumentType, err := comparisonArgumentType(columnType, operator)
+		if err != nil {
+			return validationError(path+".operator", "invalid comparison operator %s: %s", expr.Operator, err)
+		}
+		return qv.validateVariable(argumentType, cv.Name, qv.variables)
+	default:
+		return validationError(path+".value", "invalid comparison value type %T", comparisonValue)
+	}
+}
+
+// comparisonArgumentType returns the type of the value that a column is compared with by the operator.
+// The equal operator takes a value of the column type, the in operator an array of them
+func comparisonArgumentType(columnType schema.Type, operator schema.ComparisonOperatorDefinition) (schema.Type, error) {
+	operatorType, err := operator.Type()
+	if err != nil {
+		return nil, err
+	}
+	switch operatorType {
+	case schema.ComparisonOperatorDefinitionTypeEqual:
+		return unwrapNullableType(columnType), nil
+	case schema.ComparisonOperatorDefinitionTypeIn:
+		return schema.NewArrayType(unwrapNullableType(columnType).Interface()).Encode(), nil
+	default:
+		custom, err := operator.AsCustom()
+		if err != nil {
+			return nil, err
+		}
+		return custom.ArgumentType, nil
+	}
+}
+
+func (qv *queryValidator) validateExists(path string, collection collectionSchema, expr *schema.ExpressionExists) error {
+	inCollection, err := expr.InCollection.InterfaceT()
+	if err != nil {
+		return validationError(path+".in_collection", "invalid exists collection: %s", err)
+	}
+
+	var target collectionSchema
+	switch ic := inCollection.(type) {
+	case *schema.ExistsInCollectionRelated:
+		target, err = qv.resolveRelationship(path+".in_collection.relationship", collection, ic.Relationship, relationshipArguments(ic.Arguments))
+		if err != nil {
+			return err
+		}
+	case *schema.ExistsInCollectionUnrelated:
+		var ok bool
+		target, ok = qv.collections[ic.Collection]
+		if !ok {
+			return validationError(path+".in_collection.collection", "unknown collection %s", ic.Collection)
+		}
+		if err := qv.validateArguments(path+".in_collection.arguments", target, relationshipArguments(ic.Arguments), qv.variables); err != nil {
+			return err
+		}
+	default:
+		return validationError(path+".in_collection", "invalid exists collection type %T", inCollection)
+	}
+	return qv.validateExpression(path+".predicate", target, expr.Predicate)
+}
+
+// validateComparisonTarget validates the column of a comparison and returns its type
+func (qv *queryValidator) validateComparisonTarget(path string, collection collectionSchema, target schema.ComparisonTarget) (schema.Type, error) {
+	switch target.Type {
+	case schema.ComparisonTargetTypeRootCollectionColumn:
+		return qv.columnType(path+".name", qv.root, target.Name)
+	default:
+		targetCollection, err := qv.resolvePath(path+".path", collection, target.Path)
+		if err != nil {
+			return nil, err
+		}
+		return qv.columnType(path+".name", targetCollection, target.Name)
+	}
+}
+
+// resolvePath follows the relationships of the path and returns the collection at the end of it
+func (qv *queryValidator) resolvePath(path string, collection collectionSchema, elements []schema.PathElement) (collectionSchema, error) {
+	for i, element := range elements {
+		elementPath := fmt.Sprintf("%s[%d]", path, i)
+		target, err := qv.resolveRelationship(elementPath+".relationship", collection, element.Relationship, relationshipArguments(element.Arguments))
+		if err != nil {
+			return target, err
+		}
+		if err := qv.validateExpression(elementPath+".predicate", target, element.Predicate); err != nil {
+			return target, err
+		}
+		collection = target
+	}
+	return collection, nil
+}
+
+// resolveRelationship validates the relationship from the source collection and returns its target collection
+func (qv *queryValidator) resolveRelationship(path string, source collectionSchema, name string, arguments map[string]any) (collectionSchema, error) {
+	relationship, ok := qv.relationships[name]
+	if !ok {
+		return collectionSchema{}, validationError(path, "unknown relationship %s", name)
+	}
+	relationshipPath := fmt.Sprintf("$.collection_relationships.%s", name)
+	target, ok := qv.collections[relationship.TargetCollection]
+	if !ok {
+		return target, validationError(relationshipPath+".target_collection", "unknown collection %s", relationship.TargetCollection)
+	}
+	for sourceColumn, targetColumn := range relationship.ColumnMapping {
+		if _, err := qv.columnType(fmt.Sprintf("%s.column_mapping.%s", relationshipPath, sourceColumn), source, sourceColumn); err != nil {
+			return target, err
+		}
+		if _, err := qv.columnType(fmt.Sprintf("%s.column_mapping.%s", relationshipPath, sourceColumn), target, targetColumn); err != nil {
+			return target, err
+		}
+	}
+
+	// arguments of the relationship are combined with the arguments of the field that follows it
+	allArguments := relationshipArguments(relationship.Arguments)
+	for key, value := range arguments {
+		allArguments[key] = value
+	}
+	if err := qv.validateArguments(path, target, allArguments, qv.variables); err != nil {
+		return target, err
+	}
+	return target, nil
+}
+
+func (qv *queryValidator) columnType(path string, collection collectionSchema, column string) (schema.Type, error) {
+	field, ok := collection.fields[column]
+	if !ok {
+		return nil, validationError(path, "unknown column %s of %s", column, collection.name)
+	}
+	return field.Type, nil
+}
+
+// scalarType returns the scalar type of a column type, which may be nullable
+func (qv *queryValidator) scalarType(columnType schema.Type) (string, schema.ScalarType, bool) {
+	named, err := unwrapNullableType(columnType).AsNamed()
+	if err != nil {
+		return "", schema.ScalarType{}, false
+	}
+	scalarType, ok := qv.schema.ScalarTypes[named.Name]
+	return named.Name, scalarType, ok
+}
+
+func relationshipArguments[T any](arguments map[string]T) map[string]any {
+	result := make(map[string]any, len(arguments))
+	for key, value := range arguments {
+		result[key] = value
+	}
+	return result
+}
+
+func unwrapNullableType(t schema.Type) schema.Type {
+	for {
+		nullable, err := t.AsNullable()
+		if err != nil {
+			return t
+		}
+		t = nullable.UnderlyingType
+	}
+}
+
+func isNullableType(t schema.Type) bool {
+	typeEnum, err := t.Type()
+	return err == nil && typeEnum == schema.TypeNullable
+}
+
+func typeKind(t schema.Type) string {
+	typeEnum, err := t.Type()
+	if err != nil {
+		return "unknown"
+	}
+	return string(typeEnum)
+}
+
+// requestValidator returns the request validator of the runtime, or nil if request validation is disabled.
+// The validator is cached for the configuration, unless the server is created with [WithDynamicSchema].
+// Errors aren't cached, so the next request retries
+func (s *Server[Configuration, State]) requestValidator(ctx context.Context, rt *serverRuntime[Configuration, State]) (*RequestValidator, error) {
+	if !s.requestValidation {
+		return nil, nil
+	}
+	if s.dynamicSchema {
+		return s.buildRequestValidator(ctx, rt)
+	}
+
+	rt.validatorLock.Lock()
+	defer rt.validatorLock.Unlock()
+	if rt.validator != nil {
+		return rt.validator, nil
+	}
+	// the cached validator must not depend on the cancellation of the request that builds it
+	validator, err := s.buildRequestValidator(context.WithoutCancel(ctx), rt)
+	if err != nil {
+		return nil, err
+	}
+	rt.validator = validator
+	return validator, nil
+}
+
+func (s *Server[Configuration, State]) buildRequestValidator(ctx context.Context, rt *serverRuntime[Configuration, State]) (*RequestValidator, error) {
+	schemaResponse, err := s.connector.GetSchema(ctx, rt.configuration, rt.state)
+	if err != nil {
+		return nil, err
+	}
+	if schemaResponse == nil {
+		return nil, schema.InternalServerError("schema is empty", nil)
+	}
+	return newRequestValidator(schemaResponse)
+}
+
+func (s *Server[Configuration, State]) validateQueryRequest(ctx context.Context, rt *serverRuntime[Configuration, State], request *schema.QueryRequest) error {
+	validator, err := s.requestValidator(ctx, rt)
+	if err != nil || validator == nil {
+		return err
+	}
+	return validator.ValidateQueryRequest(request)
+}
+
+func (s *Server[Configuration, State]) validateMutationRequest(ctx context.Context, rt *serverRuntime[Configuration, State], request *schema.MutationRequest) error {
+	validator, err := s.requestValidator(ctx, rt)
+	if err != nil || validator == nil {
+		return err
+	}
+	return validator.ValidateMutationRequest(request)
+}
@@ -0,0 +1,561 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+const validatorTestSchema = `{
+	"scalar_types": {
+		"Int": {
+			"representation": { "type": "int32" },
+			"aggregate_functions": {
+				"max": { "result_type": { "type": "nullable", "underlying_type": { "type": "named", "name": "Int" } } }
+			},
+			"comparison_operators": {
+				"_eq": { "type": "equal" },
+				"_in": { "type": "in" }
+			}
+		},
+		"String": {
+			"aggregate_functions": {},
+			"comparison_operators": {
+				"_like": { "type": "custom", "argument_type": { "type": "named", "name": "String" } }
+			}
+		}
+	},
+	"object_types": {
+		"article": {
+			"fields": {
+				"id": { "type": { "type": "named", "name": "Int" } },
+				"title": { "type": { "type": "named", "name": "String" } },
+				"author_id": { "type": { "type": "named", "name": "Int" } },
+				"tags": { "type": { "type": "array", "element_type": { "type": "named", "name": "tag" } } }
+			}
+		},
+		"author": {
+			"fields": {
+				"id": { "type": { "type": "named", "name": "Int" } },
+				"name": { "type": { "type": "named", "name": "String" } },
+				"address": { "type": { "type": "nullable", "underlying_type": { "type": "named", "name": "address" } } }
+			}
+		},
+		"address": {
+			"fields": {
+				"city": { "type": { "type": "named", "name": "String" } }
+			}
+		},
+		"tag": {
+			"fields": {
+				"name": { "type": { "type": "named", "name": "String" } }
+			}
+		}
+	},
+	"collections": [
+		{
+			"name": "articles",
+			"type": "article",
+			"arguments": {},
+			"uniqueness_constraints": {},
+			"foreign_keys": {}
+		},
+		{
+			"name": "authors",
+			"type": "author",
+			"arguments": {
+				"limit": { "type": { "type": "nullable", "underlying_type": { "type": "named", "name": "Int" } } }
+			},
+			"uniqueness_constraints": {},
+			"foreign_keys": {}
+		},
+		{
+			"name": "articles_by_author",
+			"type": "article",
+			"arguments": {
+				"author_id": { "type": { "type": "named", "name": "Int" } }
+			},
+			"uniqueness_constraints": {},
+			"foreign_keys": {}
+		}
+	],
+	"functions": [
+		{
+			"name": "latest_article_id",
+			"arguments": {},
+			"result_type": { "type": "nullable", "underlying_type": { "type": "named", "name": "Int" } }
+		}
+	],
+	"procedures": [
+		{
+			"name": "upsert_article",
+			"arguments": {
+				"article": { "type": { "type": "named", "name": "article" } },
+				"dry_run": { "type": { "type": "nullable", "underlying_type": { "type": "named", "name": "Boolean" } } }
+			},
+			"result_type": { "type": "nullable", "underlying_type": { "type": "named", "name": "article" } }
+		}
+	]
+}`
+
+const validatorTestRelationships = `{
+	"article_author": {
+		"column_mapping": { "author_id": "id" },
+		"relationship_type": "object",
+		"target_collection": "authors",
+		"arguments": {}
+	},
+	"author_articles": {
+		"column_mapping": { "id": "author_id" },
+		"relationship_type": "array",
+		"target_collection": "articles",
+		"arguments": {}
+	},
+	"invalid_mapping": {
+		"column_mapping": { "writer_id": "id" },
+		"relationship_type": "object",
+		"target_collection": "authors",
+		"arguments": {}
+	}
+}`
+
+func newTestRequestValidator(t *testing.T) *RequestValidator {
+	var schemaResponse schema.SchemaResponse
+	if err := json.Unmarshal([]byte(validatorTestSchema), &schemaResponse); err != nil {
+		t.Fatal(err)
+	}
+	return NewRequestValidator(&schemaResponse)
+}
+
+func TestValidateQueryRequest(t *testing.T) {
+	validator := newTestRequestValidator(t)
+
+	testCases := []struct {
+		name       string
+		collection string
+		arguments  string
+		variables  string
+		query      string
+		message    string
+		path       string
+	}{
+		{
+			name:       "valid",
+			collection: "articles",
+			query: `{
+				"fields": {
+					"id": { "type": "column", "column": "id" },
+					"tags": { "type": "column", "column": "tags", "fields": { "type": "array", "fields": { "type": "object", "fields": { "name": { "type": "column", "column": "name" } } } } },
+					"author": {
+						"type": "relationship",
+						"relationship": "article_author",
+						"arguments": { "limit": { "type": "literal", "value": 1 } },
+						"query": {
+							"fields": {
+								"address": { "type": "column", "column": "address", "fields": { "type": "object", "fields": { "city": { "type": "column", "column": "city" } } } }
+							}
+						}
+					}
+				},
+				"aggregates": {
+					"count": { "type": "star_count" },
+					"max_id": { "type": "single_column", "column": "id", "function": "max" }
+				},
+				"order_by": {
+					"elements": [
+						{ "order_direction": "asc", "target": { "type": "column", "name": "name", "path": [{ "relationship": "article_author", "arguments": {}, "predicate": { "type": "and", "expressions": [] } }] } }
+					]
+				},
+				"predicate": {
+					"type": "and",
+					"expressions": [
+						{ "type": "binary_comparison_operator", "column": { "type": "column", "name": "id", "path": [] }, "operator": "_in", "value": { "type": "scalar", "value": [1, 2] } },
+						{ "type": "binary_comparison_operator", "column": { "type": "column", "name": "title", "path": [] }, "operator": "_like", "value": { "type": "variable", "name": "title" } },
+						{ "type": "not", "expression": { "type": "unary_comparison_operator", "column": { "type": "column", "name": "author_id", "path": [] }, "operator": "is_null" } },
+						{
+							"type": "exists",
+							"in_collection": { "type": "related", "relationship": "article_author", "arguments": {} },
+							"predicate": { "type": "binary_comparison_operator", "column": { "type": "root_collection_column", "name": "author_id" }, "operator": "_eq", "value": { "type": "column", "column": { "type": "column", "name": "id", "path": [] } } }
+						}
+					]
+				}
+			}`,
+		},
+		{
+			name:       "function",
+			collection: "latest_article_id",
+			query:      `{ "fields": { "__value": { "type": "column", "column": "__value" } } }`,
+		},
+		{
+			name:       "unknown_collection",
+			collection: "comments",
+			query:      `{}`,
+			message:    "unknown collection comments",
+			path:       "$.collection",
+		},
+		{
+			name:       "missing_argument",
+			collection: "articles_by_author",
+			query:      `{}`,
+			message:    "missing required argument author_id of articles_by_author",
+			path:       "$.arguments",
+		},
+		{
+			name:       "null_argument",
+			collection: "articles_by_author",
+			arguments:  `{ "author_id": { "type": "literal", "value": null } }`,
+			query:      `{}`,
+			message:    "argument author_id of articles_by_author must not be null",
+			path:       "$.arguments.author_id",
+		},
+		{
+			name:       "argument_type",
+			collection: "authors",
+			arguments:  `{ "limit": { "type": "literal", "value": "ten" } }`,
+			query:      `{}`,
+			message:    "expected a value of scalar type Int, got a string",
+			path:       "$.arguments.limit.value",
+		},
+		{
+			name:       "variable_type",
+			collection: "authors",
+			arguments:  `{ "limit": { "type": "variable", "name": "limit" } }`,
+			variables:  `[{ "limit": 10 }, { "limit": 1.5 }]`,
+			query:      `{}`,
+			message:    "expected a value of scalar type Int, got a number",
+			path:       "$.variables[1].limit",
+		},
+		{
+			name:       "unknown_argument",
+			collection: "articles",
+			arguments:  `{ "author_id": { "type": "literal", "value": 1 } }`,
+			query:      `{}`,
+			message:    "unknown argument author_id of articles",
+			path:       "$.arguments.author_id",
+		},
+		{
+			name:       "unknown_column",
+			collection: "articles",
+			query:      `{ "fields": { "body": { "type": "column", "column": "body" } } }`,
+			message:    "unknown column body of articles",
+			path:       "$.query.fields.body.column",
+		},
+		{
+			name:       "unknown_nested_column",
+			collection: "authors",
+			query:      `{ "fields": { "address": { "type": "column", "column": "address", "fields": { "type": "object", "fields": { "zip": { "type": "column", "column": "zip" } } } } } }`,
+			message:    "unknown column zip of address",
+			path:       "$.query.fields.address.fields.fields.zip.column",
+		},
+		{
+			name:       "nested_object_of_scalar",
+			collection: "articles",
+			query:      `{ "fields": { "title": { "type": "column", "column": "title", "fields": { "type": "object", "fields": {} } } } }`,
+			message:    "nested object fields can't select a value of scalar type String",
+			path:       "$.query.fields.title.fields",
+		},
+		{
+			name:       "unknown_relationship",
+			collection: "articles",
+			query:      `{ "fields": { "author": { "type": "relationship", "relationship": "author", "arguments": {}, "query": {} } } }`,
+			message:    "unknown relationship author",
+			path:       "$.query.fields.author.relationship",
+		},
+		{
+			name:       "invalid_column_mapping",
+			collection: "articles",
+			query:      `{ "fields": { "author": { "type": "relationship", "relationship": "invalid_mapping", "arguments": {}, "query": {} } } }`,
+			message:    "unknown column writer_id of articles",
+			path:       "$.collection_relationships.invalid_mapping.column_mapping.writer_id",
+		},
+		{
+			name:       "relationship_query",
+			collection: "authors",
+			query:      `{ "fields": { "articles": { "type": "relationship", "relationship": "author_articles", "arguments": {}, "query": { "fields": { "name": { "type": "column", "column": "name" } } } } } }`,
+			message:    "unknown column name of articles",
+			path:       "$.query.fields.articles.query.fields.name.column",
+		},
+		{
+			name:       "relationship_root_collection_column",
+			collection: "articles",
+			query:      `{ "fields": { "author": { "type": "relationship", "relationship": "article_author", "arguments": {}, "query": { "predicate": { "type": "binary_comparison_operator", "column": { "type": "root_collection_column", "name": "title" }, "operator": "_like", "value": { "type": "scalar", "value": "a" } } } } } }`,
+			message:    "unknown column title of authors",
+			path:       "$.query.fields.author.query.predicate.column.name",
+		},
+		{
+			name:       "unknown_aggregate_function",
+			collection: "articles",
+			query:      `{ "aggregates": { "sum_id": { "type": "single_column", "column": "id", "function": "sum" } } }`,
+			message:    "unknown aggregate function sum of scalar type Int",
+			path:       "$.query.aggregates.sum_id.function",
+		},
+		{
+			name:       "order_by_path",
+			collection: "articles",
+			query:      `{ "order_by": { "elements": [{ "order_direction": "asc", "target": { "type": "column", "name": "title", "path": [{ "relationship": "article_author", "arguments": {} }] } }] } }`,
+			message:    "unknown column title of authors",
+			path:       "$.query.order_by.elements[0].target.name",
+		},
+		{
+			name:       "unknown_operator",
+			collection: "articles",
+			query:      `{ "predicate": { "type": "or", "expressions": [{ "type": "binary_comparison_operator", "column": { "type": "column", "name": "id", "path": [] }, "operator": "_gt", "value": { "type": "scalar", "value": 1 } }] } }`,
+			message:    "unknown comparison operator _gt of scalar type Int",
+			path:       "$.query.predicate.expressions[0].operator",
+		},
+		{
+			name:       "in_operator_value",
+			collection: "articles",
+			query:      `{ "predicate": { "type": "binary_comparison_operator", "column": { "type": "column", "name": "id", "path": [] }, "operator": "_in", "value": { "type": "scalar", "value": 1 } } }`,
+			message:    "the value of operator _in must be an array",
+			path:       "$.query.predicate.value.value",
+		},
+		{
+			name:       "comparison_value_type",
+			collection: "articles",
+			query:      `{ "predicate": { "type": "binary_comparison_operator", "column": { "type": "column", "name": "id", "path": [] }, "operator": "_in", "value": { "type": "scalar", "value": [1, "2"] } } }`,
+			message:    "expected a value of scalar type Int, got a string",
+			path:       "$.query.predicate.value.value[1]",
+		},
+		{
+			name:       "comparison_variable_missing",
+			collection: "articles",
+			query:      `{ "predicate": { "type": "binary_comparison_operator", "column": { "type": "column", "name": "id", "path": [] }, "operator": "_eq", "value": { "type": "variable", "name": "id" } } }`,
+			variables:  `[{ "id": 1 }, {}]`,
+			message:    "missing variable id",
+			path:       "$.variables[1]",
+		},
+		{
+			name:       "comparison_variable_type",
+			collection: "articles",
+			query:      `{ "predicate": { "type": "binary_comparison_operator", "column": { "type": "column", "name": "id", "path": [] }, "operator": "_eq", "value": { "type": "variable", "name": "id" } } }`,
+			variables:  `[{ "id": "1" }]`,
+			message:    "expected a value of scalar type Int, got a string",
+			path:       "$.variables[0].id",
+		},
+		{
+			name:       "exists_unrelated",
+			collection: "articles",
+			query:      `{ "predicate": { "type": "exists", "in_collection": { "type": "unrelated", "collection": "authors", "arguments": {} }, "predicate": { "type": "binary_comparison_operator", "column": { "type": "column", "name": "title", "path": [] }, "operator": "_like", "value": { "type": "scalar", "value": "a" } } } }`,
+			message:    "unknown column title of authors",
+			path:       "$.query.predicate.predicate.column.name",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			arguments := tc.arguments
+			if arguments == "" {
+				arguments = "{}"
+			}
+			variables := tc.variables
+			if variables == "" {
+				variables = "null"
+			}
+			rawRequest := fmt.Sprintf(`{"collection": %q, "arguments": %s, "collection_relationships": %s, "query": %s, "variables": %s}`, tc.collection, arguments, validatorTestRelationships, tc.query, variables)
+			var request schema.QueryRequest
+			if err := json.Unmarshal([]byte(rawRequest), &request); err != nil {
+				t.Fatal(err)
+			}
+			assertValidationError(t, validator.ValidateQueryRequest(&request), tc.message, tc.path)
+		})
+	}
+}
+
+func TestValidateMutationRequest(t *testing.T) {
+	validator := newTestRequestValidator(t)
+
+	testCases := []struct {
+		name      string
+		operation string
+		message   string
+		path      string
+	}{
+		{
+			name:      "valid",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "article": { "id": 1, "title": "a", "author_id": 1, "tags": [{ "name": "b" }] } }, "fields": { "type": "object", "fields": { "id": { "type": "column", "column": "id" } } } }`,
+		},
+		{
+			name:      "unknown_procedure",
+			operation: `{ "type": "procedure", "name": "delete_article", "arguments": {} }`,
+			message:   "unknown procedure delete_article",
+			path:      "$.operations[0].name",
+		},
+		{
+			name:      "missing_argument",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "dry_run": true } }`,
+			message:   "missing required argument article of upsert_article",
+			path:      "$.operations[0].arguments",
+		},
+		{
+			name:      "null_argument",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "article": null } }`,
+			message:   "argument article of upsert_article must not be null",
+			path:      "$.operations[0].arguments.article",
+		},
+		{
+			name:      "argument_field_type",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "article": { "id": "1", "title": "a", "author_id": 1, "tags": [] } } }`,
+			message:   "expected a value of scalar type Int, got a string",
+			path:      "$.operations[0].arguments.article.id",
+		},
+		{
+			name:      "argument_missing_field",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "article": { "id": 1, "title": "a", "author_id": 1 } } }`,
+			message:   "missing required field tags of object type article",
+			path:      "$.operations[0].arguments.article",
+		},
+		{
+			name:      "argument_nested_field",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "article": { "id": 1, "title": "a", "author_id": 1, "tags": [{ "label": "b" }] } } }`,
+			message:   "unknown field label of object type tag",
+			path:      "$.operations[0].arguments.article.tags[0].label",
+		},
+		{
+			name:      "unknown_field",
+			operation: `{ "type": "procedure", "name": "upsert_article", "arguments": { "article": { "id": 1, "title": "a", "author_id": 1, "tags": [] } }, "fields": { "type": "object", "fields": { "name": { "type": "column", "column": "name" } } } }`,
+			message:   "unknown column name of article",
+			path:      "$.operations[0].fields.fields.name.column",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rawRequest := fmt.Sprintf(`{"operations": [%s], "collection_relationships": {}}`, tc.operation)
+			var request schema.MutationRequest
+			if err := json.Unmarshal([]byte(rawRequest), &request); err != nil {
+				t.Fatal(err)
+			}
+			assertValidationError(t, validator.ValidateMutationRequest(&request), tc.message, tc.path)
+		})
+	}
+}
+
+func assertValidationError(t *testing.T, err error, message string, path string) {
+	if message == "" {
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Errorf("expected error %s, got nil", message)
+		t.FailNow()
+	}
+	connectorErr, ok := err.(*schema.ConnectorError)
+	if !ok {
+		t.Errorf("expected a connector error, got %T", err)
+		t.FailNow()
+	}
+	expected := schema.UnprocessableContentError(message, map[string]any{"path": path})
+	if !internal.DeepEqual(expected, connectorErr) {
+		t.Errorf("expected %+v, got %+v", expected, connectorErr)
+	}
+}
+
+func TestServerRequestValidation(t *testing.T) {
+	server, err := NewServer[mockConfiguration, mockState](&mockConnector{}, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	}, WithRequestValidation())
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	httpServer := server.BuildTestServer()
+	defer httpServer.Close()
+
+	res, err := httpPostJSON(fmt.Sprintf("%s/query", httpServer.URL), schema.QueryRequest{
+		Collection:              "comments",
+		Arguments:               schema.QueryRequestArguments{},
+		CollectionRelationships: schema.QueryRequestCollectionRelationships{},
+		Query:                   schema.Query{},
+		Variables:               []schema.QueryRequestVariablesElem{},
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponse(t, res, http.StatusUnprocessableEntity, schema.ErrorResponse{
+		Message: "unknown collection comments",
+		Details: map[string]any{
+			"path": "$.collection",
+		},
+	})
+
+	res, err = httpPostJSON(fmt.Sprintf("%s/mutation/explain", httpServer.URL), schema.MutationRequest{
+		Operations: []schema.MutationOperation{
+			{
+				Type:      schema.MutationOperationProcedure,
+				Name:      "upsert_article",
+				Arguments: json.RawMessage(`{"article": {"id": 1, "title": "a", "author_id": 1}}`),
+			},
+		},
+		CollectionRelationships: schema.MutationRequestCollectionRelationships{},
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	assertHTTPResponseStatus(t, "POST /mutation/explain", res, http.StatusOK)
+}
+
+// flakySchemaConnector fails to get the schema until it's ready
+type flakySchemaConnector struct {
+	mockConnector
+	ready bool
+	calls int
+}
+
+func (fc *flakySchemaConnector) GetSchema(ctx context.Context, configuration *mockConfiguration, state *mockState) (schema.SchemaResponseMarshaler, error) {
+	fc.calls++
+	if !fc.ready {
+		return nil, schema.InternalServerError("schema is unavailable", nil)
+	}
+	return mockSchema, nil
+}
+
+func TestRequestValidatorRetry(t *testing.T) {
+	connector := &flakySchemaConnector{}
+	server, err := NewServer[mockConfiguration, mockState](connector, &ServerOptions{
+		Configuration: "{}",
+		InlineConfig:  true,
+	}, WithRequestValidation())
+	if err != nil {
+		t.Errorf("NewServer: expected no error, got %s", err)
+		t.FailNow()
+	}
+	rt := server.requestTenant(context.Background()).acquireRuntime()
+	defer rt.release()
+
+	if _, err := server.requestValidator(context.Background(), rt); err == nil {
+		t.Fatal("expected an error of the unavailable schema")
+	}
+	// errors aren't cached, and the validator is built with a canceled request context
+	connector.ready = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	validator, err := server.requestValidator(ctx, rt)
+	if err != nil || validator == nil {
+		t.Fatalf("expected the validator, got %v", err)
+	}
+	if cached, _ := server.requestValidator(context.Background(), rt); cached != validator {
+		t.Errorf("expected the cached validator")
+	}
+	if connector.calls != 2 {
+		t.Errorf("expected 2 schema calls, got %d", connector.calls)
+	}
+
+	server.dynamicSchema = true
+	if _, err := server.requestValidator(context.Background(), rt); err != nil {
+		t.Fatal(err)
+	}
+	if connector.calls != 3 {
+		t.Errorf("expected the schema to be fetched again with a dynamic schema, got %d calls", connector.calls)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, configurationFileName)
+	if err := os.WriteFile(configPath, []byte(`{"host": "localhost", "port": 3306, "db": "chinook"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{dir, configPath} {
+		config, err := (&Connector{}).ParseConfiguration(context.Background(), path)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %s", path, err)
+			continue
+		}
+		if config.Host != "localhost" || config.Port != 3306 || config.DB != "chinook" {
+			t.Errorf("%s: unexpected configuration %+v", path, config)
+		}
+	}
+
+	if _, err := (&Connector{}).ParseConfiguration(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error of the missing configuration")
+	}
+	if err := os.WriteFile(configPath, []byte(`{"port": "3306"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Connector{}).ParseConfiguration(context.Background(), dir); err == nil {
+		t.Error("expected an error of the invalid configuration")
+	}
+}
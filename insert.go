@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+const (
+	insertProcedurePrefix = "insert_"
+	upsertProcedurePrefix = "upsert_"
+	// the default max_allowed_packet of MySQL 8, used if the server variable can not be introspected
+	defaultMaxAllowedPacket = 64 << 20
+	// the maximum number of placeholders in a prepared statement
+	maxPlaceholders = 65535
+	// the estimated size of the type and length prefix of a statement argument in the binary protocol
+	argumentOverhead = 9
+)
+
+// compileInsert compiles multi-row INSERT statements of the objects.
+// Columns that an object omits are inserted with their DEFAULT values.
+// Rows are split into batches so that every statement and its arguments fit in max_allowed_packet.
+// If upsert is enabled, rows that conflict with a primary or unique key update the existing row instead.
+// It returns the statements and the number of rows that each statement inserts
+func compileInsert(collection string, objects []map[string]any, upsert bool, maxAllowedPacket int) ([]compiledStatement, []int, error) {
+	columnSet := map[string]bool{}
+	for _, object := range objects {
+		for column := range object {
+			columnSet[column] = true
+		}
+	}
+	columns := sortedKeys(columnSet)
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = quoteIdentifier(column)
+	}
+
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", quoteIdentifier(collection), strings.Join(quotedColumns, ", "))
+	var suffix string
+	if upsert {
+		if len(columns) == 0 {
+			return nil, nil, schema.UnprocessableContentError("upsert requires at least one column", nil)
+		}
+		assignments := make([]string, len(quotedColumns))
+		for i, column := range quotedColumns {
+			assignments[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+		}
+		suffix = " ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+	}
+	if maxAllowedPacket <= 0 {
+		maxAllowedPacket = defaultMaxAllowedPacket
+	}
+
+	statements := []compiledStatement{}
+	rowCounts := []int{}
+	var tuples []string
+	var arguments []any
+	size := len(prefix) + len(suffix)
+	flush := func() {
+		statements = append(statements, compiledStatement{
+			SQL:       prefix + strings.Join(tuples, ", ") + suffix,
+			Arguments: arguments,
+		})
+		rowCounts = append(rowCounts, len(tuples))
+		tuples = nil
+		arguments = nil
+		size = len(prefix) + len(suffix)
+	}
+
+	for i, object := range objects {
+		values := make([]string, len(columns))
+		var rowArguments []any
+		// the separator between tuples
+		rowSize := 2
+		for j, column := range columns {
+			value, ok := object[column]
+			if !ok {
+				values[j] = "DEFAULT"
+				continue
+			}
+			values[j] = "?"
+			rowArguments = append(rowArguments, value)
+			rowSize += argumentSize(value)
+		}
+		tuple := "(" + strings.Join(values, ", ") + ")"
+		rowSize += len(tuple)
+
+		if len(prefix)+len(suffix)+rowSize > maxAllowedPacket {
+			return nil, nil, schema.UnprocessableContentError(fmt.Sprintf("object %d exceeds max_allowed_packet of %d bytes", i, maxAllowedPacket), nil)
+		}
+		if len(tuples) > 0 && (size+rowSize > maxAllowedPacket || len(arguments)+len(rowArguments) > maxPlaceholders) {
+			flush()
+		}
+		tuples = append(tuples, tuple)
+		arguments = append(arguments, rowArguments...)
+		size += rowSize
+	}
+	if len(tuples) > 0 {
+		flush()
+	}
+
+	return statements, rowCounts, nil
+}
+
+// argumentSize estimates the size of a statement argument in the packet
+func argumentSize(value any) int {
+	switch v := value.(type) {
+	case nil:
+		return argumentOverhead
+	case string:
+		return argumentOverhead + len(v)
+	case []byte:
+		return argumentOverhead + len(v)
+	default:
+		return argumentOverhead + len(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+func TestCompileInsert(t *testing.T) {
+	objects := []map[string]any{
+		{"InvoiceId": float64(1), "TrackId": float64(2), "UnitPrice": 0.99},
+		{"InvoiceId": float64(1), "TrackId": float64(4)},
+		{"InvoiceId": float64(2), "TrackId": float64(6), "UnitPrice": 1.99},
+	}
+
+	testCases := []struct {
+		name             string
+		upsert           bool
+		maxAllowedPacket int
+		expected         []compiledStatement
+		expectedRows     []int
+	}{
+		{
+			name: "insert",
+			expected: []compiledStatement{
+				{
+					SQL:       "INSERT INTO `InvoiceLine` (`InvoiceId`, `TrackId`, `UnitPrice`) VALUES (?, ?, ?), (?, ?, DEFAULT), (?, ?, ?)",
+					Arguments: []any{float64(1), float64(2), 0.99, float64(1), float64(4), float64(2), float64(6), 1.99},
+				},
+			},
+			expectedRows: []int{3},
+		},
+		{
+			name:   "upsert",
+			upsert: true,
+			expected: []compiledStatement{
+				{
+					SQL:       "INSERT INTO `InvoiceLine` (`InvoiceId`, `TrackId`, `UnitPrice`) VALUES (?, ?, ?), (?, ?, DEFAULT), (?, ?, ?) ON DUPLICATE KEY UPDATE `InvoiceId` = VALUES(`InvoiceId`), `TrackId` = VALUES(`TrackId`), `UnitPrice` = VALUES(`UnitPrice`)",
+					Arguments: []any{float64(1), float64(2), 0.99, float64(1), float64(4), float64(2), float64(6), 1.99},
+				},
+			},
+			expectedRows: []int{3},
+		},
+		{
+			name:             "batches",
+			maxAllowedPacket: 160,
+			expected: []compiledStatement{
+				{
+					SQL:       "INSERT INTO `InvoiceLine` (`InvoiceId`, `TrackId`, `UnitPrice`) VALUES (?, ?, ?), (?, ?, DEFAULT)",
+					Arguments: []any{float64(1), float64(2), 0.99, float64(1), float64(4)},
+				},
+				{
+					SQL:       "INSERT INTO `InvoiceLine` (`InvoiceId`, `TrackId`, `UnitPrice`) VALUES (?, ?, ?)",
+					Arguments: []any{float64(2), float64(6), 1.99},
+				},
+			},
+			expectedRows: []int{2, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statements, rows, err := compileInsert("InvoiceLine", objects, tc.upsert, tc.maxAllowedPacket)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if !reflect.DeepEqual(statements, tc.expected) {
+				t.Errorf("\nexpected: %+v\ngot: %+v", tc.expected, statements)
+			}
+			if !reflect.DeepEqual(rows, tc.expectedRows) {
+				t.Errorf("expected rows %v, got %v", tc.expectedRows, rows)
+			}
+		})
+	}
+
+	t.Run("object_too_large", func(t *testing.T) {
+		_, _, err := compileInsert("InvoiceLine", objects, false, 50)
+		if err == nil || err.Error() != "object 0 exceeds max_allowed_packet of 50 bytes" {
+			t.Errorf("expected max_allowed_packet error, got %v", err)
+		}
+	})
+}
+
+type mockResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (mr mockResult) LastInsertId() (int64, error) {
+	return mr.lastInsertID, nil
+}
+
+func (mr mockResult) RowsAffected() (int64, error) {
+	return mr.rowsAffected, nil
+}
+
+func TestExecuteInsertProcedure(t *testing.T) {
+	configuration := &Configuration{
+		Schema: Schema{
+			Collections: []Collection{{Name: "Customer"}, {Name: "Artist"}},
+		},
+		Permissions: testPermissions,
+	}
+	state := &State{
+		MaxAllowedPacket:       90,
+		AutoIncrementColumns:   map[string]string{"Artist": "ArtistId"},
+		AutoIncrementIncrement: 1,
+	}
+
+	// the objects are split into 2 statements, and another client inserts rows between them
+	lastInsertIDs := []int64{10, 20}
+	exec := func(ctx context.Context, query string, arguments ...any) (sql.Result, error) {
+		result := mockResult{
+			lastInsertID: lastInsertIDs[0],
+			rowsAffected: int64(len(arguments)),
+		}
+		lastInsertIDs = lastInsertIDs[1:]
+		return result, nil
+	}
+
+	var operation schema.MutationOperation
+	if err := json.Unmarshal([]byte(`{
+		"type": "procedure",
+		"name": "insert_Artist",
+		"arguments": {
+			"objects": [{ "Name": "Lenny Kravitz" }, { "Name": "Nirvana" }, { "Name": "Pink Floyd" }]
+		}
+	}`), &operation); err != nil {
+		t.Errorf("failed to decode mutation operation: %s", err)
+		t.FailNow()
+	}
+
+	result, err := executeProcedure(context.Background(), exec, configuration, state, nil, &operation)
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	expected := map[string]any{
+		"affected_rows":  int64(3),
+		"generated_keys": []any{int64(10), int64(11), int64(20)},
+	}
+	if !internal.DeepEqual(expected, result) {
+		t.Errorf("expected: %v, got: %v", expected, result)
+	}
+
+	t.Run("upsert", func(t *testing.T) {
+		// MySQL counts 2 affected rows for every row that an upsert updates
+		upsertExec := func(ctx context.Context, query string, arguments ...any) (sql.Result, error) {
+			return mockResult{lastInsertID: 30, rowsAffected: int64(2 * len(arguments))}, nil
+		}
+		result, err := executeProcedure(context.Background(), upsertExec, configuration, &State{
+			MaxAllowedPacket:       1024,
+			AutoIncrementColumns:   state.AutoIncrementColumns,
+			AutoIncrementIncrement: 1,
+		}, nil, &schema.MutationOperation{
+			Type:      schema.MutationOperationProcedure,
+			Name:      "upsert_Artist",
+			Arguments: json.RawMessage(`{ "objects": [{ "ArtistId": 1, "Name": "AC/DC" }, { "ArtistId": 2, "Name": "Accept" }] }`),
+		})
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		expected := map[string]any{
+			"affected_rows":  int64(2),
+			"generated_keys": []any{nil, nil},
+		}
+		if !internal.DeepEqual(expected, result) {
+			t.Errorf("expected: %v, got: %v", expected, result)
+		}
+	})
+
+	t.Run("explicit_keys", func(t *testing.T) {
+		mixedExec := func(ctx context.Context, query string, arguments ...any) (sql.Result, error) {
+			return mockResult{lastInsertID: 10, rowsAffected: 3}, nil
+		}
+		result, err := executeProcedure(context.Background(), mixedExec, configuration, &State{
+			MaxAllowedPacket:       1024,
+			AutoIncrementColumns:   state.AutoIncrementColumns,
+			AutoIncrementIncrement: 1,
+		}, nil, &schema.MutationOperation{
+			Type:      schema.MutationOperationProcedure,
+			Name:      "insert_Artist",
+			Arguments: json.RawMessage(`{ "objects": [{ "Name": "Lenny Kravitz" }, { "ArtistId": 500, "Name": "Nirvana" }, { "Name": "Pink Floyd" }] }`),
+		})
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+			t.FailNow()
+		}
+		// the statement mixes generated and explicit keys, so the generated keys aren't consecutive
+		expected := map[string]any{
+			"affected_rows":  int64(3),
+			"generated_keys": []any{nil, nil, nil},
+		}
+		if !internal.DeepEqual(expected, result) {
+			t.Errorf("expected: %v, got: %v", expected, result)
+		}
+	})
+
+	t.Run("auto_increment_increment", func(t *testing.T) {
+		// group replication allocates keys at an interval of the number of members
+		for _, tc := range []struct {
+			increment int
+			expected  []any
+		}{
+			{increment: 7, expected: []any{int64(10), int64(17), int64(24)}},
+			{increment: 0, expected: []any{nil, nil, nil}},
+		} {
+			incrementExec := func(ctx context.Context, query string, arguments ...any) (sql.Result, error) {
+				return mockResult{lastInsertID: 10, rowsAffected: 3}, nil
+			}
+			result, err := executeProcedure(context.Background(), incrementExec, configuration, &State{
+				MaxAllowedPacket:       1024,
+				AutoIncrementColumns:   state.AutoIncrementColumns,
+				AutoIncrementIncrement: tc.increment,
+			}, nil, &schema.MutationOperation{
+				Type:      schema.MutationOperationProcedure,
+				Name:      "insert_Artist",
+				Arguments: json.RawMessage(`{ "objects": [{ "Name": "Lenny Kravitz" }, { "Name": "Nirvana" }, { "Name": "Pink Floyd" }] }`),
+			})
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			expected := map[string]any{
+				"affected_rows":  int64(3),
+				"generated_keys": tc.expected,
+			}
+			if !internal.DeepEqual(expected, result) {
+				t.Errorf("increment %d: expected: %v, got: %v", tc.increment, expected, result)
+			}
+		}
+	})
+
+	t.Run("row_level_security", func(t *testing.T) {
+		_, err := executeProcedure(context.Background(), exec, configuration, state, nil, &schema.MutationOperation{
+			Type:      schema.MutationOperationProcedure,
+			Name:      "upsert_Customer",
+			Arguments: json.RawMessage(`{ "objects": [{ "CustomerId": 1 }] }`),
+		})
+		if err == nil || err.Error() != "inserting into collection Customer with a row-level security permission is not supported" {
+			t.Errorf("expected not supported error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// fullTextIndexes are the column lists of the FULLTEXT indexes, keyed by collection name
+type fullTextIndexes map[string][][]string
+
+// HasColumn checks if the collection has a FULLTEXT index on exactly the column, so that MATCH(column) can use it
+func (fi fullTextIndexes) HasColumn(collection string, column string) bool {
+	return slices.ContainsFunc(fi[collection], func(columns []string) bool {
+		return len(columns) == 1 && columns[0] == column
+	})
+}
+
+// introspectFullTextIndexes reads the FULLTEXT indexes of the database from information_schema
+func introspectFullTextIndexes(ctx context.Context, fetch rowFetcher, database string) (fullTextIndexes, error) {
+	rows, err := fetch(ctx, "SELECT `TABLE_NAME` AS `table_name`, `INDEX_NAME` AS `index_name`, `COLUMN_NAME` AS `column_name` FROM `information_schema`.`STATISTICS` WHERE `TABLE_SCHEMA` = ? AND `INDEX_TYPE` = 'FULLTEXT' ORDER BY `TABLE_NAME`, `INDEX_NAME`, `SEQ_IN_INDEX`", database)
+	if err != nil {
+		return nil, err
+	}
+
+	results := fullTextIndexes{}
+	var lastTable, lastIndex string
+	for _, row := range rows {
+		table, tableOk := row["table_name"].(string)
+		index, indexOk := row["index_name"].(string)
+		column, columnOk := row["column_name"].(string)
+		if !tableOk || !indexOk || !columnOk {
+			return nil, fmt.Errorf("invalid FULLTEXT index row: %v", row)
+		}
+		if table != lastTable || index != lastIndex {
+			results[table] = append(results[table], []string{})
+			lastTable, lastIndex = table, index
+		}
+		indexes := results[table]
+		indexes[len(indexes)-1] = append(indexes[len(indexes)-1], column)
+	}
+	return results, nil
+}
+
+// introspectMaxAllowedPacket reads the max_allowed_packet variable of the server
+func introspectMaxAllowedPacket(ctx context.Context, fetch rowFetcher) (int, error) {
+	return introspectIntegerVariable(ctx, fetch, "max_allowed_packet")
+}
+
+// introspectAutoIncrementIncrement reads the auto_increment_increment variable of the server,
+// the interval between the keys of the rows that a statement inserts
+func introspectAutoIncrementIncrement(ctx context.Context, fetch rowFetcher) (int, error) {
+	return introspectIntegerVariable(ctx, fetch, "auto_increment_increment")
+}
+
+// introspectIntegerVariable reads an integer system variable of the server
+func introspectIntegerVariable(ctx context.Context, fetch rowFetcher, name string) (int, error) {
+	rows, err := fetch(ctx, fmt.Sprintf("SELECT @@%s AS %s", name, quoteIdentifier(name)))
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("%s is not found", name)
+	}
+	switch value := rows[0][name].(type) {
+	case int64:
+		return int(value), nil
+	case uint64:
+		return int(value), nil
+	case string:
+		return strconv.Atoi(value)
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", name, value)
+	}
+}
+
+// introspectAutoIncrementColumns reads the AUTO_INCREMENT column of every table of the database, keyed by table name
+func introspectAutoIncrementColumns(ctx context.Context, fetch rowFetcher, database string) (map[string]string, error) {
+	rows, err := fetch(ctx, "SELECT `TABLE_NAME` AS `table_name`, `COLUMN_NAME` AS `column_name` FROM `information_schema`.`COLUMNS` WHERE `TABLE_SCHEMA` = ? AND `EXTRA` LIKE '%auto_increment%'", database)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]string, len(rows))
+	for _, row := range rows {
+		table, tableOk := row["table_name"].(string)
+		column, columnOk := row["column_name"].(string)
+		if !tableOk || !columnOk {
+			return nil, fmt.Errorf("invalid AUTO_INCREMENT column row: %v", row)
+		}
+		results[table] = column
+	}
+	return results, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+)
+
+func TestIntrospectFullTextIndexes(t *testing.T) {
+	fetch := mockRowFetcher(t, map[string][]map[string]any{
+		"SELECT `TABLE_NAME` AS `table_name`, `INDEX_NAME` AS `index_name`, `COLUMN_NAME` AS `column_name` FROM `information_schema`.`STATISTICS` WHERE `TABLE_SCHEMA` = ? AND `INDEX_TYPE` = 'FULLTEXT' ORDER BY `TABLE_NAME`, `INDEX_NAME`, `SEQ_IN_INDEX` [Chinook]": {
+			{"table_name": "Album", "index_name": "IFT_AlbumTitle", "column_name": "Title"},
+			{"table_name": "Track", "index_name": "IFT_TrackName", "column_name": "Name"},
+			{"table_name": "Track", "index_name": "IFT_TrackSearch", "column_name": "Name"},
+			{"table_name": "Track", "index_name": "IFT_TrackSearch", "column_name": "Composer"},
+		},
+	})
+
+	indexes, err := introspectFullTextIndexes(context.Background(), fetch, "Chinook")
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	expected := fullTextIndexes{
+		"Album": {{"Title"}},
+		"Track": {{"Name"}, {"Name", "Composer"}},
+	}
+	if !internal.DeepEqual(expected, indexes) {
+		t.Errorf("expected: %v, got: %v", expected, indexes)
+		t.FailNow()
+	}
+	if !indexes.HasColumn("Track", "Name") || indexes.HasColumn("Track", "Composer") || indexes.HasColumn("Artist", "Name") {
+		t.Errorf("unexpected FULLTEXT columns of %v", indexes)
+	}
+}
+
+func TestIntrospectAutoIncrementColumns(t *testing.T) {
+	fetch := mockRowFetcher(t, map[string][]map[string]any{
+		"SELECT `TABLE_NAME` AS `table_name`, `COLUMN_NAME` AS `column_name` FROM `information_schema`.`COLUMNS` WHERE `TABLE_SCHEMA` = ? AND `EXTRA` LIKE '%auto_increment%' [Chinook]": {
+			{"table_name": "Artist", "column_name": "ArtistId"},
+			{"table_name": "Album", "column_name": "AlbumId"},
+		},
+	})
+
+	columns, err := introspectAutoIncrementColumns(context.Background(), fetch, "Chinook")
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	expected := map[string]string{
+		"Artist": "ArtistId",
+		"Album":  "AlbumId",
+	}
+	if !internal.DeepEqual(expected, columns) {
+		t.Errorf("expected: %v, got: %v", expected, columns)
+	}
+}
+
+func TestIntrospectAutoIncrementIncrement(t *testing.T) {
+	fetch := mockRowFetcher(t, map[string][]map[string]any{
+		"SELECT @@auto_increment_increment AS `auto_increment_increment` []": {
+			{"auto_increment_increment": uint64(3)},
+		},
+	})
+
+	increment, err := introspectAutoIncrementIncrement(context.Background(), fetch)
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+	if increment != 3 {
+		t.Errorf("expected 3, got %d", increment)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+const (
+	deleteProcedurePrefix = "delete_"
+	updateProcedurePrefix = "update_"
+)
+
+// statementExecutor executes a SQL statement
+type statementExecutor func(ctx context.Context, query string, arguments ...any) (sql.Result, error)
+
+// procedureArguments are the decoded arguments of a generated procedure.
+// Arguments other than where, set and objects are the session arguments of the request. Null session arguments are omitted
+type procedureArguments struct {
+	Where            schema.Expression
+	Set              map[string]any
+	Objects          []map[string]any
+	SessionArguments map[string]any
+}
+
+// compiledProcedure is the statements of a procedure, executed in order in the transaction of the mutation
+type compiledProcedure struct {
+	Statements []compiledStatement
+	// numbers of rows that each statement inserts, set if the procedure returns generated keys
+	InsertedRows []int
+	// whether the keys of the rows that each statement inserts can be derived from LAST_INSERT_ID
+	DerivableKeys []bool
+	// the statements are upserts, whose rows may update existing rows instead of inserting
+	Upsert bool
+}
+
+func decodeProcedureArguments(rawArguments json.RawMessage) (*procedureArguments, error) {
+	result := &procedureArguments{
+		SessionArguments: map[string]any{},
+	}
+	if len(rawArguments) == 0 {
+		return result, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rawArguments, &raw); err != nil {
+		return nil, schema.UnprocessableContentError(fmt.Sprintf("failed to decode procedure arguments: %s", err), nil)
+	}
+	for key, rawValue := range raw {
+		var err error
+		switch key {
+		case "where":
+			if string(rawValue) != "null" {
+				err = json.Unmarshal(rawValue, &result.Where)
+			}
+		case "set":
+			err = json.Unmarshal(rawValue, &result.Set)
+		case "objects":
+			err = json.Unmarshal(rawValue, &result.Objects)
+		default:
+			var value any
+			err = json.Unmarshal(rawValue, &value)
+			if value != nil {
+				result.SessionArguments[key] = value
+			}
+		}
+		if err != nil {
+			return nil, schema.UnprocessableContentError(fmt.Sprintf("invalid argument %s: %s", key, err), nil)
+		}
+	}
+	return result, nil
+}
+
+// executeProcedure executes a generated procedure of a collection.
+// Insert and upsert procedures also return the generated key of every object, or null if it is unknown.
+// Keys are derived from LAST_INSERT_ID, the key of the first row that a statement inserts,
+// because InnoDB allocates keys at the auto_increment_increment interval to the rows of a multi-row insert whose rows all generate their keys.
+// The keys are unknown if the interval can't be introspected.
+// The keys of upserts, which may update existing rows, and of statements that set the AUTO_INCREMENT column of some rows are unknown.
+// Every object of an upsert counts as one affected row, although MySQL counts 2 for a row that it updates
+func executeProcedure(ctx context.Context, exec statementExecutor, configuration *Configuration, state *State, relationships map[string]schema.Relationship, operation *schema.MutationOperation) (any, error) {
+	procedure, err := compileProcedure(configuration, state, relationships, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	var affectedRows int64
+	var generatedKeys []any
+	if procedure.InsertedRows != nil {
+		generatedKeys = []any{}
+	}
+	for i, statement := range procedure.Statements {
+		result, err := exec(ctx, statement.SQL, statement.Arguments...)
+		if err != nil {
+			return nil, err
+		}
+		if procedure.InsertedRows == nil {
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			affectedRows += rows
+			continue
+		}
+
+		insertedRows := procedure.InsertedRows[i]
+		if procedure.Upsert {
+			affectedRows += int64(insertedRows)
+		} else {
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			affectedRows += rows
+		}
+
+		var lastInsertID int64
+		if procedure.DerivableKeys[i] && state.AutoIncrementIncrement > 0 {
+			lastInsertID, err = result.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+		}
+		for j := 0; j < insertedRows; j++ {
+			if lastInsertID == 0 {
+				generatedKeys = append(generatedKeys, nil)
+			} else {
+				generatedKeys = append(generatedKeys, lastInsertID+int64(j*state.AutoIncrementIncrement))
+			}
+		}
+	}
+
+	results := map[string]any{
+		"affected_rows": affectedRows,
+	}
+	if generatedKeys != nil {
+		results["generated_keys"] = generatedKeys
+	}
+	return results, nil
+}
+
+func compileProcedure(configuration *Configuration, state *State, relationships map[string]schema.Relationship, operation *schema.MutationOperation) (*compiledProcedure, error) {
+	if operation.Type != schema.MutationOperationProcedure {
+		return nil, schema.UnprocessableContentError(fmt.Sprintf("invalid mutation operation type: %s", operation.Type), nil)
+	}
+
+	arguments, err := decodeProcedureArguments(operation.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	compiler := newQueryCompiler(relationships, nil).
+		WithPermissions(configuration.Permissions, arguments.SessionArguments).
+		WithFullTextIndexes(state.FullTextIndexes)
+
+	var statement *compiledStatement
+	if collection, ok := strings.CutPrefix(operation.Name, deleteProcedurePrefix); ok && hasCollection(configuration, collection) {
+		statement, err = compiler.CompileDelete(collection, arguments.Where)
+	} else if collection, ok := strings.CutPrefix(operation.Name, updateProcedurePrefix); ok && hasCollection(configuration, collection) {
+		statement, err = compiler.CompileUpdate(collection, arguments.Set, arguments.Where)
+	} else if collection, ok := strings.CutPrefix(operation.Name, insertProcedurePrefix); ok && hasCollection(configuration, collection) {
+		return compileInsertProcedure(configuration, state, collection, arguments.Objects, false)
+	} else if collection, ok := strings.CutPrefix(operation.Name, upsertProcedurePrefix); ok && hasCollection(configuration, collection) {
+		return compileInsertProcedure(configuration, state, collection, arguments.Objects, true)
+	} else {
+		return nil, schema.UnprocessableContentError(fmt.Sprintf("invalid procedure name: %s", operation.Name), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiledProcedure{
+		Statements: []compiledStatement{*statement},
+	}, nil
+}
+
+// compileInsertProcedure compiles the batched statements of an insert or upsert procedure.
+// Inserted rows can't be filtered by a predicate, so collections with row-level security don't support them
+func compileInsertProcedure(configuration *Configuration, state *State, collection string, objects []map[string]any, upsert bool) (*compiledProcedure, error) {
+	if permission, ok := configuration.Permissions[collection]; ok && len(permission.Predicate) > 0 {
+		return nil, schema.NotSupportedError(fmt.Sprintf("inserting into collection %s with a row-level security permission is not supported", collection), nil)
+	}
+
+	statements, insertedRows, err := compileInsert(collection, objects, upsert, state.MaxAllowedPacket)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledProcedure{
+		Statements:    statements,
+		InsertedRows:  insertedRows,
+		DerivableKeys: derivableKeys(state.AutoIncrementColumns[collection], objects, insertedRows, upsert),
+		Upsert:        upsert,
+	}, nil
+}
+
+// derivableKeys checks for every insert statement if all of its rows generate their keys,
+// so the keys are consecutive from LAST_INSERT_ID. Rows that set the AUTO_INCREMENT column, even to 0, make a mixed-mode insert
+func derivableKeys(keyColumn string, objects []map[string]any, insertedRows []int, upsert bool) []bool {
+	results := make([]bool, len(insertedRows))
+	if upsert || keyColumn == "" {
+		return results
+	}
+	offset := 0
+	for i, rows := range insertedRows {
+		results[i] = !slices.ContainsFunc(objects[offset:offset+rows], func(object map[string]any) bool {
+			return object[keyColumn] != nil
+		})
+		offset += rows
+	}
+	return results
+}
+
+// CompileDelete compiles a delete statement of the rows matching the predicate and the permission of the collection
+func (qc *queryCompiler) CompileDelete(collection string, where schema.Expression) (*compiledStatement, error) {
+	tableAlias := qc.nextTableAlias()
+	conditions, arguments, err := qc.compileFilter(collection, tableAlias, where)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := fmt.Sprintf("DELETE %s FROM %s AS %s", quoteIdentifier(tableAlias), quoteIdentifier(collection), quoteIdentifier(tableAlias))
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return &compiledStatement{
+		SQL:       statement,
+		Arguments: arguments,
+	}, nil
+}
+
+// CompileUpdate compiles an update statement of the rows matching the predicate and the permission of the collection
+func (qc *queryCompiler) CompileUpdate(collection string, set map[string]any, where schema.Expression) (*compiledStatement, error) {
+	if len(set) == 0 {
+		return nil, schema.UnprocessableContentError("argument set is required", nil)
+	}
+
+	tableAlias := qc.nextTableAlias()
+	columns := sortedKeys(set)
+	assignments := make([]string, len(columns))
+	arguments := make([]any, len(columns))
+	for i, column := range columns {
+		assignments[i] = fmt.Sprintf("%s = ?", qualifyColumn(tableAlias, column))
+		arguments[i] = set[column]
+	}
+
+	conditions, whereArguments, err := qc.compileFilter(collection, tableAlias, where)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := fmt.Sprintf("UPDATE %s AS %s SET %s", quoteIdentifier(collection), quoteIdentifier(tableAlias), strings.Join(assignments, ", "))
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return &compiledStatement{
+		SQL:       statement,
+		Arguments: append(arguments, whereArguments...),
+	}, nil
+}
+
+func hasCollection(configuration *Configuration, name string) bool {
+	return slices.ContainsFunc(configuration.Schema.Collections, func(collection Collection) bool {
+		return collection.Name == name
+	})
+}
+
+// newStatementExecutor creates a statementExecutor that runs statements in the transaction
+func newStatementExecutor(tx *sql.Tx) statementExecutor {
+	return func(ctx context.Context, query string, arguments ...any) (sql.Result, error) {
+		result, err := tx.ExecContext(ctx, query, arguments...)
+		if err != nil {
+			return nil, schema.InternalServerError("database statement failed", map[string]any{
+				"cause": err.Error(),
+			})
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+func TestCompileProcedure(t *testing.T) {
+	configuration := &Configuration{
+		Schema: Schema{
+			Collections: []Collection{{Name: "Customer"}},
+		},
+		Permissions: testPermissions,
+	}
+
+	testCases := []struct {
+		name              string
+		operation         string
+		expectedSQL       string
+		expectedArguments []any
+	}{
+		{
+			name: "delete",
+			operation: `{
+				"type": "procedure",
+				"name": "delete_Customer",
+				"arguments": {
+					"countries": ["Brazil"],
+					"where": {
+						"type": "binary_comparison_operator",
+						"column": { "type": "column", "name": "CustomerId" },
+						"operator": "_eq",
+						"value": { "type": "scalar", "value": 1 }
+					}
+				}
+			}`,
+			expectedSQL:       "DELETE `t0` FROM `Customer` AS `t0` WHERE (`t0`.`Country` IN (?)) AND `t0`.`CustomerId` = ?",
+			expectedArguments: []any{"Brazil", float64(1)},
+		},
+		{
+			name: "update",
+			operation: `{
+				"type": "procedure",
+				"name": "update_Customer",
+				"arguments": {
+					"countries": ["Brazil", "Canada"],
+					"set": { "Phone": "+55 (12) 3923-5555", "Company": "Embraer" }
+				}
+			}`,
+			expectedSQL:       "UPDATE `Customer` AS `t0` SET `t0`.`Company` = ?, `t0`.`Phone` = ? WHERE (`t0`.`Country` IN (?, ?))",
+			expectedArguments: []any{"Embraer", "+55 (12) 3923-5555", "Brazil", "Canada"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var operation schema.MutationOperation
+			if err := json.Unmarshal([]byte(tc.operation), &operation); err != nil {
+				t.Errorf("failed to decode mutation operation: %s", err)
+				t.FailNow()
+			}
+			procedure, err := compileProcedure(configuration, &State{}, nil, &operation)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if len(procedure.Statements) != 1 {
+				t.Errorf("expected 1 statement, got %d", len(procedure.Statements))
+				t.FailNow()
+			}
+			statement := procedure.Statements[0]
+			if statement.SQL != tc.expectedSQL {
+				t.Errorf("\nexpected: %s\ngot: %s", tc.expectedSQL, statement.SQL)
+			}
+			if !reflect.DeepEqual(statement.Arguments, tc.expectedArguments) {
+				t.Errorf("expected arguments %v, got %v", tc.expectedArguments, statement.Arguments)
+			}
+		})
+	}
+
+	t.Run("invalid_procedure", func(t *testing.T) {
+		_, err := compileProcedure(configuration, &State{}, nil, &schema.MutationOperation{
+			Type: schema.MutationOperationProcedure,
+			Name: "delete_Album",
+		})
+		if err == nil || err.Error() != "invalid procedure name: delete_Album" {
+			t.Errorf("expected invalid procedure error, got %v", err)
+		}
+	})
+}
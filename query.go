@@ -0,0 +1,881 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+// the maximum value of LIMIT that MySQL accepts, used when only OFFSET is requested
+const maxLimit = "18446744073709551615"
+
+const (
+	inOperator    = "_in"
+	matchOperator = "_match"
+)
+
+// comparisonOperators maps binary comparison operators of the connector schema to MySQL conditions,
+// formatted with the compiled column and the compiled value.
+// The _in and _match operators are compiled separately
+var comparisonOperators = map[string]string{
+	"_eq":     "%s = %s",
+	"_lte":    "%s <= %s",
+	"_like":   "%s LIKE %s",
+	"_ilike":  "LOWER(%s) LIKE LOWER(%s)",
+	"_nlike":  "%s NOT LIKE %s",
+	"_regex":  "REGEXP_LIKE(%s, %s, 'c')",
+	"_iregex": "REGEXP_LIKE(%s, %s, 'i')",
+}
+
+// relationshipBatchSize is the maximum number of distinct join values of the parent rows in a relationship subquery
+const relationshipBatchSize = 1000
+
+// joinIndexAlias is the SQL alias of the index of the parent key of the rows of a relationship subquery
+const joinIndexAlias = "k"
+
+// rowFetcher executes a SQL statement and returns its rows keyed by the column aliases of the statement
+type rowFetcher func(ctx context.Context, query string, arguments ...any) ([]map[string]any, error)
+
+// compiledStatement is a parameterized SQL statement
+type compiledStatement struct {
+	SQL       string
+	Arguments []any
+}
+
+// compiledQuery is the SQL statement of one level of a query.
+// Nested relationship fields are compiled into subqueries that are executed once for all rows of their parent level,
+// in batches of relationshipBatchSize parent rows
+type compiledQuery struct {
+	compiledStatement
+	// projections of the requested fields, sorted by their NDC aliases
+	Fields []fieldProjection
+	// the join of a relationship subquery, nil for the root query and relationships without column mapping.
+	// The SQL of the subquery is the statement of a single parent row
+	Join *compiledJoin
+}
+
+// compiledJoin builds the statements of a relationship subquery for batches of parent rows.
+// The join values of the batch are joined with the target collection in SQL, so they are compared with the collation
+// of the target columns, and every row returns the index of its parent key in the batch as joinIndexAlias
+type compiledJoin struct {
+	TableAlias string
+	// the projections of the subquery
+	Selects []string
+	// the quoted name of the target collection
+	Table string
+	// the qualified target columns of the relationship
+	Columns []string
+	// conditions of the predicate and the permission of the target collection
+	Filter  []string
+	OrderBy string
+	Limit   *int
+	Offset  *int
+}
+
+// fieldProjection maps an NDC field alias to the generated SQL alias of its column,
+// or to the compiled subquery of a relationship field
+type fieldProjection struct {
+	Alias        string
+	SQLAlias     string
+	Relationship *compiledRelationship
+}
+
+// compiledRelationship is the subquery of a relationship field
+type compiledRelationship struct {
+	Query *compiledQuery
+	// generated SQL aliases of the source columns in the parent row,
+	// in the same order as the join placeholders of the subquery
+	SourceAliases []string
+}
+
+// queryCompiler compiles NDC queries into parameterized MySQL statements.
+// Every requested field is projected with a generated SQL alias,
+// so two aliases of the same column never collapse into one key
+type queryCompiler struct {
+	relationships    map[string]schema.Relationship
+	variables        map[string]any
+	permissions      map[string]Permission
+	sessionArguments map[string]any
+	// collections whose permission predicates are being compiled, to stop self-referencing policies
+	activePermissions map[string]bool
+	fullTextIndexes   fullTextIndexes
+	tableCount        int
+}
+
+// expressionScope is the context that an expression is compiled in
+type expressionScope struct {
+	// the collection and the alias of the table that column targets refer to
+	collection string
+	tableAlias string
+	// the collection and the alias of the table of the current query, that root_collection_column targets refer to
+	rootCollection string
+	rootAlias      string
+	// values of the variables that comparison values refer to
+	variables map[string]any
+	// the collection whose permission predicate is being compiled, if any
+	permission string
+}
+
+func newQueryCompiler(relationships map[string]schema.Relationship, variables map[string]any) *queryCompiler {
+	return &queryCompiler{
+		relationships:     relationships,
+		variables:         variables,
+		activePermissions: map[string]bool{},
+	}
+}
+
+// WithPermissions enables row-level security policies. Variables of the policy predicates are evaluated with the session arguments of the request
+func (qc *queryCompiler) WithPermissions(permissions map[string]Permission, sessionArguments map[string]any) *queryCompiler {
+	qc.permissions = permissions
+	qc.sessionArguments = sessionArguments
+	return qc
+}
+
+// WithFullTextIndexes enables the _match operator on the columns of the FULLTEXT indexes
+func (qc *queryCompiler) WithFullTextIndexes(indexes fullTextIndexes) *queryCompiler {
+	qc.fullTextIndexes = indexes
+	return qc
+}
+
+// Compile compiles the query of a collection
+func (qc *queryCompiler) Compile(collection string, query *schema.Query) (*compiledQuery, error) {
+	return qc.compileQuery(collection, query, nil)
+}
+
+func (qc *queryCompiler) nextTableAlias() string {
+	alias := fmt.Sprintf("t%d", qc.tableCount)
+	qc.tableCount++
+	return alias
+}
+
+// compileQuery compiles a query. joinColumns are the target columns of a relationship,
+// the query of a relationship is compiled into a join that matches them with the join values of a batch of parent rows
+func (qc *queryCompiler) compileQuery(collection string, query *schema.Query, joinColumns []string) (*compiledQuery, error) {
+	if len(query.Aggregates) > 0 {
+		return nil, schema.NotSupportedError("aggregates are not supported", nil)
+	}
+
+	tableAlias := qc.nextTableAlias()
+	result := &compiledQuery{}
+
+	var selects []string
+	// hidden projections of the source columns that relationship fields join on
+	joinSources := map[string]string{}
+
+	for i, alias := range sortedKeys(query.Fields) {
+		field := query.Fields[alias]
+		fieldValue, err := field.InterfaceT()
+		if err != nil {
+			return nil, schema.UnprocessableContentError(err.Error(), map[string]any{
+				"field": alias,
+			})
+		}
+		switch f := fieldValue.(type) {
+		case *schema.ColumnField:
+			if len(f.Fields) > 0 {
+				return nil, schema.NotSupportedError(fmt.Sprintf("nested fields of column %s are not supported", f.Column), nil)
+			}
+			sqlAlias := fmt.Sprintf("f%d", i)
+			selects = append(selects, fmt.Sprintf("%s AS %s", qualifyColumn(tableAlias, f.Column), quoteIdentifier(sqlAlias)))
+			result.Fields = append(result.Fields, fieldProjection{
+				Alias:    alias,
+				SQLAlias: sqlAlias,
+			})
+		case *schema.RelationshipField:
+			relationship, ok := qc.relationships[f.Relationship]
+			if !ok {
+				return nil, schema.UnprocessableContentError(fmt.Sprintf("invalid relationship name %s", f.Relationship), nil)
+			}
+			if len(f.Arguments) > 0 || len(relationship.Arguments) > 0 {
+				return nil, schema.NotSupportedError("relationship arguments are not supported", nil)
+			}
+
+			sourceColumns := sortedKeys(relationship.ColumnMapping)
+			targetColumns := make([]string, len(sourceColumns))
+			sourceAliases := make([]string, len(sourceColumns))
+			for j, sourceColumn := range sourceColumns {
+				sourceAlias, ok := joinSources[sourceColumn]
+				if !ok {
+					sourceAlias = fmt.Sprintf("j%d", len(joinSources))
+					joinSources[sourceColumn] = sourceAlias
+					selects = append(selects, fmt.Sprintf("%s AS %s", qualifyColumn(tableAlias, sourceColumn), quoteIdentifier(sourceAlias)))
+				}
+				sourceAliases[j] = sourceAlias
+				targetColumns[j] = relationship.ColumnMapping[sourceColumn]
+			}
+
+			subQuery, err := qc.compileQuery(relationship.TargetCollection, &f.Query, targetColumns)
+			if err != nil {
+				return nil, err
+			}
+			result.Fields = append(result.Fields, fieldProjection{
+				Alias: alias,
+				Relationship: &compiledRelationship{
+					Query:         subQuery,
+					SourceAliases: sourceAliases,
+				},
+			})
+		default:
+			return nil, schema.UnprocessableContentError("invalid field", map[string]any{
+				"value": field,
+			})
+		}
+	}
+
+	if len(joinColumns) > 0 {
+		join := &compiledJoin{
+			TableAlias: tableAlias,
+			Table:      quoteIdentifier(collection),
+			Limit:      query.Limit,
+			Offset:     query.Offset,
+		}
+		for _, column := range joinColumns {
+			join.Columns = append(join.Columns, qualifyColumn(tableAlias, column))
+		}
+		join.Selects = append(selects, fmt.Sprintf("%s AS %s", join.keyColumn(joinIndexAlias), quoteIdentifier(joinIndexAlias)))
+		result.Join = join
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	if len(selects) == 0 {
+		sb.WriteString("1")
+	} else {
+		sb.WriteString(strings.Join(selects, ", "))
+	}
+	sb.WriteString(" FROM ")
+	sb.WriteString(quoteIdentifier(collection))
+	sb.WriteString(" AS ")
+	sb.WriteString(quoteIdentifier(tableAlias))
+
+	filter, arguments, err := qc.compileFilter(collection, tableAlias, query.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	result.Arguments = arguments
+	if len(filter) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(filter, " AND "))
+	}
+
+	var orderBy string
+	if query.OrderBy != nil && len(query.OrderBy.Elements) > 0 {
+		orderBy, err = compileOrderBy(tableAlias, query.OrderBy)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(orderBy)
+	}
+
+	if query.Limit != nil {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", *query.Limit))
+	}
+	if query.Offset != nil {
+		if query.Limit == nil {
+			sb.WriteString(" LIMIT " + maxLimit)
+		}
+		sb.WriteString(fmt.Sprintf(" OFFSET %d", *query.Offset))
+	}
+
+	result.SQL = sb.String()
+	if result.Join != nil {
+		result.Join.Filter = filter
+		result.Join.OrderBy = orderBy
+		result.SQL = result.Join.statement(1)
+	}
+	return result, nil
+}
+
+// statement builds the subquery of a batch of parent rows. The placeholders of the join values of every parent row
+// come first, followed by the arguments of the filter.
+// The limit and the offset apply to the rows of every parent row, so they are numbered in partitions of the parent keys
+func (cj *compiledJoin) statement(parentCount int) string {
+	var keys strings.Builder
+	for i := range parentCount {
+		if i == 0 {
+			keys.WriteString("SELECT 0 AS " + quoteIdentifier(joinIndexAlias))
+			for j := range cj.Columns {
+				keys.WriteString(fmt.Sprintf(", ? AS %s", quoteIdentifier(fmt.Sprintf("c%d", j))))
+			}
+			continue
+		}
+		keys.WriteString(fmt.Sprintf(" UNION ALL SELECT %d", i))
+		keys.WriteString(strings.Repeat(", ?", len(cj.Columns)))
+	}
+	conditions := make([]string, len(cj.Columns))
+	for i, column := range cj.Columns {
+		conditions[i] = fmt.Sprintf("%s = %s", column, cj.keyColumn(fmt.Sprintf("c%d", i)))
+	}
+	from := fmt.Sprintf(" FROM (%s) AS %s INNER JOIN %s AS %s ON %s", keys.String(), quoteIdentifier(cj.TableAlias+"_keys"),
+		cj.Table, quoteIdentifier(cj.TableAlias), strings.Join(conditions, " AND "))
+	var where string
+	if len(cj.Filter) > 0 {
+		where = " WHERE " + strings.Join(cj.Filter, " AND ")
+	}
+
+	if cj.Limit == nil && cj.Offset == nil {
+		sql := "SELECT " + strings.Join(cj.Selects, ", ") + from + where
+		if cj.OrderBy != "" {
+			sql += " ORDER BY " + cj.OrderBy
+		}
+		return sql
+	}
+
+	window := "PARTITION BY " + cj.keyColumn(joinIndexAlias)
+	if cj.OrderBy != "" {
+		window += " ORDER BY " + cj.OrderBy
+	}
+	var bounds []string
+	offset := 0
+	if cj.Offset != nil {
+		offset = *cj.Offset
+		bounds = append(bounds, fmt.Sprintf("`rn` > %d", offset))
+	}
+	if cj.Limit != nil {
+		bounds = append(bounds, fmt.Sprintf("`rn` <= %d", offset+*cj.Limit))
+	}
+	return fmt.Sprintf("SELECT * FROM (SELECT %s, ROW_NUMBER() OVER (%s) AS `rn`%s%s) AS %s WHERE %s ORDER BY `rn`",
+		strings.Join(cj.Selects, ", "), window, from, where, quoteIdentifier(cj.TableAlias+"_rows"), strings.Join(bounds, " AND "))
+}
+
+// keyColumn qualifies a column of the derived table of the parent keys
+func (cj *compiledJoin) keyColumn(name string) string {
+	return qualifyColumn(cj.TableAlias+"_keys", name)
+}
+
+func compileOrderBy(tableAlias string, orderBy *schema.OrderBy) (string, error) {
+	elements := make([]string, 0, len(orderBy.Elements))
+	for _, element := range orderBy.Elements {
+		target, err := element.Target.AsColumn()
+		if err != nil {
+			return "", schema.NotSupportedError(err.Error(), map[string]any{
+				"value": element.Target,
+			})
+		}
+		if len(target.Path) > 0 {
+			return "", schema.NotSupportedError(fmt.Sprintf("ordering by relationship columns is not supported: %s", target.Name), nil)
+		}
+		direction := "ASC"
+		if element.OrderDirection == schema.OrderDirectionDesc {
+			direction = "DESC"
+		}
+		elements = append(elements, fmt.Sprintf("%s %s", qualifyColumn(tableAlias, target.Name), direction))
+	}
+
+	return strings.Join(elements, ", "), nil
+}
+
+// compileFilter compiles the permission predicate of the collection and the request predicate into WHERE conditions
+func (qc *queryCompiler) compileFilter(collection string, tableAlias string, predicate schema.Expression) ([]string, []any, error) {
+	var conditions []string
+	permission, arguments, err := qc.compilePermission(collection, tableAlias)
+	if err != nil {
+		return nil, nil, err
+	}
+	if permission != "" {
+		conditions = append(conditions, permission)
+	}
+	if len(predicate) > 0 {
+		condition, args, err := qc.compileExpression(&expressionScope{
+			collection:     collection,
+			tableAlias:     tableAlias,
+			rootCollection: collection,
+			rootAlias:      tableAlias,
+			variables:      qc.variables,
+		}, predicate)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, condition)
+		arguments = append(arguments, args...)
+	}
+	return conditions, arguments, nil
+}
+
+// compilePermission compiles the row-level security predicate of the collection, if any
+func (qc *queryCompiler) compilePermission(collection string, tableAlias string) (string, []any, error) {
+	permission, ok := qc.permissions[collection]
+	if !ok || len(permission.Predicate) == 0 || qc.activePermissions[collection] {
+		return "", nil, nil
+	}
+	qc.activePermissions[collection] = true
+	defer delete(qc.activePermissions, collection)
+
+	condition, arguments, err := qc.compileExpression(&expressionScope{
+		collection:     collection,
+		tableAlias:     tableAlias,
+		rootCollection: collection,
+		rootAlias:      tableAlias,
+		variables:      qc.sessionArguments,
+		permission:     collection,
+	}, permission.Predicate)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + condition + ")", arguments, nil
+}
+
+func (qc *queryCompiler) compileExpression(scope *expressionScope, expression schema.Expression) (string, []any, error) {
+	exprValue, err := expression.InterfaceT()
+	if err != nil {
+		return "", nil, schema.UnprocessableContentError(err.Error(), nil)
+	}
+	switch expr := exprValue.(type) {
+	case *schema.ExpressionAnd:
+		return qc.compileLogicalExpression(scope, expr.Expressions, "AND", "1 = 1")
+	case *schema.ExpressionOr:
+		return qc.compileLogicalExpression(scope, expr.Expressions, "OR", "1 = 0")
+	case *schema.ExpressionNot:
+		condition, arguments, err := qc.compileExpression(scope, expr.Expression)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", condition), arguments, nil
+	case *schema.ExpressionUnaryComparisonOperator:
+		column, err := compileComparisonTarget(scope, expr.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		if expr.Operator != schema.UnaryComparisonOperatorIsNull {
+			return "", nil, schema.UnprocessableContentError(fmt.Sprintf("invalid unary comparison operator: %s", expr.Operator), nil)
+		}
+		return fmt.Sprintf("%s IS NULL", column), nil, nil
+	case *schema.ExpressionBinaryComparisonOperator:
+		return qc.compileBinaryComparison(scope, expr)
+	case *schema.ExpressionExists:
+		return qc.compileExists(scope, expr)
+	default:
+		return "", nil, schema.UnprocessableContentError("invalid expression", map[string]any{
+			"value": expression,
+		})
+	}
+}
+
+func (qc *queryCompiler) compileLogicalExpression(scope *expressionScope, expressions []schema.Expression, operator string, emptyCondition string) (string, []any, error) {
+	if len(expressions) == 0 {
+		return emptyCondition, nil, nil
+	}
+	clauses := make([]string, 0, len(expressions))
+	var arguments []any
+	for _, expression := range expressions {
+		clause, args, err := qc.compileExpression(scope, expression)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, clause)
+		arguments = append(arguments, args...)
+	}
+	return "(" + strings.Join(clauses, " "+operator+" ") + ")", arguments, nil
+}
+
+// compileExists compiles an exists expression into a correlated subquery.
+// The permission predicate of the target collection is applied to the subquery too
+func (qc *queryCompiler) compileExists(scope *expressionScope, expr *schema.ExpressionExists) (string, []any, error) {
+	inCollection, err := expr.InCollection.InterfaceT()
+	if err != nil {
+		return "", nil, schema.UnprocessableContentError(err.Error(), nil)
+	}
+
+	tableAlias := qc.nextTableAlias()
+	var collection string
+	var conditions []string
+	switch inCol := inCollection.(type) {
+	case *schema.ExistsInCollectionRelated:
+		relationship, ok := qc.relationships[inCol.Relationship]
+		if !ok {
+			return "", nil, schema.UnprocessableContentError(fmt.Sprintf("invalid in collection relationship: %s", inCol.Relationship), nil)
+		}
+		if len(inCol.Arguments) > 0 || len(relationship.Arguments) > 0 {
+			return "", nil, schema.NotSupportedError("relationship arguments are not supported", nil)
+		}
+		collection = relationship.TargetCollection
+		for _, sourceColumn := range sortedKeys(relationship.ColumnMapping) {
+			conditions = append(conditions, fmt.Sprintf("%s = %s", qualifyColumn(tableAlias, relationship.ColumnMapping[sourceColumn]), qualifyColumn(scope.tableAlias, sourceColumn)))
+		}
+	case *schema.ExistsInCollectionUnrelated:
+		if len(inCol.Arguments) > 0 {
+			return "", nil, schema.NotSupportedError("collection arguments are not supported", nil)
+		}
+		collection = inCol.Collection
+	default:
+		return "", nil, schema.UnprocessableContentError("invalid in collection field", map[string]any{
+			"value": expr.InCollection,
+		})
+	}
+
+	permission, arguments, err := qc.compilePermission(collection, tableAlias)
+	if err != nil {
+		return "", nil, err
+	}
+	if permission != "" {
+		conditions = append(conditions, permission)
+	}
+	if len(expr.Predicate) > 0 {
+		condition, args, err := qc.compileExpression(&expressionScope{
+			collection:     collection,
+			tableAlias:     tableAlias,
+			rootCollection: scope.rootCollection,
+			rootAlias:      scope.rootAlias,
+			variables:      scope.variables,
+			permission:     scope.permission,
+		}, expr.Predicate)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		arguments = append(arguments, args...)
+	}
+
+	subQuery := fmt.Sprintf("SELECT 1 FROM %s AS %s", quoteIdentifier(collection), quoteIdentifier(tableAlias))
+	if len(conditions) > 0 {
+		subQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return fmt.Sprintf("EXISTS (%s)", subQuery), arguments, nil
+}
+
+func (qc *queryCompiler) compileBinaryComparison(scope *expressionScope, expr *schema.ExpressionBinaryComparisonOperator) (string, []any, error) {
+	column, err := compileComparisonTarget(scope, expr.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	operator, ok := comparisonOperators[expr.Operator]
+	switch {
+	case ok:
+	case expr.Operator == inOperator:
+	case expr.Operator == matchOperator:
+		if err := qc.validateFullTextColumn(scope, expr.Column); err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, schema.UnprocessableContentError(fmt.Sprintf("invalid comparison operator: %s", expr.Operator), nil)
+	}
+
+	compValue, err := expr.Value.InterfaceT()
+	if err != nil {
+		return "", nil, schema.UnprocessableContentError(err.Error(), nil)
+	}
+	var value any
+	switch v := compValue.(type) {
+	case *schema.ComparisonValueColumn:
+		if operator == "" {
+			return "", nil, schema.NotSupportedError(fmt.Sprintf("column comparison values are not supported by operator %s", expr.Operator), nil)
+		}
+		target, err := compileComparisonTarget(scope, v.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf(operator, column, target), nil, nil
+	case *schema.ComparisonValueScalar:
+		value = v.Value
+	case *schema.ComparisonValueVariable:
+		variable, ok := scope.variables[v.Name]
+		if !ok && scope.permission != "" {
+			return "", nil, schema.ForbiddenError(fmt.Sprintf("session argument %s is required by the permission of collection %s", v.Name, scope.permission), nil)
+		}
+		if !ok {
+			return "", nil, schema.UnprocessableContentError(fmt.Sprintf("variable %s not found", v.Name), nil)
+		}
+		value = variable
+	default:
+		return "", nil, schema.UnprocessableContentError("invalid comparison value", map[string]any{
+			"value": expr.Value,
+		})
+	}
+
+	switch expr.Operator {
+	case inOperator:
+	case matchOperator:
+		return fmt.Sprintf("MATCH(%s) AGAINST(? IN NATURAL LANGUAGE MODE)", column), []any{value}, nil
+	default:
+		return fmt.Sprintf(operator, column, "?"), []any{value}, nil
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		return "", nil, schema.UnprocessableContentError(fmt.Sprintf("expected an array value for operator _in, got %v", value), nil)
+	}
+	if len(values) == 0 {
+		return "1 = 0", nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return fmt.Sprintf("%s IN (%s)", column, placeholders), values, nil
+}
+
+// validateFullTextColumn checks that the target column has a FULLTEXT index, which MATCH ... AGAINST requires
+func (qc *queryCompiler) validateFullTextColumn(scope *expressionScope, target schema.ComparisonTarget) error {
+	collection := scope.collection
+	if target.Type == schema.ComparisonTargetTypeRootCollectionColumn {
+		collection = scope.rootCollection
+	}
+	if !qc.fullTextIndexes.HasColumn(collection, target.Name) {
+		return schema.UnprocessableContentError(fmt.Sprintf("operator _match requires a FULLTEXT index on column %s of collection %s", target.Name, collection), nil)
+	}
+	return nil
+}
+
+func compileComparisonTarget(scope *expressionScope, target schema.ComparisonTarget) (string, error) {
+	if len(target.Path) > 0 {
+		return "", schema.NotSupportedError(fmt.Sprintf("comparison target with relationship path is not supported: %s", target.Name), nil)
+	}
+	switch target.Type {
+	case schema.ComparisonTargetTypeColumn:
+		return qualifyColumn(scope.tableAlias, target.Name), nil
+	case schema.ComparisonTargetTypeRootCollectionColumn:
+		return qualifyColumn(scope.rootAlias, target.Name), nil
+	default:
+		return "", schema.UnprocessableContentError(fmt.Sprintf("invalid comparison target type: %s", target.Type), nil)
+	}
+}
+
+// evalQueryArguments evaluates the collection arguments of a query request with a variable set.
+// Null arguments are omitted, so permissions that require them are forbidden
+func evalQueryArguments(arguments schema.QueryRequestArguments, variables map[string]any) (map[string]any, error) {
+	results := make(map[string]any, len(arguments))
+	for key, argument := range arguments {
+		var value any
+		switch argument.Type {
+		case schema.ArgumentTypeLiteral:
+			value = argument.Value
+		case schema.ArgumentTypeVariable:
+			variable, ok := variables[argument.Name]
+			if !ok {
+				return nil, schema.UnprocessableContentError(fmt.Sprintf("variable %s not found", argument.Name), nil)
+			}
+			value = variable
+		default:
+			return nil, schema.UnprocessableContentError(fmt.Sprintf("invalid argument type: %s", argument.Type), nil)
+		}
+		if value != nil {
+			results[key] = value
+		}
+	}
+	return results, nil
+}
+
+// executeQuery runs a compiled query and rebuilds the rows keyed by the NDC field aliases
+func executeQuery(ctx context.Context, fetch rowFetcher, query *compiledQuery) (*schema.RowSet, error) {
+	if len(query.Fields) == 0 {
+		return &schema.RowSet{}, nil
+	}
+
+	rawRows, err := fetch(ctx, query.SQL, query.Arguments...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := evalRows(ctx, fetch, query.Fields, rawRows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.RowSet{
+		Rows: rows,
+	}, nil
+}
+
+// evalRows rebuilds rows keyed by the generated SQL aliases into rows keyed by the NDC field aliases.
+// Every relationship field is fetched for all rows at once
+func evalRows(ctx context.Context, fetch rowFetcher, fields []fieldProjection, rawRows []map[string]any) ([]map[string]any, error) {
+	rows := make([]map[string]any, len(rawRows))
+	for i := range rawRows {
+		rows[i] = make(map[string]any, len(fields))
+	}
+	for _, field := range fields {
+		if field.Relationship == nil {
+			for i, rawRow := range rawRows {
+				value, ok := rawRow[field.SQLAlias]
+				if !ok {
+					return nil, schema.InternalServerError(fmt.Sprintf("column %s of field %s is missing in the result", field.SQLAlias, field.Alias), nil)
+				}
+				rows[i][field.Alias] = value
+			}
+			continue
+		}
+
+		rowSets, err := evalRelationship(ctx, fetch, field.Relationship, rawRows)
+		if err != nil {
+			return nil, err
+		}
+		for i, rowSet := range rowSets {
+			rows[i][field.Alias] = rowSet
+		}
+	}
+
+	return rows, nil
+}
+
+// evalRelationship fetches the rows of a relationship field of all parent rows, in batches of relationshipBatchSize
+// distinct join values, and returns the row set of every parent row
+func evalRelationship(ctx context.Context, fetch rowFetcher, relationship *compiledRelationship, parentRows []map[string]any) ([]*schema.RowSet, error) {
+	query := relationship.Query
+	results := make([]*schema.RowSet, len(parentRows))
+	if len(query.Fields) == 0 || len(parentRows) == 0 {
+		for i := range results {
+			results[i] = &schema.RowSet{}
+		}
+		return results, nil
+	}
+
+	// a relationship without column mapping returns the same rows for every parent row
+	if query.Join == nil {
+		rowSet, err := executeQuery(ctx, fetch, query)
+		if err != nil {
+			return nil, err
+		}
+		for i := range results {
+			results[i] = rowSet
+		}
+		return results, nil
+	}
+
+	// the index of the distinct join values of every parent row, -1 if a join value is null and the row matches nothing.
+	// The rows of the subquery are matched by the database, so values that are equal in the collation of the target
+	// columns but not byte-equal only fetch the same rows twice
+	parentKeys := make([]int, len(parentRows))
+	var keyValues [][]any
+	keyIndexes := map[string]int{}
+	for i, parentRow := range parentRows {
+		parentKeys[i] = -1
+		values := make([]any, len(relationship.SourceAliases))
+		for j, sourceAlias := range relationship.SourceAliases {
+			values[j] = parentRow[sourceAlias]
+		}
+		if slices.Contains(values, nil) {
+			continue
+		}
+		key := joinKey(values)
+		index, ok := keyIndexes[key]
+		if !ok {
+			index = len(keyValues)
+			keyIndexes[key] = index
+			keyValues = append(keyValues, values)
+		}
+		parentKeys[i] = index
+	}
+
+	var rawRows []map[string]any
+	// the index of the parent key of every raw row
+	var rowKeys []int
+	for start := 0; start < len(keyValues); start += relationshipBatchSize {
+		batch := keyValues[start:min(start+relationshipBatchSize, len(keyValues))]
+		arguments := make([]any, 0, len(batch)*len(relationship.SourceAliases)+len(query.Arguments))
+		for _, values := range batch {
+			arguments = append(arguments, values...)
+		}
+		arguments = append(arguments, query.Arguments...)
+		batchRows, err := fetch(ctx, query.Join.statement(len(batch)), arguments...)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range batchRows {
+			index, err := strconv.Atoi(fmt.Sprint(row[joinIndexAlias]))
+			if err != nil || index < 0 || index >= len(batch) {
+				return nil, schema.InternalServerError("invalid parent key index of a relationship row", map[string]any{
+					"value": row[joinIndexAlias],
+				})
+			}
+			rowKeys = append(rowKeys, start+index)
+		}
+		rawRows = append(rawRows, batchRows...)
+	}
+
+	rows, err := evalRows(ctx, fetch, query.Fields, rawRows)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([][]map[string]any, len(keyValues))
+	for i, key := range rowKeys {
+		groups[key] = append(groups[key], rows[i])
+	}
+
+	for i, key := range parentKeys {
+		group := []map[string]any{}
+		if key >= 0 && groups[key] != nil {
+			group = groups[key]
+		}
+		results[i] = &schema.RowSet{
+			Rows: group,
+		}
+	}
+	return results, nil
+}
+
+// joinKey encodes the join values of a parent row, so parent rows with the same values share their subquery rows.
+// The key is never empty
+func joinKey(values []any) string {
+	var sb strings.Builder
+	for _, value := range values {
+		fmt.Fprintf(&sb, "%v\x00", value)
+	}
+	return sb.String()
+}
+
+// newRowFetcher creates a rowFetcher that runs statements against the database
+func newRowFetcher(db sqlQueryer) rowFetcher {
+	return func(ctx context.Context, query string, arguments ...any) ([]map[string]any, error) {
+		rows, err := db.QueryContext(ctx, query, arguments...)
+		if err != nil {
+			return nil, schema.InternalServerError("database query failed", map[string]any{
+				"cause": err.Error(),
+			})
+		}
+		defer rows.Close()
+
+		cols, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+
+		results := []map[string]any{}
+		for rows.Next() {
+			columns := make([]any, len(cols))
+			columnPointers := make([]any, len(cols))
+			for i := range columns {
+				columnPointers[i] = &columns[i]
+			}
+
+			if err := rows.Scan(columnPointers...); err != nil {
+				return nil, err
+			}
+
+			rowMap := make(map[string]any)
+			for i, colName := range cols {
+				val := columnPointers[i].(*any)
+				switch v := (*val).(type) {
+				case []byte:
+					rowMap[colName] = string(v)
+				default:
+					rowMap[colName] = *val
+				}
+			}
+
+			results = append(results, rowMap)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func qualifyColumn(tableAlias string, column string) string {
+	return quoteIdentifier(tableAlias) + "." + quoteIdentifier(column)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
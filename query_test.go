@@ -0,0 +1,764 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/internal"
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+func decodeQueryRequest(t *testing.T, rawJSON string) *schema.QueryRequest {
+	var request schema.QueryRequest
+	if err := json.Unmarshal([]byte(rawJSON), &request); err != nil {
+		t.Errorf("failed to decode query request: %s", err)
+		t.FailNow()
+	}
+	return &request
+}
+
+// mockRowFetcher returns rows by the SQL statement and its arguments
+func mockRowFetcher(t *testing.T, results map[string][]map[string]any) rowFetcher {
+	return func(ctx context.Context, query string, arguments ...any) ([]map[string]any, error) {
+		key := fmt.Sprintf("%s %v", query, arguments)
+		rows, ok := results[key]
+		if !ok {
+			t.Errorf("unexpected statement: %s", key)
+			t.FailNow()
+		}
+		return rows, nil
+	}
+}
+
+var testFullTextIndexes = fullTextIndexes{
+	"Track": {{"Name"}, {"Name", "Composer"}},
+}
+
+func TestCompileQuery(t *testing.T) {
+	testCases := []struct {
+		name              string
+		request           string
+		expectedSQL       string
+		expectedArguments []any
+	}{
+		{
+			name: "field_aliases",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"id": { "type": "column", "column": "ArtistId" },
+						"name": { "type": "column", "column": "Name" }
+					}
+				}
+			}`,
+			expectedSQL: "SELECT `t0`.`ArtistId` AS `f0`, `t0`.`Name` AS `f1` FROM `Artist` AS `t0`",
+		},
+		{
+			name: "duplicate_columns",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"name": { "type": "column", "column": "Name" },
+						"title": { "type": "column", "column": "Name" }
+					},
+					"limit": 10,
+					"offset": 5
+				}
+			}`,
+			expectedSQL: "SELECT `t0`.`Name` AS `f0`, `t0`.`Name` AS `f1` FROM `Artist` AS `t0` LIMIT 10 OFFSET 5",
+		},
+		{
+			name: "predicate_order_by",
+			request: `{
+				"collection": "Track",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"name": { "type": "column", "column": "Name" }
+					},
+					"predicate": {
+						"type": "and",
+						"expressions": [
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "AlbumId" },
+								"operator": "_in",
+								"value": { "type": "scalar", "value": [1, 2] }
+							},
+							{
+								"type": "not",
+								"expression": {
+									"type": "unary_comparison_operator",
+									"column": { "type": "column", "name": "Composer" },
+									"operator": "is_null"
+								}
+							},
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "Name" },
+								"operator": "_like",
+								"value": { "type": "variable", "name": "search" }
+							}
+						]
+					},
+					"order_by": {
+						"elements": [
+							{
+								"order_direction": "desc",
+								"target": { "type": "column", "name": "Milliseconds", "path": [] }
+							}
+						]
+					},
+					"offset": 2
+				},
+				"variables": [{ "search": "%Love%" }]
+			}`,
+			expectedSQL:       "SELECT `t0`.`Name` AS `f0` FROM `Track` AS `t0` WHERE (`t0`.`AlbumId` IN (?, ?) AND NOT (`t0`.`Composer` IS NULL) AND `t0`.`Name` LIKE ?) ORDER BY `t0`.`Milliseconds` DESC LIMIT 18446744073709551615 OFFSET 2",
+			expectedArguments: []any{float64(1), float64(2), "%Love%"},
+		},
+		{
+			name: "string_operators",
+			request: `{
+				"collection": "Track",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"name": { "type": "column", "column": "Name" }
+					},
+					"predicate": {
+						"type": "or",
+						"expressions": [
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "Name" },
+								"operator": "_ilike",
+								"value": { "type": "scalar", "value": "%love%" }
+							},
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "Composer" },
+								"operator": "_nlike",
+								"value": { "type": "scalar", "value": "AC/%" }
+							},
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "Name" },
+								"operator": "_regex",
+								"value": { "type": "scalar", "value": "^Love" }
+							},
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "Name" },
+								"operator": "_iregex",
+								"value": { "type": "column", "column": { "type": "column", "name": "Composer" } }
+							},
+							{
+								"type": "binary_comparison_operator",
+								"column": { "type": "column", "name": "Name" },
+								"operator": "_match",
+								"value": { "type": "variable", "name": "search" }
+							}
+						]
+					}
+				},
+				"variables": [{ "search": "rock and roll" }]
+			}`,
+			expectedSQL:       "SELECT `t0`.`Name` AS `f0` FROM `Track` AS `t0` WHERE (LOWER(`t0`.`Name`) LIKE LOWER(?) OR `t0`.`Composer` NOT LIKE ? OR REGEXP_LIKE(`t0`.`Name`, ?, 'c') OR REGEXP_LIKE(`t0`.`Name`, `t0`.`Composer`, 'i') OR MATCH(`t0`.`Name`) AGAINST(? IN NATURAL LANGUAGE MODE))",
+			expectedArguments: []any{"%love%", "AC/%", "^Love", "rock and roll"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := decodeQueryRequest(t, tc.request)
+			var variables map[string]any
+			if len(request.Variables) > 0 {
+				variables = request.Variables[0]
+			}
+			query, err := newQueryCompiler(request.CollectionRelationships, variables).
+				WithFullTextIndexes(testFullTextIndexes).
+				Compile(request.Collection, &request.Query)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if query.SQL != tc.expectedSQL {
+				t.Errorf("\nexpected: %s\ngot: %s", tc.expectedSQL, query.SQL)
+			}
+			if !reflect.DeepEqual(query.Arguments, tc.expectedArguments) {
+				t.Errorf("expected arguments %v, got %v", tc.expectedArguments, query.Arguments)
+			}
+		})
+	}
+}
+
+func TestExecuteQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		request  string
+		results  map[string][]map[string]any
+		expected *schema.RowSet
+	}{
+		{
+			name: "duplicate_columns",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"id": { "type": "column", "column": "ArtistId" },
+						"name": { "type": "column", "column": "Name" },
+						"title": { "type": "column", "column": "Name" }
+					}
+				}
+			}`,
+			results: map[string][]map[string]any{
+				"SELECT `t0`.`ArtistId` AS `f0`, `t0`.`Name` AS `f1`, `t0`.`Name` AS `f2` FROM `Artist` AS `t0` []": {
+					{"f0": int64(1), "f1": "AC/DC", "f2": "AC/DC"},
+					{"f0": int64(2), "f1": "Accept", "f2": "Accept"},
+				},
+			},
+			expected: &schema.RowSet{
+				Rows: []map[string]any{
+					{"id": int64(1), "name": "AC/DC", "title": "AC/DC"},
+					{"id": int64(2), "name": "Accept", "title": "Accept"},
+				},
+			},
+		},
+		{
+			name: "nested_relationships",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {
+					"artist_albums": {
+						"column_mapping": { "ArtistId": "ArtistId" },
+						"relationship_type": "array",
+						"target_collection": "Album",
+						"arguments": {}
+					},
+					"album_tracks": {
+						"column_mapping": { "AlbumId": "AlbumId" },
+						"relationship_type": "array",
+						"target_collection": "Track",
+						"arguments": {}
+					}
+				},
+				"query": {
+					"fields": {
+						"name": { "type": "column", "column": "Name" },
+						"albums": {
+							"type": "relationship",
+							"relationship": "artist_albums",
+							"arguments": {},
+							"query": {
+								"fields": {
+									"title": { "type": "column", "column": "Title" },
+									"tracks": {
+										"type": "relationship",
+										"relationship": "album_tracks",
+										"arguments": {},
+										"query": {
+											"fields": {
+												"track_name": { "type": "column", "column": "Name" },
+												"name": { "type": "column", "column": "Name" }
+											},
+											"limit": 1
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}`,
+			results: map[string][]map[string]any{
+				"SELECT `t0`.`ArtistId` AS `j0`, `t0`.`Name` AS `f1` FROM `Artist` AS `t0` []": {
+					{"j0": int64(1), "f1": "AC/DC"},
+					{"j0": int64(2), "f1": "Accept"},
+					{"j0": nil, "f1": "Unknown"},
+					{"j0": int64(1), "f1": "AC/DC"},
+				},
+				"SELECT `t1`.`Title` AS `f0`, `t1`.`AlbumId` AS `j0`, `t1_keys`.`k` AS `k` FROM (SELECT 0 AS `k`, ? AS `c0` UNION ALL SELECT 1, ?) AS `t1_keys` INNER JOIN `Album` AS `t1` ON `t1`.`ArtistId` = `t1_keys`.`c0` [1 2]": {
+					{"f0": "For Those About To Rock We Salute You", "j0": int64(1), "k": int64(0)},
+					{"f0": "Let There Be Rock", "j0": int64(4), "k": int64(0)},
+				},
+				"SELECT * FROM (SELECT `t2`.`Name` AS `f0`, `t2`.`Name` AS `f1`, `t2_keys`.`k` AS `k`, ROW_NUMBER() OVER (PARTITION BY `t2_keys`.`k`) AS `rn` FROM (SELECT 0 AS `k`, ? AS `c0` UNION ALL SELECT 1, ?) AS `t2_keys` INNER JOIN `Track` AS `t2` ON `t2`.`AlbumId` = `t2_keys`.`c0`) AS `t2_rows` WHERE `rn` <= 1 ORDER BY `rn` [1 4]": {
+					{"f0": "For Those About To Rock (We Salute You)", "f1": "For Those About To Rock (We Salute You)", "k": int64(0)},
+				},
+			},
+			expected: &schema.RowSet{
+				Rows: []map[string]any{
+					{
+						"name": "AC/DC",
+						"albums": &schema.RowSet{
+							Rows: []map[string]any{
+								{
+									"title": "For Those About To Rock We Salute You",
+									"tracks": &schema.RowSet{
+										Rows: []map[string]any{
+											{
+												"name":       "For Those About To Rock (We Salute You)",
+												"track_name": "For Those About To Rock (We Salute You)",
+											},
+										},
+									},
+								},
+								{
+									"title": "Let There Be Rock",
+									"tracks": &schema.RowSet{
+										Rows: []map[string]any{},
+									},
+								},
+							},
+						},
+					},
+					{
+						"name": "Accept",
+						"albums": &schema.RowSet{
+							Rows: []map[string]any{},
+						},
+					},
+					{
+						"name": "Unknown",
+						"albums": &schema.RowSet{
+							Rows: []map[string]any{},
+						},
+					},
+					{
+						"name": "AC/DC",
+						"albums": &schema.RowSet{
+							Rows: []map[string]any{
+								{
+									"title": "For Those About To Rock We Salute You",
+									"tracks": &schema.RowSet{
+										Rows: []map[string]any{
+											{
+												"name":       "For Those About To Rock (We Salute You)",
+												"track_name": "For Those About To Rock (We Salute You)",
+											},
+										},
+									},
+								},
+								{
+									"title": "Let There Be Rock",
+									"tracks": &schema.RowSet{
+										Rows: []map[string]any{},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "collation_equal_join_values",
+			request: `{
+				"collection": "Employee",
+				"arguments": {},
+				"collection_relationships": {
+					"city_customers": {
+						"column_mapping": { "City": "City" },
+						"relationship_type": "array",
+						"target_collection": "Customer",
+						"arguments": {}
+					}
+				},
+				"query": {
+					"fields": {
+						"customers": {
+							"type": "relationship",
+							"relationship": "city_customers",
+							"arguments": {},
+							"query": {
+								"fields": {
+									"id": { "type": "column", "column": "CustomerId" }
+								}
+							}
+						}
+					}
+				}
+			}`,
+			results: map[string][]map[string]any{
+				"SELECT `t0`.`City` AS `j0` FROM `Employee` AS `t0` []": {
+					{"j0": "Calgary"},
+					{"j0": "CALGARY "},
+				},
+				// the case-insensitive and pad-insensitive collation of the column matches both parent keys
+				"SELECT `t1`.`CustomerId` AS `f0`, `t1_keys`.`k` AS `k` FROM (SELECT 0 AS `k`, ? AS `c0` UNION ALL SELECT 1, ?) AS `t1_keys` INNER JOIN `Customer` AS `t1` ON `t1`.`City` = `t1_keys`.`c0` [Calgary CALGARY ]": {
+					{"f0": int64(1), "k": int64(0)},
+					{"f0": int64(1), "k": "1"},
+				},
+			},
+			expected: &schema.RowSet{
+				Rows: []map[string]any{
+					{"customers": &schema.RowSet{Rows: []map[string]any{{"id": int64(1)}}}},
+					{"customers": &schema.RowSet{Rows: []map[string]any{{"id": int64(1)}}}},
+				},
+			},
+		},
+		{
+			name: "no_fields",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {}
+			}`,
+			results:  map[string][]map[string]any{},
+			expected: &schema.RowSet{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := decodeQueryRequest(t, tc.request)
+			query, err := newQueryCompiler(request.CollectionRelationships, nil).Compile(request.Collection, &request.Query)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			rowSet, err := executeQuery(context.Background(), mockRowFetcher(t, tc.results), query)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if !internal.DeepEqual(rowSet, tc.expected) {
+				expectedBytes, _ := json.Marshal(tc.expected)
+				resultBytes, _ := json.Marshal(rowSet)
+				t.Errorf("\nexpect: %s\ngot: %s", string(expectedBytes), string(resultBytes))
+			}
+		})
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			name: "invalid_relationship",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"albums": { "type": "relationship", "relationship": "artist_albums", "arguments": {}, "query": {} }
+					}
+				}
+			}`,
+			expected: "invalid relationship name artist_albums",
+		},
+		{
+			name: "invalid_operator",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"name": { "type": "column", "column": "Name" }
+					},
+					"predicate": {
+						"type": "binary_comparison_operator",
+						"column": { "type": "column", "name": "Name" },
+						"operator": "_unknown",
+						"value": { "type": "scalar", "value": "AC/DC" }
+					}
+				}
+			}`,
+			expected: "invalid comparison operator: _unknown",
+		},
+		{
+			name: "match_without_fulltext_index",
+			request: `{
+				"collection": "Artist",
+				"arguments": {},
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"name": { "type": "column", "column": "Name" }
+					},
+					"predicate": {
+						"type": "binary_comparison_operator",
+						"column": { "type": "column", "name": "Name" },
+						"operator": "_match",
+						"value": { "type": "scalar", "value": "AC/DC" }
+					}
+				}
+			}`,
+			expected: "operator _match requires a FULLTEXT index on column Name of collection Artist",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := decodeQueryRequest(t, tc.request)
+			_, err := newQueryCompiler(request.CollectionRelationships, nil).
+				WithFullTextIndexes(testFullTextIndexes).
+				Compile(request.Collection, &request.Query)
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("expected error %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+var testPermissions = map[string]Permission{}
+
+func init() {
+	if err := json.Unmarshal([]byte(`{
+		"Customer": {
+			"predicate": {
+				"type": "binary_comparison_operator",
+				"column": { "type": "column", "name": "Country" },
+				"operator": "_in",
+				"value": { "type": "variable", "name": "countries" }
+			}
+		},
+		"Invoice": {
+			"predicate": {
+				"type": "exists",
+				"in_collection": { "type": "unrelated", "collection": "Customer", "arguments": {} },
+				"predicate": {
+					"type": "binary_comparison_operator",
+					"column": { "type": "column", "name": "CustomerId" },
+					"operator": "_eq",
+					"value": { "type": "column", "column": { "type": "root_collection_column", "name": "CustomerId" } }
+				}
+			}
+		}
+	}`), &testPermissions); err != nil {
+		panic(err)
+	}
+}
+
+func TestCompileQueryPermissions(t *testing.T) {
+	testCases := []struct {
+		name              string
+		request           string
+		expectedSQL       []string
+		expectedArguments []any
+	}{
+		{
+			name: "root_collection",
+			request: `{
+				"collection": "Customer",
+				"arguments": { "countries": { "type": "literal", "value": ["Brazil", "Canada"] } },
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"id": { "type": "column", "column": "CustomerId" }
+					},
+					"predicate": {
+						"type": "binary_comparison_operator",
+						"column": { "type": "column", "name": "City" },
+						"operator": "_eq",
+						"value": { "type": "scalar", "value": "Toronto" }
+					}
+				}
+			}`,
+			expectedSQL: []string{
+				"SELECT `t0`.`CustomerId` AS `f0` FROM `Customer` AS `t0` WHERE (`t0`.`Country` IN (?, ?)) AND `t0`.`City` = ?",
+			},
+			expectedArguments: []any{"Brazil", "Canada", "Toronto"},
+		},
+		{
+			name: "relationship_and_exists",
+			request: `{
+				"collection": "Employee",
+				"arguments": { "countries": { "type": "literal", "value": ["Brazil"] } },
+				"collection_relationships": {
+					"customers": {
+						"column_mapping": { "EmployeeId": "SupportRepId" },
+						"relationship_type": "array",
+						"target_collection": "Customer",
+						"arguments": {}
+					}
+				},
+				"query": {
+					"fields": {
+						"customers": {
+							"type": "relationship",
+							"relationship": "customers",
+							"arguments": {},
+							"query": {
+								"fields": {
+									"id": { "type": "column", "column": "CustomerId" }
+								}
+							}
+						}
+					},
+					"predicate": {
+						"type": "exists",
+						"in_collection": { "type": "related", "relationship": "customers", "arguments": {} }
+					}
+				}
+			}`,
+			expectedSQL: []string{
+				"SELECT `t0`.`EmployeeId` AS `j0` FROM `Employee` AS `t0` WHERE EXISTS (SELECT 1 FROM `Customer` AS `t2` WHERE `t2`.`SupportRepId` = `t0`.`EmployeeId` AND (`t2`.`Country` IN (?)))",
+				"SELECT `t1`.`CustomerId` AS `f0`, `t1_keys`.`k` AS `k` FROM (SELECT 0 AS `k`, ? AS `c0`) AS `t1_keys` INNER JOIN `Customer` AS `t1` ON `t1`.`SupportRepId` = `t1_keys`.`c0` WHERE (`t1`.`Country` IN (?))",
+			},
+			expectedArguments: []any{"Brazil"},
+		},
+		{
+			name: "nested_permission",
+			request: `{
+				"collection": "Invoice",
+				"arguments": { "countries": { "type": "variable", "name": "countries" } },
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"id": { "type": "column", "column": "InvoiceId" }
+					}
+				},
+				"variables": [{ "countries": ["Canada"] }]
+			}`,
+			expectedSQL: []string{
+				"SELECT `t0`.`InvoiceId` AS `f0` FROM `Invoice` AS `t0` WHERE (EXISTS (SELECT 1 FROM `Customer` AS `t1` WHERE (`t1`.`Country` IN (?)) AND `t1`.`CustomerId` = `t0`.`CustomerId`))",
+			},
+			expectedArguments: []any{"Canada"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := decodeQueryRequest(t, tc.request)
+			var variables map[string]any
+			if len(request.Variables) > 0 {
+				variables = request.Variables[0]
+			}
+			sessionArguments, err := evalQueryArguments(request.Arguments, variables)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			query, err := newQueryCompiler(request.CollectionRelationships, variables).
+				WithPermissions(testPermissions, sessionArguments).
+				Compile(request.Collection, &request.Query)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+				t.FailNow()
+			}
+			if query.SQL != tc.expectedSQL[0] {
+				t.Errorf("\nexpected: %s\ngot: %s", tc.expectedSQL[0], query.SQL)
+			}
+			if !reflect.DeepEqual(query.Arguments, tc.expectedArguments) {
+				t.Errorf("expected arguments %v, got %v", tc.expectedArguments, query.Arguments)
+			}
+			for i, expectedSQL := range tc.expectedSQL[1:] {
+				if len(query.Fields) <= i || query.Fields[i].Relationship == nil {
+					t.Errorf("expected relationship field at %d", i)
+					t.FailNow()
+				}
+				if subQuery := query.Fields[i].Relationship.Query.SQL; subQuery != expectedSQL {
+					t.Errorf("\nexpected: %s\ngot: %s", expectedSQL, subQuery)
+				}
+			}
+		})
+	}
+
+	t.Run("missing_session_argument", func(t *testing.T) {
+		for _, arguments := range []string{`{}`, `{ "countries": { "type": "literal", "value": null } }`} {
+			request := decodeQueryRequest(t, `{
+				"collection": "Customer",
+				"arguments": `+arguments+`,
+				"collection_relationships": {},
+				"query": {
+					"fields": {
+						"id": { "type": "column", "column": "CustomerId" }
+					}
+				}
+			}`)
+			sessionArguments, err := evalQueryArguments(request.Arguments, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = newQueryCompiler(request.CollectionRelationships, nil).
+				WithPermissions(testPermissions, sessionArguments).
+				Compile(request.Collection, &request.Query)
+			var connectorError *schema.ConnectorError
+			if !errors.As(err, &connectorError) || connectorError.StatusCode() != http.StatusForbidden {
+				t.Errorf("%s: expected forbidden error, got %v", arguments, err)
+			}
+		}
+	})
+}
+
+func TestCompileRelationshipBatch(t *testing.T) {
+	request := decodeQueryRequest(t, `{
+		"collection": "Invoice",
+		"arguments": {},
+		"collection_relationships": {
+			"lines": {
+				"column_mapping": { "InvoiceId": "InvoiceId", "CustomerId": "CustomerId" },
+				"relationship_type": "array",
+				"target_collection": "InvoiceLine",
+				"arguments": {}
+			}
+		},
+		"query": {
+			"fields": {
+				"lines": {
+					"type": "relationship",
+					"relationship": "lines",
+					"arguments": {},
+					"query": {
+						"fields": {
+							"price": { "type": "column", "column": "UnitPrice" }
+						},
+						"predicate": {
+							"type": "binary_comparison_operator",
+							"column": { "type": "column", "name": "Quantity" },
+							"operator": "_lte",
+							"value": { "type": "scalar", "value": 10 }
+						},
+						"order_by": {
+							"elements": [
+								{ "order_direction": "desc", "target": { "type": "column", "name": "UnitPrice", "path": [] } }
+							]
+						},
+						"limit": 2,
+						"offset": 1
+					}
+				}
+			}
+		}
+	}`)
+	query, err := newQueryCompiler(request.CollectionRelationships, nil).Compile(request.Collection, &request.Query)
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+		t.FailNow()
+	}
+
+	join := query.Fields[0].Relationship.Query.Join
+	if join == nil {
+		t.Fatal("expected the join of the relationship subquery")
+	}
+	expectedSQL := "SELECT * FROM (SELECT `t1`.`UnitPrice` AS `f0`, `t1_keys`.`k` AS `k`, " +
+		"ROW_NUMBER() OVER (PARTITION BY `t1_keys`.`k` ORDER BY `t1`.`UnitPrice` DESC) AS `rn` " +
+		"FROM (SELECT 0 AS `k`, ? AS `c0`, ? AS `c1` UNION ALL SELECT 1, ?, ?) AS `t1_keys` " +
+		"INNER JOIN `InvoiceLine` AS `t1` ON `t1`.`CustomerId` = `t1_keys`.`c0` AND `t1`.`InvoiceId` = `t1_keys`.`c1` " +
+		"WHERE `t1`.`Quantity` <= ?) AS `t1_rows` WHERE `rn` > 1 AND `rn` <= 3 ORDER BY `rn`"
+	if sql := join.statement(2); sql != expectedSQL {
+		t.Errorf("\nexpected: %s\ngot: %s", expectedSQL, sql)
+	}
+}
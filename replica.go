@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hasura/ndc-sdk-go/connector"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultReplicaHealthCheckInterval = 10 * time.Second
+	replicaHealthCheckTimeout         = 5 * time.Second
+)
+
+// sqlQueryer abstracts the query method of a database handle
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// replica is a read replica of the primary database
+type replica struct {
+	// address of the replica, used as the metrics attribute so that credentials of the DSN are never exported
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaPool routes read traffic across healthy read replicas.
+// Replicas are ejected when a health check fails and are added back once they recover.
+// Reads fall back to the primary database if no replica is healthy
+type replicaPool struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	latency  metric.Float64Histogram
+	// registration of the health gauge callback
+	registration metric.Registration
+	// stops the health checks
+	stop context.CancelFunc
+}
+
+func newReplicaPool(primary *sql.DB, dsns []string, telemetry *connector.TelemetryState) (*replicaPool, error) {
+	pool := &replicaPool{
+		primary: primary,
+	}
+
+	for _, dsn := range dsns {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replica DSN: %w", err)
+		}
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+		r := &replica{
+			name: cfg.Addr,
+			db:   db,
+		}
+		r.healthy.Store(true)
+		pool.replicas = append(pool.replicas, r)
+	}
+
+	if telemetry == nil || len(pool.replicas) == 0 {
+		return pool, nil
+	}
+
+	var err error
+	pool.latency, err = telemetry.Meter.Float64Histogram(
+		"mysql.replica.query_time",
+		metric.WithDescription("Time taken to execute a query on a read replica, in seconds"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	healthGauge, err := telemetry.Meter.Int64ObservableGauge(
+		"mysql.replica.healthy",
+		metric.WithDescription("Health status of read replicas, 1 if the replica is serving reads"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	pool.registration, err = telemetry.Meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		for _, r := range pool.replicas {
+			var value int64
+			if r.healthy.Load() {
+				value = 1
+			}
+			observer.ObserveInt64(healthGauge, value, metric.WithAttributes(attribute.String("replica", r.name)))
+		}
+		return nil
+	}, healthGauge)
+	if err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// pick selects the next healthy replica in round-robin order, or nil if none is healthy
+func (rp *replicaPool) pick() *replica {
+	count := len(rp.replicas)
+	if count == 0 {
+		return nil
+	}
+	start := rp.next.Add(1)
+	for i := 0; i < count; i++ {
+		r := rp.replicas[(start+uint64(i))%uint64(count)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// QueryContext runs a read query on a healthy replica, or on the primary database if no replica is available
+func (rp *replicaPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	r := rp.pick()
+	if r == nil {
+		return rp.primary.QueryContext(ctx, query, args...)
+	}
+
+	startTime := time.Now()
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if rp.latency != nil {
+		rp.latency.Record(ctx, time.Since(startTime).Seconds(), metric.WithAttributes(attribute.String("replica", r.name)))
+	}
+	return rows, err
+}
+
+// Start runs the health checks of replicas in the background until the context is canceled
+func (rp *replicaPool) Start(ctx context.Context, interval time.Duration) {
+	if len(rp.replicas) == 0 {
+		return
+	}
+	logger := connector.GetLogger(ctx)
+	ctx, rp.stop = context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rp.checkHealth(ctx, logger)
+			}
+		}
+	}()
+}
+
+func (rp *replicaPool) checkHealth(ctx context.Context, logger *slog.Logger) {
+	for _, r := range rp.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaHealthCheckTimeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			logger.Info("read replica recovered", slog.String("replica", r.name))
+		} else {
+			logger.Warn("read replica is ejected", slog.String("replica", r.name), slog.Any("error", err))
+		}
+	}
+}
+
+// Close stops the health checks and closes the connections of replicas. The primary database isn't closed
+func (rp *replicaPool) Close() error {
+	if rp.stop != nil {
+		rp.stop()
+	}
+	var errs []error
+	if rp.registration != nil {
+		errs = append(errs, rp.registration.Unregister())
+	}
+	for _, r := range rp.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReplicaPool(t *testing.T) {
+	pool, err := newReplicaPool(nil, []string{
+		"user:secret@tcp(replica-1:3306)/chinook",
+		"user:secret@tcp(replica-2:3306)/chinook",
+	}, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		t.FailNow()
+	}
+
+	t.Run("round_robin", func(t *testing.T) {
+		seen := map[string]int{}
+		for i := 0; i < 4; i++ {
+			seen[pool.pick().name]++
+		}
+		if seen["replica-1:3306"] != 2 || seen["replica-2:3306"] != 2 {
+			t.Errorf("expected reads to be balanced across replicas, got %v", seen)
+			t.FailNow()
+		}
+	})
+
+	t.Run("eject_unhealthy", func(t *testing.T) {
+		pool.replicas[0].healthy.Store(false)
+		defer pool.replicas[0].healthy.Store(true)
+		for i := 0; i < 3; i++ {
+			if name := pool.pick().name; name != "replica-2:3306" {
+				t.Errorf("expected replica-2:3306, got %s", name)
+				t.FailNow()
+			}
+		}
+	})
+
+	t.Run("fallback_primary", func(t *testing.T) {
+		for _, r := range pool.replicas {
+			r.healthy.Store(false)
+			defer r.healthy.Store(true)
+		}
+		if r := pool.pick(); r != nil {
+			t.Errorf("expected no healthy replica, got %s", r.name)
+			t.FailNow()
+		}
+	})
+
+	t.Run("invalid_dsn", func(t *testing.T) {
+		if _, err := newReplicaPool(nil, []string{"invalid"}, nil); err == nil {
+			t.Error("expected error, got nil")
+			t.FailNow()
+		}
+	})
+}
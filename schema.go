@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+const (
+	// the scalar type of string columns, which supports the string comparison operators
+	stringScalarType = "STRING"
+	// the scalar type of affected row counts and generated keys of procedures
+	intScalarType = "INT"
+	// the result type of update and delete procedures
+	mutationResponseObjectType = "mutation_response"
+	// the result type of insert and upsert procedures
+	insertMutationResponseObjectType = "insert_mutation_response"
+)
+
+// buildSchemaResponse builds the NDC schema from the configuration.
+// Scalar types advertise the comparison operators that the query compiler supports
+func buildSchemaResponse(configuration *Configuration) *schema.SchemaResponse {
+	result := &schema.SchemaResponse{
+		ScalarTypes: schema.SchemaResponseScalarTypes{},
+		ObjectTypes: schema.SchemaResponseObjectTypes{},
+		Collections: []schema.CollectionInfo{},
+		Functions:   []schema.FunctionInfo{},
+		Procedures:  []schema.ProcedureInfo{},
+	}
+
+	for name, scalarType := range configuration.Schema.ScalarTypes {
+		aggregateFunctions := schema.ScalarTypeAggregateFunctions{}
+		for fnName, fn := range scalarType.AggregateFunctions {
+			aggregateFunctions[fnName] = schema.AggregateFunctionDefinition{
+				ResultType: schema.NewNullableNamedType(fn.ResultType.Name).Encode(),
+			}
+		}
+		result.ScalarTypes[name] = schema.ScalarType{
+			AggregateFunctions:  aggregateFunctions,
+			ComparisonOperators: comparisonOperatorDefinitions(name),
+		}
+	}
+
+	for name, objectType := range configuration.Schema.ObjectTypes {
+		fields := schema.ObjectTypeFields{}
+		for fieldName, field := range objectType.Fields {
+			fields[fieldName] = schema.ObjectField{
+				Description: optionalString(field.Description),
+				Type:        schema.NewNamedType(field.Type.Name).Encode(),
+			}
+		}
+		result.ObjectTypes[name] = schema.ObjectType{
+			Description: optionalString(objectType.Description),
+			Fields:      fields,
+		}
+	}
+
+	sessionArguments := sessionArgumentInfos(configuration)
+	for _, collection := range configuration.Schema.Collections {
+		uniquenessConstraints := schema.CollectionInfoUniquenessConstraints{}
+		for constraintName, constraint := range collection.UniquenessConstraints {
+			uniqueColumns := []string{}
+			if value, ok := constraint.(map[string]any); ok {
+				if columns, ok := value["unique_columns"].([]any); ok {
+					for _, column := range columns {
+						if c, ok := column.(string); ok {
+							uniqueColumns = append(uniqueColumns, c)
+						}
+					}
+				}
+			}
+			uniquenessConstraints[constraintName] = schema.UniquenessConstraint{
+				UniqueColumns: uniqueColumns,
+			}
+		}
+		foreignKeys := schema.CollectionInfoForeignKeys{}
+		for fkName, fk := range collection.ForeignKeys {
+			foreignKeys[fkName] = schema.ForeignKeyConstraint{
+				ColumnMapping:     fk.ColumnMapping,
+				ForeignCollection: fk.ForeignCollection,
+			}
+		}
+		result.Collections = append(result.Collections, schema.CollectionInfo{
+			Name:                  collection.Name,
+			Description:           optionalString(collection.Description),
+			Arguments:             schema.CollectionInfoArguments(sessionArguments),
+			Type:                  collection.Type,
+			UniquenessConstraints: uniquenessConstraints,
+			ForeignKeys:           foreignKeys,
+		})
+		result.Procedures = append(result.Procedures, collectionProcedures(configuration, collection, sessionArguments)...)
+		if objectType, ok := configuration.Schema.ObjectTypes[collection.Type]; ok {
+			result.ObjectTypes[inputObjectTypeName(collection.Name)] = inputObjectType(collection, objectType)
+		}
+	}
+	addMutationResponseTypes(result)
+
+	return result
+}
+
+// collectionProcedures returns the generated update, delete, insert and upsert procedures of a collection.
+// Inserted rows can't be filtered by a predicate, so collections with row-level security don't have insert and upsert procedures.
+// Update and delete procedures also accept the session arguments of the permissions
+func collectionProcedures(configuration *Configuration, collection Collection, sessionArguments map[string]schema.ArgumentInfo) []schema.ProcedureInfo {
+	whereArgument := schema.ArgumentInfo{
+		Description: optionalString("Filters the affected rows. All rows are affected if it is null"),
+		Type:        schema.NewNullableType(schema.NewPredicateType(collection.Type)).Encode(),
+	}
+	procedures := []schema.ProcedureInfo{
+		{
+			Name:        updateProcedurePrefix + collection.Name,
+			Description: optionalString(fmt.Sprintf("Updates the rows of %s that match the predicate", collection.Name)),
+			Arguments: schema.ProcedureInfoArguments{
+				"set": {
+					Description: optionalString("The new values of the updated columns"),
+					Type:        schema.NewNamedType(inputObjectTypeName(collection.Name)).Encode(),
+				},
+				"where": whereArgument,
+			},
+			ResultType: schema.NewNamedType(mutationResponseObjectType).Encode(),
+		},
+		{
+			Name:        deleteProcedurePrefix + collection.Name,
+			Description: optionalString(fmt.Sprintf("Deletes the rows of %s that match the predicate", collection.Name)),
+			Arguments: schema.ProcedureInfoArguments{
+				"where": whereArgument,
+			},
+			ResultType: schema.NewNamedType(mutationResponseObjectType).Encode(),
+		},
+	}
+	for _, procedure := range procedures {
+		for name, argument := range sessionArguments {
+			if _, ok := procedure.Arguments[name]; !ok {
+				procedure.Arguments[name] = argument
+			}
+		}
+	}
+	if permission, ok := configuration.Permissions[collection.Name]; ok && len(permission.Predicate) > 0 {
+		return procedures
+	}
+
+	objectsArgument := schema.ArgumentInfo{
+		Description: optionalString("The inserted rows. Omitted columns are inserted with their default values"),
+		Type:        schema.NewArrayType(schema.NewNamedType(inputObjectTypeName(collection.Name))).Encode(),
+	}
+	return append(procedures,
+		schema.ProcedureInfo{
+			Name:        insertProcedurePrefix + collection.Name,
+			Description: optionalString(fmt.Sprintf("Inserts rows into %s", collection.Name)),
+			Arguments:   schema.ProcedureInfoArguments{"objects": objectsArgument},
+			ResultType:  schema.NewNamedType(insertMutationResponseObjectType).Encode(),
+		},
+		schema.ProcedureInfo{
+			Name:        upsertProcedurePrefix + collection.Name,
+			Description: optionalString(fmt.Sprintf("Inserts rows into %s, or updates the existing rows with the same primary or unique keys", collection.Name)),
+			Arguments:   schema.ProcedureInfoArguments{"objects": objectsArgument},
+			ResultType:  schema.NewNamedType(insertMutationResponseObjectType).Encode(),
+		},
+	)
+}
+
+// sessionArgumentInfos returns the session arguments that the variables of the permission predicates refer to.
+// Session arguments apply to every collection that a request reaches through relationships, so every collection declares them.
+// They are nullable because a request that doesn't access a collection with a permission doesn't need them,
+// and a request that omits an argument that a permission requires is forbidden
+func sessionArgumentInfos(configuration *Configuration) map[string]schema.ArgumentInfo {
+	results := map[string]schema.ArgumentInfo{}
+	for _, name := range sortedKeys(configuration.Permissions) {
+		permission := configuration.Permissions[name]
+		if len(permission.Predicate) > 0 {
+			collectSessionArguments(configuration, name, name, permission.Predicate, results)
+		}
+	}
+	return results
+}
+
+// collectSessionArguments adds the variables of the permission predicate of the root collection to the results.
+// The type of an argument is the type of the column that it is compared with, or STRING if the column is unknown
+func collectSessionArguments(configuration *Configuration, rootCollection string, collection string, expression schema.Expression, results map[string]schema.ArgumentInfo) {
+	exprValue, err := expression.InterfaceT()
+	if err != nil {
+		return
+	}
+	switch expr := exprValue.(type) {
+	case *schema.ExpressionAnd:
+		for _, item := range expr.Expressions {
+			collectSessionArguments(configuration, rootCollection, collection, item, results)
+		}
+	case *schema.ExpressionOr:
+		for _, item := range expr.Expressions {
+			collectSessionArguments(configuration, rootCollection, collection, item, results)
+		}
+	case *schema.ExpressionNot:
+		collectSessionArguments(configuration, rootCollection, collection, expr.Expression, results)
+	case *schema.ExpressionExists:
+		// relationships of permissions are unknown, so columns of related collections fall back to STRING
+		inCollection := ""
+		if unrelated, err := expr.InCollection.AsUnrelated(); err == nil {
+			inCollection = unrelated.Collection
+		}
+		if len(expr.Predicate) > 0 {
+			collectSessionArguments(configuration, rootCollection, inCollection, expr.Predicate, results)
+		}
+	case *schema.ExpressionBinaryComparisonOperator:
+		variable, err := expr.Value.AsVariable()
+		if err != nil {
+			return
+		}
+		if _, ok := results[variable.Name]; ok {
+			return
+		}
+		columnCollection := collection
+		if expr.Column.Type == schema.ComparisonTargetTypeRootCollectionColumn {
+			columnCollection = rootCollection
+		}
+		var argumentType schema.TypeEncoder = schema.NewNamedType(columnScalarType(configuration, columnCollection, expr.Column.Name))
+		if expr.Operator == inOperator {
+			argumentType = schema.NewArrayType(argumentType)
+		}
+		results[variable.Name] = schema.ArgumentInfo{
+			Description: optionalString(fmt.Sprintf("Session argument of the permission of %s", rootCollection)),
+			Type:        schema.NewNullableType(argumentType).Encode(),
+		}
+	}
+}
+
+// columnScalarType returns the scalar type name of a column of a collection, or STRING if the column is unknown
+func columnScalarType(configuration *Configuration, collection string, column string) string {
+	for _, c := range configuration.Schema.Collections {
+		if c.Name != collection {
+			continue
+		}
+		if field, ok := configuration.Schema.ObjectTypes[c.Type].Fields[column]; ok && field.Type.Name != "" {
+			return field.Type.Name
+		}
+	}
+	return stringScalarType
+}
+
+func inputObjectTypeName(collection string) string {
+	return collection + "_input"
+}
+
+// inputObjectType returns the object type of the inserted and updated values of a collection.
+// Every field is nullable, so objects can omit columns
+func inputObjectType(collection Collection, objectType ObjectType) schema.ObjectType {
+	fields := schema.ObjectTypeFields{}
+	for fieldName, field := range objectType.Fields {
+		fields[fieldName] = schema.ObjectField{
+			Description: optionalString(field.Description),
+			Type:        schema.NewNullableNamedType(field.Type.Name).Encode(),
+		}
+	}
+	return schema.ObjectType{
+		Description: optionalString(fmt.Sprintf("The inserted or updated values of %s", collection.Name)),
+		Fields:      fields,
+	}
+}
+
+// addMutationResponseTypes adds the result types of the generated procedures
+func addMutationResponseTypes(result *schema.SchemaResponse) {
+	if _, ok := result.ScalarTypes[intScalarType]; !ok {
+		result.ScalarTypes[intScalarType] = schema.ScalarType{
+			AggregateFunctions:  schema.ScalarTypeAggregateFunctions{},
+			ComparisonOperators: comparisonOperatorDefinitions(intScalarType),
+		}
+	}
+	affectedRows := schema.ObjectField{
+		Description: optionalString("The number of rows that the procedure inserts, updates or deletes"),
+		Type:        schema.NewNamedType(intScalarType).Encode(),
+	}
+	result.ObjectTypes[mutationResponseObjectType] = schema.ObjectType{
+		Description: optionalString("The result of an update or delete procedure"),
+		Fields: schema.ObjectTypeFields{
+			"affected_rows": affectedRows,
+		},
+	}
+	result.ObjectTypes[insertMutationResponseObjectType] = schema.ObjectType{
+		Description: optionalString("The result of an insert or upsert procedure"),
+		Fields: schema.ObjectTypeFields{
+			"affected_rows": affectedRows,
+			"generated_keys": {
+				Description: optionalString("The auto-increment keys of the inserted objects in order, or null if a key is unknown"),
+				Type:        schema.NewArrayType(schema.NewNullableNamedType(intScalarType)).Encode(),
+			},
+		},
+	}
+}
+
+// comparisonOperatorDefinitions returns the comparison operators of a scalar type.
+// String columns additionally support pattern matching and the _match full-text search operator,
+// which requires a FULLTEXT index on the column
+func comparisonOperatorDefinitions(scalarType string) map[string]schema.ComparisonOperatorDefinition {
+	results := map[string]schema.ComparisonOperatorDefinition{
+		"_eq":  schema.NewComparisonOperatorEqual().Encode(),
+		"_in":  schema.NewComparisonOperatorIn().Encode(),
+		"_lte": schema.NewComparisonOperatorCustom(schema.NewNamedType(scalarType)).Encode(),
+	}
+	if scalarType != stringScalarType {
+		return results
+	}
+	for _, name := range []string{"_like", "_ilike", "_nlike", "_regex", "_iregex", matchOperator} {
+		results[name] = schema.NewComparisonOperatorCustom(schema.NewNamedType(stringScalarType)).Encode()
+	}
+	return results
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ndc-sdk-go/schema"
+)
+
+func TestBuildSchemaResponseProcedures(t *testing.T) {
+	configuration := &Configuration{
+		Schema: Schema{
+			ObjectTypes: map[string]ObjectType{
+				"Artist": {
+					Fields: map[string]Field{
+						"ArtistId": {Type: DataType{Type: "named", Name: "INT"}},
+						"Name":     {Type: DataType{Type: "named", Name: "STRING"}},
+					},
+				},
+				"Customer": {
+					Fields: map[string]Field{
+						"CustomerId": {Type: DataType{Type: "named", Name: "INT"}},
+						"Country":    {Type: DataType{Type: "named", Name: "STRING"}},
+					},
+				},
+			},
+			Collections: []Collection{
+				{Name: "Artist", Type: "Artist"},
+				{Name: "Customer", Type: "Customer"},
+			},
+		},
+		Permissions: testPermissions,
+	}
+
+	result := buildSchemaResponse(configuration)
+	procedures := map[string]schema.ProcedureInfo{}
+	var names []string
+	for _, procedure := range result.Procedures {
+		procedures[procedure.Name] = procedure
+		names = append(names, procedure.Name)
+	}
+	expectedNames := []string{
+		"update_Artist", "delete_Artist", "insert_Artist", "upsert_Artist",
+		"update_Customer", "delete_Customer",
+	}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("expected procedures %v, got %v", expectedNames, names)
+	}
+
+	insert := procedures["insert_Artist"]
+	expectedObjectsType := schema.NewArrayType(schema.NewNamedType("Artist_input")).Encode()
+	if !reflect.DeepEqual(insert.Arguments["objects"].Type, expectedObjectsType) {
+		t.Errorf("expected objects type %v, got %v", expectedObjectsType, insert.Arguments["objects"].Type)
+	}
+	if !reflect.DeepEqual(insert.ResultType, schema.NewNamedType(insertMutationResponseObjectType).Encode()) {
+		t.Errorf("expected the insert result type, got %v", insert.ResultType)
+	}
+	expectedWhereType := schema.NewNullableType(schema.NewPredicateType("Artist")).Encode()
+	if !reflect.DeepEqual(procedures["delete_Artist"].Arguments["where"].Type, expectedWhereType) {
+		t.Errorf("expected where type %v, got %v", expectedWhereType, procedures["delete_Artist"].Arguments["where"].Type)
+	}
+
+	// the session argument of the Customer permission is declared on every collection, and the update and delete procedures
+	expectedSessionType := schema.NewNullableType(schema.NewArrayType(schema.NewNamedType("STRING"))).Encode()
+	for _, collection := range result.Collections {
+		if !reflect.DeepEqual(collection.Arguments["countries"].Type, expectedSessionType) {
+			t.Errorf("expected the countries argument of collection %s, got %v", collection.Name, collection.Arguments)
+		}
+	}
+	for _, name := range []string{"update_Customer", "delete_Customer"} {
+		if !reflect.DeepEqual(procedures[name].Arguments["countries"].Type, expectedSessionType) {
+			t.Errorf("expected the countries argument of procedure %s, got %v", name, procedures[name].Arguments)
+		}
+	}
+	if _, ok := procedures["insert_Artist"].Arguments["countries"]; ok {
+		t.Errorf("expected no session arguments of procedure insert_Artist")
+	}
+
+	input, ok := result.ObjectTypes["Artist_input"]
+	if !ok {
+		t.Fatalf("expected the Artist_input object type")
+	}
+	if !reflect.DeepEqual(input.Fields["Name"].Type, schema.NewNullableNamedType("STRING").Encode()) {
+		t.Errorf("expected a nullable Name field, got %v", input.Fields["Name"].Type)
+	}
+	for _, name := range []string{mutationResponseObjectType, insertMutationResponseObjectType} {
+		if _, ok := result.ObjectTypes[name]; !ok {
+			t.Errorf("expected the %s object type", name)
+		}
+	}
+	if _, ok := result.ScalarTypes[intScalarType]; !ok {
+		t.Errorf("expected the %s scalar type", intScalarType)
+	}
+}